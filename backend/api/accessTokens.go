@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const ACCESS_TOKEN_PREFIX = "sgt_"
+const WS_TOKEN_PROTOCOL_PREFIX = "token."
+const SCOPES_CONTEXT_KEY = "scopes"
+
+func NewAccessToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return ACCESS_TOKEN_PREFIX + base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func HashAccessToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+// Access token is taken either from "Authorization: Bearer <token>" header
+// or, since browsers can not set headers for websockets, from "token.<token>" subprotocol
+func getAccessToken(c *gin.Context) (string, bool) {
+	if token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); found {
+		return strings.TrimSpace(token), true
+	}
+	for _, protocol := range strings.Split(c.GetHeader("Sec-WebSocket-Protocol"), ",") {
+		if token, found := strings.CutPrefix(strings.TrimSpace(protocol), WS_TOKEN_PROTOCOL_PREFIX); found {
+			return token, true
+		}
+	}
+	return "", false
+}
+
+func authorizeAccessToken(c *gin.Context, mdb *mongo.Database, token string) {
+	accessToken, httpErr := entities.GetAccessTokenByHash(mdb, HashAccessToken(token))
+	if httpErr != nil {
+		custErrors.AbortWithError(c, httpErr)
+		return
+	}
+
+	now := time.Now()
+	if accessToken.ExpiresAt != nil && now.After(*accessToken.ExpiresAt) {
+		custErrors.AbortWithError(c, custErrors.NewHttpError(
+			http.StatusUnauthorized,
+			custErrors.AccessTokenExpired,
+		))
+		return
+	}
+
+	dbUser, httpErr := entities.GetDbUser(mdb, accessToken.UserId)
+	if httpErr != nil {
+		custErrors.AbortWithError(c, httpErr)
+		return
+	}
+	if dbUser.IsSuspended() {
+		custErrors.AbortWithError(c, dbUser.SuspensionError())
+		return
+	}
+
+	_, err := mdb.Collection(entities.ACCESS_TOKENS_COLLECTION).UpdateByID(
+		context.TODO(),
+		accessToken.Id,
+		bson.M{"$set": bson.M{"lastUsedAt": now}},
+	)
+	if err != nil {
+		custErrors.AbortWithInternalError(c, err)
+		return
+	}
+
+	c.Set(USER_ID_CONTEXT_KEY, accessToken.UserId)
+	c.Set(SCOPES_CONTEXT_KEY, accessToken.Scopes)
+}
+
+// Requests authorized with a session cookie are allowed everything,
+// requests authorized with an access token only what its scopes grant
+func RequireScope(scope entities.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, ok := c.Get(SCOPES_CONTEXT_KEY)
+		if !ok {
+			return
+		}
+		if !slices.Contains(scopes.([]entities.Scope), scope) {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusForbidden,
+				custErrors.ScopeMissing, scope,
+			))
+			return
+		}
+	}
+}
+
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get(SESSION_ID_CONTEXT_KEY); !ok {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusForbidden,
+				custErrors.SessionRequired,
+			))
+			return
+		}
+	}
+}
+
+func CreateAccessTokenHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		var accessTokenDTO entities.AccessTokenDTO
+		if err := c.ShouldBindJSON(&accessTokenDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+
+		if accessTokenDTO.ExpiresAt != nil && accessTokenDTO.ExpiresAt.Before(time.Now()) {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.ExpirationInPast,
+			))
+			return
+		}
+
+		token, err := NewAccessToken()
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		accessToken := &entities.AccessToken{
+			UserId:         userId,
+			Hash:           HashAccessToken(token),
+			CreatedAt:      time.Now(),
+			AccessTokenDTO: accessTokenDTO,
+		}
+
+		res, err := mdb.Collection(entities.ACCESS_TOKENS_COLLECTION).InsertOne(context.TODO(), accessToken)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		accessToken.Id = res.InsertedID.(primitive.ObjectID)
+
+		// the token itself is shown only once, only its hash is stored
+		c.JSON(http.StatusCreated, gin.H{
+			"token":       token,
+			"accessToken": accessToken,
+		})
+	}
+}
+
+func GetAccessTokensHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		accessTokens := make([]entities.AccessToken, 0)
+		res, err := mdb.Collection(entities.ACCESS_TOKENS_COLLECTION).Find(
+			context.TODO(),
+			bson.M{"userId": userId},
+			options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}),
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if err := res.All(context.TODO(), &accessTokens); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, accessTokens)
+	}
+}
+
+func RevokeAccessTokenHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		tokenId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.InvalidId, "tokenId",
+			))
+			return
+		}
+
+		res, err := mdb.Collection(entities.ACCESS_TOKENS_COLLECTION).DeleteOne(
+			context.TODO(),
+			bson.M{"_id": tokenId, "userId": userId},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if res.DeletedCount == 0 {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusNotFound,
+				custErrors.AccessTokenNotFound,
+			))
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
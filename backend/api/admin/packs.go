@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func GetPackHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		packId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.InvalidId, "packId",
+			))
+			return
+		}
+
+		pack, httpErr := entities.GetPack(mdb, packId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		c.JSON(http.StatusOK, pack)
+	}
+}
+
+func SetPackVisibilityHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		packId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.InvalidId, "packId",
+			))
+			return
+		}
+
+		var packVisibilityDTO entities.PackVisibilityDTO
+		if err := c.ShouldBindJSON(&packVisibilityDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+
+		res, err := mdb.Collection(entities.PACKS_COLLECTION).UpdateByID(
+			context.TODO(),
+			packId,
+			bson.M{"$set": bson.M{"isHidden": packVisibilityDTO.IsHidden}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if res.MatchedCount == 0 {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusNotFound,
+				custErrors.PackNotFound, packId.Hex(),
+			))
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,156 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/media"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const STATUS_QUERY_PARAM = "status"
+const PACK_QUERY_PARAM = "packId"
+
+// Open reports are returned oldest first, so the queue is worked through in order
+func GetReportsHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := entities.ReportStatus(c.DefaultQuery(STATUS_QUERY_PARAM, string(entities.ReportOpen)))
+		if status != entities.ReportOpen && status != entities.ReportResolved {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.NotOneOf, "status", "open, resolved",
+			))
+			return
+		}
+
+		filter := bson.M{"status": status}
+		if packIdStr := c.Query(PACK_QUERY_PARAM); packIdStr != "" {
+			packId, err := primitive.ObjectIDFromHex(packIdStr)
+			if err != nil {
+				custErrors.AbortWithError(c, custErrors.NewHttpError(
+					http.StatusBadRequest,
+					custErrors.InvalidId, "packId",
+				))
+				return
+			}
+			filter["packId"] = packId
+		}
+
+		sortOrder := 1
+		if status == entities.ReportResolved {
+			sortOrder = -1
+		}
+
+		reports := make([]entities.Report, 0)
+		res, err := mdb.Collection(entities.REPORTS_COLLECTION).Find(
+			context.TODO(),
+			filter,
+			options.Find().SetSort(bson.D{{Key: "_id", Value: sortOrder}}).SetLimit(MAX_LIMIT),
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if err := res.All(context.TODO(), &reports); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, reports)
+	}
+}
+
+// Hiding or deleting the pack resolves every open report on it,
+// dismissing resolves only the given one
+func ResolveReportHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		user, httpErr := entities.GetUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		reportId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.InvalidId, "reportId",
+			))
+			return
+		}
+
+		var resolutionDTO entities.ResolutionDTO
+		if err := c.ShouldBindJSON(&resolutionDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+
+		report, httpErr := entities.GetReport(mdb, reportId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+		if report.Status == entities.ReportResolved {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusConflict,
+				custErrors.ReportAlreadyResolved,
+			))
+			return
+		}
+
+		switch resolutionDTO.Action {
+		case entities.ActionHide:
+			_, err = mdb.Collection(entities.PACKS_COLLECTION).UpdateByID(
+				context.TODO(),
+				report.PackId,
+				bson.M{"$set": bson.M{"isHidden": true}},
+			)
+		case entities.ActionDelete:
+			var contents []entities.PackDTO
+			contents, err = entities.DeletePack(mdb, report.PackId)
+			for i := 0; err == nil && i < len(contents); i++ {
+				err = ms.UpdateRefs(context.TODO(), &contents[i], nil)
+			}
+			// the pack may already be deleted by its author
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				err = nil
+			}
+		}
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		resolution := entities.Resolution{
+			ResolutionDTO: resolutionDTO,
+			ResolvedBy:    *user,
+			ResolvedAt:    time.Now(),
+		}
+		filter := bson.M{"_id": report.Id}
+		if resolutionDTO.Action != entities.ActionDismiss {
+			filter = bson.M{"packId": report.PackId, "status": entities.ReportOpen}
+		}
+		_, err = mdb.Collection(entities.REPORTS_COLLECTION).UpdateMany(
+			context.TODO(),
+			filter,
+			bson.M{"$set": bson.M{"status": entities.ReportResolved, "resolution": resolution}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, resolution)
+	}
+}
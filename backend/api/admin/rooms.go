@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/api/ws"
+	wsLobby "github.com/holdennekt/sgame/api/ws/lobby"
+	lobbyEvents "github.com/holdennekt/sgame/api/ws/lobby/events"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/redis/go-redis/v9"
+)
+
+func GetRoomsHandler(rds *redis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roomKeys, err := entities.GetRoomKeys(rds)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		rooms := make([]entities.Room, 0, len(roomKeys))
+		for _, roomKey := range roomKeys {
+			room, httpErr := entities.GetRoomByKey(rds, roomKey)
+			if httpErr != nil {
+				custErrors.AbortWithError(c, httpErr)
+				return
+			}
+			rooms = append(rooms, *room)
+		}
+
+		c.JSON(http.StatusOK, rooms)
+	}
+}
+
+func CloseRoomHandler(rds *redis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roomKey := entities.GetRoomRedisKey(c.Param("id"))
+		room, httpErr := entities.GetRoomByKey(rds, roomKey)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		if err := rds.Del(context.TODO(), roomKey).Err(); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		roomDeletedMessage := lobbyEvents.NewRoomDeletedInternalMessage(room.Id)
+		if err := ws.PublishRdsMessage(rds, roomKey, roomDeletedMessage); err != nil {
+			log.Println(err)
+		}
+		if err := ws.PublishRdsMessage(rds, wsLobby.LOBBY, roomDeletedMessage); err != nil {
+			log.Println(err)
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,211 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/api/ws"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const FILTER_QUERY_PARAM = "filter"
+const LIMIT_QUERY_PARAM = "limit"
+
+const DEFAULT_LIMIT = "50"
+const MAX_LIMIT = 100
+
+type userView struct {
+	entities.User
+	Login      string               `json:"login"`
+	Role       entities.Role        `json:"role"`
+	Suspension *entities.Suspension `json:"suspension"`
+}
+
+func newUserView(dbUser entities.DbUser) userView {
+	return userView{
+		User:       dbUser.User,
+		Login:      dbUser.Login,
+		Role:       dbUser.GetRole(),
+		Suspension: dbUser.Suspension,
+	}
+}
+
+// Moderators can manage only regular users, admins everyone except admins
+func getManagedUser(c *gin.Context, mdb *mongo.Database) (*entities.DbUser, custErrors.HttpError) {
+	userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+	role := c.MustGet(api.ROLE_CONTEXT_KEY).(entities.Role)
+
+	targetId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		return nil, custErrors.NewHttpError(
+			http.StatusBadRequest,
+			custErrors.InvalidId, "userId",
+		)
+	}
+	if targetId == userId {
+		return nil, custErrors.NewHttpError(
+			http.StatusForbidden,
+			custErrors.CanNotManageSelf,
+		)
+	}
+
+	dbUser, httpErr := entities.GetDbUser(mdb, targetId)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	if dbUser.GetRole().Includes(role) {
+		return nil, custErrors.NewHttpError(
+			http.StatusForbidden,
+			custErrors.CanNotManageHigherRole,
+		)
+	}
+	return dbUser, nil
+}
+
+func GetUsersHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		searchFilter := regexp.QuoteMeta(strings.TrimSpace(c.Query(FILTER_QUERY_PARAM)))
+		limit, err := strconv.ParseInt(c.DefaultQuery(LIMIT_QUERY_PARAM, DEFAULT_LIMIT), 10, 64)
+		if err != nil || limit < 1 || limit > MAX_LIMIT {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.LimitOutOfRange, MAX_LIMIT,
+			))
+			return
+		}
+
+		dbUsers := make([]entities.DbUser, 0)
+		res, err := mdb.Collection(entities.USERS_COLLECTION).Find(
+			context.TODO(),
+			bson.M{"$or": []bson.M{
+				{"name": primitive.Regex{Pattern: searchFilter, Options: "i"}},
+				{"login": primitive.Regex{Pattern: searchFilter, Options: "i"}},
+			}},
+			options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit),
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if err := res.All(context.TODO(), &dbUsers); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		users := make([]userView, len(dbUsers))
+		for i, dbUser := range dbUsers {
+			users[i] = newUserView(dbUser)
+		}
+
+		c.JSON(http.StatusOK, users)
+	}
+}
+
+func SuspendUserHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		dbUser, httpErr := getManagedUser(c, mdb)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		var suspensionDTO entities.SuspensionDTO
+		if err := c.ShouldBindJSON(&suspensionDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+
+		suspension := entities.Suspension{
+			SuspensionDTO: suspensionDTO,
+			SuspendedBy:   userId,
+			SuspendedAt:   time.Now(),
+		}
+		_, err := mdb.Collection(entities.USERS_COLLECTION).UpdateByID(
+			context.TODO(),
+			dbUser.Id,
+			bson.M{"$set": bson.M{"suspension": suspension}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		// suspended user must lose access immediately, not after the session expires.
+		// Access tokens are kept, they are rejected only while the suspension lasts
+		if err := api.DeleteUserSessions(rds, dbUser.Id.Hex()); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if err := ws.DisconnectUser(rds, dbUser.Id.Hex()); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, suspension)
+	}
+}
+
+func UnsuspendUserHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dbUser, httpErr := getManagedUser(c, mdb)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		_, err := mdb.Collection(entities.USERS_COLLECTION).UpdateByID(
+			context.TODO(),
+			dbUser.Id,
+			bson.M{"$unset": bson.M{"suspension": ""}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func SetRoleHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dbUser, httpErr := getManagedUser(c, mdb)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		var roleDTO entities.RoleDTO
+		if err := c.ShouldBindJSON(&roleDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+
+		_, err := mdb.Collection(entities.USERS_COLLECTION).UpdateByID(
+			context.TODO(),
+			dbUser.Id,
+			bson.M{"$set": bson.M{"role": roleDTO.Role}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		dbUser.Role = roleDTO.Role
+		c.JSON(http.StatusOK, newUserView(*dbUser))
+	}
+}
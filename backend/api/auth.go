@@ -2,12 +2,10 @@ package api
 
 import (
 	"context"
-	"errors"
 	"net/http"
-	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/holdennekt/sgame/custErrors"
 	"github.com/holdennekt/sgame/entities"
 	"github.com/redis/go-redis/v9"
@@ -25,69 +23,31 @@ func CheckPasswordHash(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-func LoginHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
+func LoginHandler(rds *redis.Client, so SessionOptions, provider AuthProvider) gin.HandlerFunc {
 	return func(c *gin.Context) {
-
-		var dbUserDTO entities.DbUserDTO
-		if err := c.ShouldBind(&dbUserDTO); err != nil {
-			c.AbortWithStatusJSON(
-				http.StatusBadRequest,
-				gin.H{"error": strings.Join(custErrors.ParseValidationErrors(err), ", ")},
-			)
-			return
-		}
-
-		dbUser, httpErr := entities.GetDbUserByLogin(mdb, dbUserDTO.Login)
+		authResult, httpErr := provider.Authenticate(c)
 		if httpErr != nil {
 			custErrors.AbortWithError(c, httpErr)
 			return
 		}
 
-		userIdStr := dbUser.Id.Hex()
-
-		err := bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte(dbUserDTO.Password))
-		if err != nil {
-			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-				c.AbortWithStatusJSON(
-					http.StatusUnauthorized,
-					gin.H{"error": "wrong password"},
-				)
-				return
-			}
-			custErrors.AbortWithInternalError(c, err)
-			return
-		}
+		userIdStr := authResult.UserId.Hex()
 
-		sessions, err := rds.HGetAll(context.TODO(), SESSIONS_KEY).Result()
+		sessionId, err := SetSession(rds, so, userIdStr, c.Request.UserAgent(), false)
 		if err != nil {
 			custErrors.AbortWithInternalError(c, err)
 			return
 		}
-		for sessionId, userId := range sessions {
-			if userIdStr == userId {
-				c.SetCookie(SESSION_ID_COOKIE_NAME, sessionId, 0, "", "", false, true)
-				c.JSON(http.StatusOK, gin.H{"id": userIdStr})
-				return
-			}
-		}
-
-		sessionId, err := SetSession(rds, userIdStr)
-		if err != nil {
-			custErrors.AbortWithInternalError(c, err)
-		}
-		c.SetCookie(SESSION_ID_COOKIE_NAME, sessionId, 0, "", "", false, true)
+		so.SetCookie(c, sessionId)
 		c.JSON(http.StatusOK, gin.H{"id": userIdStr})
 	}
 }
 
-func RegisterHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
+func RegisterHandler(mdb *mongo.Database, rds *redis.Client, so SessionOptions) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var dbUserDTO entities.DbUserDTO
 		if err := c.ShouldBind(&dbUserDTO); err != nil {
-			c.AbortWithStatusJSON(
-				http.StatusBadRequest,
-				gin.H{"error": strings.Join(custErrors.ParseValidationErrors(err), ", ")},
-			)
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
 			return
 		}
 
@@ -109,10 +69,10 @@ func RegisterHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 		res, err := mdb.Collection(entities.USERS_COLLECTION).InsertOne(context.TODO(), dbUser)
 		if err != nil {
 			if mongo.IsDuplicateKeyError(err) {
-				c.AbortWithStatusJSON(
+				custErrors.AbortWithError(c, custErrors.NewHttpError(
 					http.StatusConflict,
-					gin.H{"error": "such login already exists"},
-				)
+					custErrors.LoginTaken,
+				))
 				return
 			}
 			custErrors.AbortWithInternalError(c, err)
@@ -120,26 +80,48 @@ func RegisterHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 		}
 		userIdStr := res.InsertedID.(primitive.ObjectID).Hex()
 
-		sessionId, err := SetSession(rds, userIdStr)
+		sessionId, err := SetSession(rds, so, userIdStr, c.Request.UserAgent(), false)
 		if err != nil {
 			custErrors.AbortWithInternalError(c, err)
+			return
 		}
-		c.SetCookie(SESSION_ID_COOKIE_NAME, sessionId, 0, "", "", false, true)
+		so.SetCookie(c, sessionId)
 		c.JSON(http.StatusCreated, gin.H{"id": userIdStr})
 	}
 }
 
-func SetSession(rds *redis.Client, userId string) (string, error) {
-	sessionId, err := uuid.NewRandom()
-	if err != nil {
-		return "", err
-	}
+func GuestLoginHandler(mdb *mongo.Database, rds *redis.Client, so SessionOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var guestDTO entities.GuestDTO
+		if err := c.ShouldBind(&guestDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+
+		expiresAt := time.Now().Add(so.GuestTTL)
+		dbUser := &entities.DbUser{
+			User: entities.User{
+				Name:    guestDTO.Name,
+				IsGuest: true,
+			},
+			ExpiresAt: &expiresAt,
+		}
+
+		res, err := mdb.Collection(entities.USERS_COLLECTION).InsertOne(context.TODO(), dbUser)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		userIdStr := res.InsertedID.(primitive.ObjectID).Hex()
 
-	err = rds.HSet(context.TODO(), SESSIONS_KEY, sessionId.String(), userId).Err()
-	if err != nil {
-		return "", err
+		sessionId, err := SetSession(rds, so, userIdStr, c.Request.UserAgent(), true)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		so.SetGuestCookie(c, sessionId)
+		c.JSON(http.StatusCreated, gin.H{"id": userIdStr})
 	}
-	return sessionId.String(), nil
 }
 
 func GetUser(mdb *mongo.Database) gin.HandlerFunc {
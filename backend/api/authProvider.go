@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const PASSWORD_PROVIDER = "password"
+
+// Result of a successful authentication. UserId is set when the provider
+// itself knows the user (password), otherwise the user is resolved by Identity
+type AuthResult struct {
+	UserId   *primitive.ObjectID
+	Identity entities.Identity
+	Name     string
+	Avatar   *string
+}
+
+type AuthProvider interface {
+	Name() string
+	Authenticate(c *gin.Context) (*AuthResult, custErrors.HttpError)
+}
+
+// Provider that authenticates on a third party site, BeginAuth returns
+// the url the user must be redirected to
+type RedirectAuthProvider interface {
+	AuthProvider
+	BeginAuth(c *gin.Context) (string, error)
+}
+
+type PasswordProvider struct {
+	mdb *mongo.Database
+}
+
+func NewPasswordProvider(mdb *mongo.Database) *PasswordProvider {
+	return &PasswordProvider{mdb: mdb}
+}
+
+func (pp *PasswordProvider) Name() string {
+	return PASSWORD_PROVIDER
+}
+
+func (pp *PasswordProvider) Authenticate(c *gin.Context) (*AuthResult, custErrors.HttpError) {
+	var dbUserDTO entities.DbUserDTO
+	if err := c.ShouldBind(&dbUserDTO); err != nil {
+		return nil, custErrors.NewValidationError(err)
+	}
+
+	dbUser, httpErr := entities.GetDbUserByLogin(pp.mdb, dbUserDTO.Login)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	err := CheckPasswordHash(dbUserDTO.Password, dbUser.Password)
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return nil, custErrors.NewHttpError(
+				http.StatusUnauthorized,
+				custErrors.WrongPassword,
+			)
+		}
+		return nil, custErrors.NewInternalError(err)
+	}
+
+	if dbUser.IsSuspended() {
+		return nil, dbUser.SuspensionError()
+	}
+
+	return &AuthResult{
+		UserId:   &dbUser.Id,
+		Identity: entities.Identity{Provider: PASSWORD_PROVIDER, Subject: dbUser.Login},
+		Name:     dbUser.Name,
+		Avatar:   dbUser.Avatar,
+	}, nil
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/custErrors"
+)
+
+const REQUEST_ID_HEADER = "X-Request-Id"
+
+// Ids set by proxies are kept to follow the request through their logs too
+const MAX_REQUEST_ID_LENGTH = 64
+
+func isValidRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > MAX_REQUEST_ID_LENGTH {
+		return false
+	}
+	for _, r := range requestId {
+		isAlphanumeric := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+		if !isAlphanumeric && r != '-' && r != '_' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// Gives every request an id that is logged with its errors and sent back
+// in the header and in error responses, so a report of a user can be found in logs
+func CorrelateRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		correlationId := c.GetHeader(REQUEST_ID_HEADER)
+		if !isValidRequestId(correlationId) {
+			correlationId = custErrors.NewCorrelationId()
+		}
+		c.Set(custErrors.CORRELATION_ID_CONTEXT_KEY, correlationId)
+		c.Header(REQUEST_ID_HEADER, correlationId)
+	}
+}
@@ -0,0 +1,274 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const PROVIDER_PARAM = "provider"
+const DEFAULT_USER_NAME = "user"
+
+type AuthProviders map[string]RedirectAuthProvider
+
+func NewAuthProviders(providers ...RedirectAuthProvider) AuthProviders {
+	ap := make(AuthProviders, len(providers))
+	for _, provider := range providers {
+		ap[provider.Name()] = provider
+	}
+	return ap
+}
+
+func (ap AuthProviders) get(c *gin.Context) (RedirectAuthProvider, custErrors.HttpError) {
+	provider, ok := ap[c.Param(PROVIDER_PARAM)]
+	if !ok {
+		return nil, custErrors.NewHttpError(
+			http.StatusNotFound,
+			custErrors.UnknownAuthProvider,
+		)
+	}
+	return provider, nil
+}
+
+func truncateName(name string) string {
+	runes := []rune(name)
+	if len(runes) == 0 {
+		return DEFAULT_USER_NAME
+	}
+	if len(runes) > 20 {
+		return string(runes[:20])
+	}
+	return name
+}
+
+func GetAuthProvidersHandler(ap AuthProviders, isPasswordEnabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		names := make([]string, 0, len(ap)+1)
+		if isPasswordEnabled {
+			names = append(names, PASSWORD_PROVIDER)
+		}
+		for name := range ap {
+			names = append(names, name)
+		}
+		c.JSON(http.StatusOK, names)
+	}
+}
+
+func BeginAuthHandler(ap AuthProviders) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provider, httpErr := ap.get(c)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		authUrl, err := provider.BeginAuth(c)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.Redirect(http.StatusFound, authUrl)
+	}
+}
+
+// Logs the user in with the third party identity, creating a new user if needed.
+// When the request already carries a valid session the identity is linked to its user instead
+func AuthCallbackHandler(mdb *mongo.Database, rds *redis.Client, so SessionOptions, ap AuthProviders, clientOrigin string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provider, httpErr := ap.get(c)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		authResult, httpErr := provider.Authenticate(c)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		linkedUser, httpErr := entities.GetDbUserByIdentity(mdb, authResult.Identity)
+		if httpErr != nil && httpErr.Status() != http.StatusNotFound {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		var currentSession *Session
+		sessionId, err := c.Cookie(SESSION_ID_COOKIE_NAME)
+		if err == nil {
+			currentSession, err = GetSession(rds, sessionId)
+			if err != nil && !errors.Is(err, redis.Nil) {
+				custErrors.AbortWithInternalError(c, err)
+				return
+			}
+		}
+
+		if currentSession != nil {
+			if linkedUser != nil {
+				if linkedUser.Id != currentSession.UserId {
+					custErrors.AbortWithError(c, custErrors.NewHttpError(
+						http.StatusConflict,
+						custErrors.IdentityTaken,
+					))
+					return
+				}
+				c.Redirect(http.StatusFound, clientOrigin)
+				return
+			}
+
+			// one identity per provider, identities are unlinked by provider
+			filter := bson.M{
+				"_id":                 currentSession.UserId,
+				"identities.provider": bson.M{"$ne": authResult.Identity.Provider},
+			}
+			update := bson.M{"$push": bson.M{"identities": authResult.Identity}}
+			if currentSession.IsGuest {
+				update["$set"] = bson.M{"isGuest": false}
+				update["$unset"] = bson.M{"expiresAt": ""}
+			}
+			res, err := mdb.Collection(entities.USERS_COLLECTION).UpdateOne(context.TODO(), filter, update)
+			if err != nil {
+				custErrors.AbortWithInternalError(c, err)
+				return
+			}
+			if res.MatchedCount == 0 {
+				custErrors.AbortWithError(c, custErrors.NewHttpError(
+					http.StatusConflict,
+					custErrors.ProviderAlreadyLinked,
+					authResult.Identity.Provider,
+				))
+				return
+			}
+			if currentSession.IsGuest {
+				if err := RenewSession(rds, so, sessionId, currentSession.UserId.Hex()); err != nil {
+					if errors.Is(err, redis.Nil) {
+						custErrors.AbortWithError(c, custErrors.NewHttpError(
+							http.StatusUnauthorized,
+							custErrors.InvalidSession,
+						))
+						return
+					}
+					custErrors.AbortWithInternalError(c, err)
+					return
+				}
+				so.SetCookie(c, sessionId)
+			}
+
+			c.Redirect(http.StatusFound, clientOrigin)
+			return
+		}
+
+		var userId primitive.ObjectID
+		if linkedUser != nil {
+			if linkedUser.IsSuspended() {
+				custErrors.AbortWithError(c, linkedUser.SuspensionError())
+				return
+			}
+			userId = linkedUser.Id
+		} else {
+			dbUser := &entities.DbUser{
+				User: entities.User{
+					Name:   truncateName(authResult.Name),
+					Avatar: authResult.Avatar,
+				},
+				Identities: []entities.Identity{authResult.Identity},
+			}
+			res, err := mdb.Collection(entities.USERS_COLLECTION).InsertOne(context.TODO(), dbUser)
+			if err != nil {
+				custErrors.AbortWithInternalError(c, err)
+				return
+			}
+			userId = res.InsertedID.(primitive.ObjectID)
+		}
+
+		sessionId, err = SetSession(rds, so, userId.Hex(), c.Request.UserAgent(), false)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		so.SetCookie(c, sessionId)
+		c.Redirect(http.StatusFound, clientOrigin)
+	}
+}
+
+func GetIdentitiesHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		dbUser, httpErr := entities.GetDbUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		identities := make([]entities.Identity, 0, len(dbUser.Identities)+1)
+		if dbUser.Login != "" {
+			identities = append(identities, entities.Identity{Provider: PASSWORD_PROVIDER, Subject: dbUser.Login})
+		}
+		identities = append(identities, dbUser.Identities...)
+
+		c.JSON(http.StatusOK, identities)
+	}
+}
+
+func UnlinkIdentityHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+		providerName := c.Param(PROVIDER_PARAM)
+
+		dbUser, httpErr := entities.GetDbUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		// login methods left after unlinking, users linked before the one identity per provider rule may have several
+		remainingCount := 0
+		for _, identity := range dbUser.Identities {
+			if identity.Provider != providerName {
+				remainingCount++
+			}
+		}
+		if dbUser.Login != "" && providerName != PASSWORD_PROVIDER {
+			remainingCount++
+		}
+		if remainingCount == 0 {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusConflict,
+				custErrors.LastIdentity,
+			))
+			return
+		}
+
+		var update bson.M
+		if providerName == PASSWORD_PROVIDER {
+			update = bson.M{"$unset": bson.M{"login": "", "password": ""}}
+		} else {
+			update = bson.M{"$pull": bson.M{"identities": bson.M{"provider": providerName}}}
+		}
+
+		res, err := mdb.Collection(entities.USERS_COLLECTION).UpdateByID(context.TODO(), userId, update)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if res.ModifiedCount == 0 {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusNotFound,
+				custErrors.IdentityNotFound,
+			))
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
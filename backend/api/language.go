@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/i18n"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const USER_LANGUAGE_PREFIX = "userLanguage:"
+
+// The preference is needed by every request, it is cached not to load the user each time
+const USER_LANGUAGE_TTL = 24 * time.Hour
+
+func GetUserLanguageRedisKey(userId string) string {
+	return USER_LANGUAGE_PREFIX + userId
+}
+
+// Language chosen by the user, empty if there is no preference
+func GetUserLanguage(mdb *mongo.Database, rds *redis.Client, userId primitive.ObjectID) (i18n.Language, error) {
+	cached, err := rds.Get(context.TODO(), GetUserLanguageRedisKey(userId.Hex())).Result()
+	if err == nil {
+		return i18n.Language(cached), nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		return "", err
+	}
+
+	var dbUser entities.DbUser
+	err = mdb.Collection(entities.USERS_COLLECTION).FindOne(
+		context.TODO(),
+		bson.M{"_id": userId},
+		options.FindOne().SetProjection(bson.M{"language": 1}),
+	).Decode(&dbUser)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return "", err
+	}
+
+	err = rds.Set(context.TODO(), GetUserLanguageRedisKey(userId.Hex()), string(dbUser.Language), USER_LANGUAGE_TTL).Err()
+	return dbUser.Language, err
+}
+
+// Requests of users without a preference keep the language negotiated by Accept-Language
+func setPreferredLanguage(c *gin.Context, mdb *mongo.Database, rds *redis.Client) {
+	userId := c.MustGet(USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+	lang, err := GetUserLanguage(mdb, rds, userId)
+	if err != nil {
+		log.Println("Error while getting user language:", err)
+		return
+	}
+	if lang != "" {
+		c.Set(i18n.LANGUAGE_CONTEXT_KEY, lang)
+	}
+}
+
+func GetLanguageHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		lang, err := GetUserLanguage(mdb, rds, userId)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, entities.LanguageDTO{Language: lang})
+	}
+}
+
+func SetLanguageHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		var languageDTO entities.LanguageDTO
+		if err := c.ShouldBindJSON(&languageDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+
+		update := bson.M{"$set": bson.M{"language": languageDTO.Language}}
+		if languageDTO.Language == "" {
+			update = bson.M{"$unset": bson.M{"language": ""}}
+		}
+		res, err := mdb.Collection(entities.USERS_COLLECTION).UpdateByID(context.TODO(), userId, update)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if res.MatchedCount == 0 {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusNotFound,
+				custErrors.UserNotFound, userId.Hex(),
+			))
+			return
+		}
+
+		err = rds.Set(context.TODO(), GetUserLanguageRedisKey(userId.Hex()), string(languageDTO.Language), USER_LANGUAGE_TTL).Err()
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, languageDTO)
+	}
+}
@@ -0,0 +1,379 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+)
+
+const OIDC_STATE_COOKIE_NAME = "oidcState"
+const OIDC_NONCE_COOKIE_NAME = "oidcNonce"
+const OIDC_COOKIE_MAX_AGE = 10 * 60
+
+var ErrInvalidIdToken = errors.New("invalid id token")
+
+type OIDCConfig struct {
+	Name         string
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+	Scopes       []string
+	// Options of the session cookie, state and nonce cookies are set with them too
+	Cookie CookieOptions
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type idTokenClaims struct {
+	Issuer            string          `json:"iss"`
+	Subject           string          `json:"sub"`
+	Audience          json.RawMessage `json:"aud"`
+	ExpiresAt         int64           `json:"exp"`
+	Nonce             string          `json:"nonce"`
+	Name              string          `json:"name"`
+	PreferredUsername string          `json:"preferred_username"`
+	Email             string          `json:"email"`
+	Picture           string          `json:"picture"`
+}
+
+// Generic OpenID Connect provider using the authorization code flow.
+// Only RS256 signed id tokens are accepted
+type OIDCProvider struct {
+	config     OIDCConfig
+	discovery  oidcDiscovery
+	httpClient *http.Client
+
+	keysMutex sync.Mutex
+	keys      map[string]*rsa.PublicKey
+}
+
+func NewOIDCProvider(ctx context.Context, config OIDCConfig) (*OIDCProvider, error) {
+	op := &OIDCProvider{
+		config:     config,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		keys:       make(map[string]*rsa.PublicKey),
+	}
+	if len(op.config.Scopes) == 0 {
+		op.config.Scopes = []string{"openid", "profile", "email"}
+	}
+
+	discoveryUrl := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := op.getJSON(ctx, discoveryUrl, &op.discovery); err != nil {
+		return nil, err
+	}
+	if op.discovery.Issuer != config.Issuer {
+		return nil, fmt.Errorf("issuer mismatch: expected \"%s\", got \"%s\"", config.Issuer, op.discovery.Issuer)
+	}
+
+	return op, nil
+}
+
+func (op *OIDCProvider) Name() string {
+	return op.config.Name
+}
+
+func (op *OIDCProvider) BeginAuth(c *gin.Context) (string, error) {
+	state, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+
+	op.setCookie(c, OIDC_STATE_COOKIE_NAME, state.String(), OIDC_COOKIE_MAX_AGE)
+	op.setCookie(c, OIDC_NONCE_COOKIE_NAME, nonce.String(), OIDC_COOKIE_MAX_AGE)
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", op.config.ClientId)
+	query.Set("redirect_uri", op.config.RedirectUrl)
+	query.Set("scope", strings.Join(op.config.Scopes, " "))
+	query.Set("state", state.String())
+	query.Set("nonce", nonce.String())
+
+	authUrl, err := url.Parse(op.discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	authUrl.RawQuery = query.Encode()
+	return authUrl.String(), nil
+}
+
+func (op *OIDCProvider) Authenticate(c *gin.Context) (*AuthResult, custErrors.HttpError) {
+	if errParam := c.Query("error"); errParam != "" {
+		return nil, custErrors.NewHttpError(
+			http.StatusUnauthorized,
+			custErrors.AuthenticationRefused, errParam, c.Query("error_description"),
+		)
+	}
+
+	state, err := c.Cookie(OIDC_STATE_COOKIE_NAME)
+	if err != nil || state != c.Query("state") {
+		return nil, custErrors.NewHttpError(
+			http.StatusBadRequest,
+			custErrors.InvalidOidcState,
+		)
+	}
+	nonce, err := c.Cookie(OIDC_NONCE_COOKIE_NAME)
+	if err != nil {
+		return nil, custErrors.NewHttpError(
+			http.StatusBadRequest,
+			custErrors.MissingOidcNonce,
+		)
+	}
+	op.setCookie(c, OIDC_STATE_COOKIE_NAME, "", -1)
+	op.setCookie(c, OIDC_NONCE_COOKIE_NAME, "", -1)
+
+	rawIdToken, err := op.exchangeCode(c.Request.Context(), c.Query("code"))
+	if err != nil {
+		return nil, custErrors.NewHttpError(
+			http.StatusUnauthorized,
+			custErrors.AuthenticationFailed, err.Error(),
+		)
+	}
+
+	claims, err := op.verifyIdToken(c.Request.Context(), rawIdToken, nonce)
+	if err != nil {
+		return nil, custErrors.NewHttpError(
+			http.StatusUnauthorized,
+			custErrors.AuthenticationFailed, err.Error(),
+		)
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.PreferredUsername
+	}
+	if name == "" {
+		name, _, _ = strings.Cut(claims.Email, "@")
+	}
+	var avatar *string
+	if claims.Picture != "" {
+		avatar = &claims.Picture
+	}
+
+	return &AuthResult{
+		Identity: entities.Identity{Provider: op.config.Name, Subject: claims.Subject},
+		Name:     name,
+		Avatar:   avatar,
+	}, nil
+}
+
+func (op *OIDCProvider) setCookie(c *gin.Context, name string, value string, maxAge int) {
+	sameSite := op.config.Cookie.SameSite
+	// the callback is a cross site redirect from the issuer, strict cookies are not sent with it
+	if sameSite == http.SameSiteStrictMode {
+		sameSite = http.SameSiteLaxMode
+	}
+	c.SetSameSite(sameSite)
+	c.SetCookie(name, value, maxAge, "/", op.config.Cookie.Domain, op.config.Cookie.Secure, true)
+}
+
+func (op *OIDCProvider) exchangeCode(ctx context.Context, code string) (string, error) {
+	if code == "" {
+		return "", errors.New("missing authorization code")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", op.config.RedirectUrl)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, op.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(op.config.ClientId), url.QueryEscape(op.config.ClientSecret))
+
+	resp, err := op.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint responded with status %d", resp.StatusCode)
+	}
+
+	var tokenResponse struct {
+		IdToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return "", err
+	}
+	if tokenResponse.IdToken == "" {
+		return "", errors.New("token endpoint did not return id_token")
+	}
+	return tokenResponse.IdToken, nil
+}
+
+func (op *OIDCProvider) verifyIdToken(ctx context.Context, rawIdToken string, nonce string) (*idTokenClaims, error) {
+	parts := strings.Split(rawIdToken, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidIdToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported alg \"%s\"", ErrInvalidIdToken, header.Alg)
+	}
+
+	key, err := op.getKey(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidIdToken
+	}
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature); err != nil {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidIdToken)
+	}
+
+	var claims idTokenClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if claims.Issuer != op.discovery.Issuer {
+		return nil, fmt.Errorf("%w: wrong issuer", ErrInvalidIdToken)
+	}
+	if !audienceContains(claims.Audience, op.config.ClientId) {
+		return nil, fmt.Errorf("%w: wrong audience", ErrInvalidIdToken)
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidIdToken)
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: wrong nonce", ErrInvalidIdToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIdToken)
+	}
+
+	return &claims, nil
+}
+
+func (op *OIDCProvider) getKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	op.keysMutex.Lock()
+	defer op.keysMutex.Unlock()
+
+	if key, ok := op.keys[kid]; ok {
+		return key, nil
+	}
+
+	// unknown kid means keys were rotated, so refetch them
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := op.getJSON(ctx, op.discovery.JwksUri, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	op.keys = keys
+
+	key, ok := op.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key \"%s\"", ErrInvalidIdToken, kid)
+	}
+	return key, nil
+}
+
+func (op *OIDCProvider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := op.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func decodeJWTPart(part string, v any) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return ErrInvalidIdToken
+	}
+	if err := json.Unmarshal(decoded, v); err != nil {
+		return ErrInvalidIdToken
+	}
+	return nil
+}
+
+func audienceContains(rawAudience json.RawMessage, clientId string) bool {
+	var single string
+	if err := json.Unmarshal(rawAudience, &single); err == nil {
+		return single == clientId
+	}
+	var multiple []string
+	if err := json.Unmarshal(rawAudience, &multiple); err == nil {
+		return slices.Contains(multiple, clientId)
+	}
+	return false
+}
@@ -0,0 +1,263 @@
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/custErrors"
+)
+
+const (
+	testClientId     = "sgame"
+	testClientSecret = "secret"
+	testRedirectUrl  = "http://localhost/auth/oidc/callback"
+	testCode         = "code"
+	testKid          = "key-1"
+	testState        = "state"
+	testNonce        = "nonce"
+)
+
+// Issuer serving discovery, JWKS and a token endpoint that returns idToken for testCode
+type mockIssuer struct {
+	server  *httptest.Server
+	key     *rsa.PrivateKey
+	idToken string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi := &mockIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                mi.server.URL,
+			AuthorizationEndpoint: mi.server.URL + "/authorize",
+			TokenEndpoint:         mi.server.URL + "/token",
+			JwksUri:               mi.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string][]jsonWebKey{"keys": {{
+			Kid: testKid,
+			Kty: "RSA",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientId, clientSecret, ok := r.BasicAuth()
+		if !ok || clientId != testClientId || clientSecret != testClientSecret {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.PostFormValue("code") != testCode || r.PostFormValue("redirect_uri") != testRedirectUrl {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": mi.idToken})
+	})
+	mi.server = httptest.NewServer(mux)
+	t.Cleanup(mi.server.Close)
+	return mi
+}
+
+func (mi *mockIssuer) validClaims() map[string]any {
+	return map[string]any{
+		"iss":   mi.server.URL,
+		"sub":   "subject",
+		"aud":   testClientId,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": testNonce,
+		"name":  "Name",
+	}
+}
+
+func signIdToken(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": testKid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hashed := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newTestProvider(t *testing.T, mi *mockIssuer, cookie CookieOptions) *OIDCProvider {
+	t.Helper()
+	op, err := NewOIDCProvider(context.Background(), OIDCConfig{
+		Name:         "oidc",
+		Issuer:       mi.server.URL,
+		ClientId:     testClientId,
+		ClientSecret: testClientSecret,
+		RedirectUrl:  testRedirectUrl,
+		Cookie:       cookie,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return op
+}
+
+func newCallbackContext(state string) *gin.Context {
+	query := url.Values{}
+	query.Set("code", testCode)
+	query.Set("state", state)
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+query.Encode(), nil)
+	req.AddCookie(&http.Cookie{Name: OIDC_STATE_COOKIE_NAME, Value: testState})
+	req.AddCookie(&http.Cookie{Name: OIDC_NONCE_COOKIE_NAME, Value: testNonce})
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	return c
+}
+
+func TestNewOIDCProviderIssuerMismatch(t *testing.T) {
+	mi := newMockIssuer(t)
+	_, err := NewOIDCProvider(context.Background(), OIDCConfig{Issuer: mi.server.URL + "/"})
+	if err == nil {
+		t.Fatal("expected issuer mismatch error")
+	}
+}
+
+func TestBeginAuth(t *testing.T) {
+	mi := newMockIssuer(t)
+	op := newTestProvider(t, mi, CookieOptions{Domain: "example.com", Secure: true, SameSite: http.SameSiteStrictMode})
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodGet, "/auth/oidc", nil)
+	authUrl, err := op.BeginAuth(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(authUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("client_id") != testClientId || query.Get("redirect_uri") != testRedirectUrl {
+		t.Errorf("unexpected authorization url %s", authUrl)
+	}
+
+	cookies := make(map[string]*http.Cookie)
+	for _, cookie := range rec.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	for name, param := range map[string]string{OIDC_STATE_COOKIE_NAME: "state", OIDC_NONCE_COOKIE_NAME: "nonce"} {
+		cookie, ok := cookies[name]
+		if !ok {
+			t.Fatalf("cookie %s is not set", name)
+		}
+		if cookie.Value != query.Get(param) {
+			t.Errorf("cookie %s is \"%s\", %s param is \"%s\"", name, cookie.Value, param, query.Get(param))
+		}
+		if !cookie.Secure || !cookie.HttpOnly || cookie.Domain != "example.com" {
+			t.Errorf("cookie %s does not follow the cookie options: %+v", name, cookie)
+		}
+		if cookie.SameSite != http.SameSiteLaxMode {
+			t.Errorf("cookie %s must be lax to survive the redirect from the issuer, got %v", name, cookie.SameSite)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	mi := newMockIssuer(t)
+	op := newTestProvider(t, mi, CookieOptions{})
+	mi.idToken = signIdToken(t, mi.key, mi.validClaims())
+
+	result, httpErr := op.Authenticate(newCallbackContext(testState))
+	if httpErr != nil {
+		t.Fatal(httpErr)
+	}
+	if result.Identity.Provider != "oidc" || result.Identity.Subject != "subject" || result.Name != "Name" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestAuthenticateAudienceList(t *testing.T) {
+	mi := newMockIssuer(t)
+	op := newTestProvider(t, mi, CookieOptions{})
+	claims := mi.validClaims()
+	claims["aud"] = []string{"other", testClientId}
+	mi.idToken = signIdToken(t, mi.key, claims)
+
+	if _, httpErr := op.Authenticate(newCallbackContext(testState)); httpErr != nil {
+		t.Fatal(httpErr)
+	}
+}
+
+func TestAuthenticateStateMismatch(t *testing.T) {
+	mi := newMockIssuer(t)
+	op := newTestProvider(t, mi, CookieOptions{})
+	mi.idToken = signIdToken(t, mi.key, mi.validClaims())
+
+	_, httpErr := op.Authenticate(newCallbackContext("other state"))
+	if httpErr == nil || httpErr.ErrorCode() != custErrors.InvalidOidcState {
+		t.Fatalf("expected %s error, got %v", custErrors.InvalidOidcState, httpErr)
+	}
+}
+
+func TestAuthenticateInvalidIdToken(t *testing.T) {
+	mi := newMockIssuer(t)
+	op := newTestProvider(t, mi, CookieOptions{})
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		key    *rsa.PrivateKey
+		modify func(claims map[string]any)
+	}{
+		{"signature", otherKey, func(claims map[string]any) {}},
+		{"issuer", mi.key, func(claims map[string]any) { claims["iss"] = "http://other" }},
+		{"audience", mi.key, func(claims map[string]any) { claims["aud"] = "other" }},
+		{"expired", mi.key, func(claims map[string]any) { claims["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{"nonce", mi.key, func(claims map[string]any) { claims["nonce"] = "other nonce" }},
+		{"subject", mi.key, func(claims map[string]any) { delete(claims, "sub") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := mi.validClaims()
+			tt.modify(claims)
+			mi.idToken = signIdToken(t, tt.key, claims)
+
+			_, httpErr := op.Authenticate(newCallbackContext(testState))
+			if httpErr == nil || httpErr.ErrorCode() != custErrors.AuthenticationFailed {
+				t.Fatalf("expected %s error, got %v", custErrors.AuthenticationFailed, httpErr)
+			}
+			if httpErr.Status() != http.StatusUnauthorized {
+				t.Errorf("expected status %d, got %d", http.StatusUnauthorized, httpErr.Status())
+			}
+		})
+	}
+}
@@ -0,0 +1,500 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/i18n"
+	"github.com/holdennekt/sgame/media"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const BANK_QUESTIONS_LIMIT = 5000
+
+// Packs affected by the change of a bank question
+type bankSyncResult struct {
+	Updated []primitive.ObjectID `json:"updated"`
+	// Packs that pinned the question and kept the old content
+	Pinned []primitive.ObjectID `json:"pinned"`
+	// Packs that could not be saved, by their id
+	Failed map[string]custErrors.ErrorResponse `json:"failed"`
+}
+
+func CreateBankQuestionHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		var bankQuestionDTO entities.BankQuestionDTO
+		if err := c.ShouldBindJSON(&bankQuestionDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+		bankQuestionDTO.Normalize()
+
+		count, err := mdb.Collection(entities.BANK_QUESTIONS_COLLECTION).CountDocuments(
+			context.TODO(),
+			bson.M{"ownerId": userId},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if count >= BANK_QUESTIONS_LIMIT {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusConflict,
+				custErrors.BankFull, BANK_QUESTIONS_LIMIT,
+			))
+			return
+		}
+
+		now := time.Now()
+		bankQuestion := entities.BankQuestion{
+			OwnerId:         userId,
+			Version:         1,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+			BankQuestionDTO: bankQuestionDTO,
+		}
+		res, err := mdb.Collection(entities.BANK_QUESTIONS_COLLECTION).InsertOne(context.TODO(), bankQuestion)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		bankQuestion.Id = res.InsertedID.(primitive.ObjectID)
+
+		err = ms.UpdateAttachmentRefs(context.TODO(), nil, []*entities.Attachment{bankQuestion.Attachment})
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, bankQuestion)
+	}
+}
+
+func GetBankQuestionsHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		pr, httpErr := parsePageRequest(c, false)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+		if pr.sort != SortNewest {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.NotOneOf, "sort", SortNewest,
+			))
+			return
+		}
+
+		filter := bson.M{"ownerId": userId}
+		// the bank is personal and small enough to be searched without a text index
+		conditions := bson.A{}
+		for _, term := range searchTerms(c.Query(FILTER_QUERY_PARAM)) {
+			pattern := primitive.Regex{Pattern: regexp.QuoteMeta(term), Options: "i"}
+			conditions = append(conditions, bson.M{"$or": bson.A{
+				bson.M{"text": pattern},
+				bson.M{"answers": pattern},
+			}})
+		}
+		if len(conditions) > 0 {
+			filter["$and"] = conditions
+		}
+		if tagsParam := c.Query(TAGS_QUERY_PARAM); tagsParam != "" {
+			tags := make([]string, 0)
+			for _, tag := range strings.Split(tagsParam, ",") {
+				if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+					tags = append(tags, tag)
+				}
+			}
+			if len(tags) > 0 {
+				filter["tags"] = bson.M{"$all": tags}
+			}
+		}
+		if pr.cursor != nil {
+			filter["_id"] = bson.M{"$lt": pr.cursor.Id}
+		}
+
+		res, err := mdb.Collection(entities.BANK_QUESTIONS_COLLECTION).Find(
+			context.TODO(),
+			filter,
+			options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(pr.limit+1),
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		bankQuestions := make([]entities.BankQuestion, 0)
+		if err := res.All(context.TODO(), &bankQuestions); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		var nextCursor *string
+		if int64(len(bankQuestions)) > pr.limit {
+			bankQuestions = bankQuestions[:pr.limit]
+			encoded := cursor{Sort: SortNewest, Id: bankQuestions[len(bankQuestions)-1].Id}.encode()
+			nextCursor = &encoded
+		}
+
+		c.JSON(http.StatusOK, Page[entities.BankQuestion]{Items: bankQuestions, NextCursor: nextCursor})
+	}
+}
+
+func GetBankQuestionHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bankQuestion, ok := getOwnBankQuestion(c, mdb)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, bankQuestion)
+	}
+}
+
+// Saves the new content of the question and brings it to every pack that uses it and has not pinned it
+func UpdateBankQuestionHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		user, httpErr := entities.GetUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		bankQuestion, ok := getOwnBankQuestion(c, mdb)
+		if !ok {
+			return
+		}
+
+		var bankQuestionDTO entities.BankQuestionDTO
+		if err := c.ShouldBindJSON(&bankQuestionDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+		bankQuestionDTO.Normalize()
+
+		oldAttachment := bankQuestion.Attachment
+		oldVersion := bankQuestion.Version
+		bankQuestion.BankQuestionDTO = bankQuestionDTO
+		bankQuestion.Version++
+		bankQuestion.UpdatedAt = time.Now()
+
+		res, err := mdb.Collection(entities.BANK_QUESTIONS_COLLECTION).ReplaceOne(
+			context.TODO(),
+			bson.M{"_id": bankQuestion.Id, "version": oldVersion},
+			bankQuestion,
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if res.MatchedCount == 0 {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusConflict,
+				custErrors.BankQuestionChanged,
+			))
+			return
+		}
+
+		err = ms.UpdateAttachmentRefs(
+			context.TODO(),
+			[]*entities.Attachment{oldAttachment},
+			[]*entities.Attachment{bankQuestion.Attachment},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		result, err := syncBankQuestion(mdb, ms, *user, bankQuestion, i18n.FromContext(c))
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"question": bankQuestion, "packs": result})
+	}
+}
+
+// Packs keep their copies of the question, only the references are removed
+func DeleteBankQuestionHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bankQuestion, ok := getOwnBankQuestion(c, mdb)
+		if !ok {
+			return
+		}
+
+		_, err := mdb.Collection(entities.BANK_QUESTIONS_COLLECTION).DeleteOne(
+			context.TODO(),
+			bson.D{{Key: "_id", Value: bankQuestion.Id}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		err = ms.UpdateAttachmentRefs(context.TODO(), []*entities.Attachment{bankQuestion.Attachment}, nil)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		if err := unlinkBankQuestions(mdb, []primitive.ObjectID{bankQuestion.Id}); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func getOwnBankQuestion(c *gin.Context, mdb *mongo.Database) (*entities.BankQuestion, bool) {
+	userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+	objId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		custErrors.AbortWithError(c, custErrors.NewHttpError(
+			http.StatusBadRequest,
+			custErrors.InvalidId, "questionId",
+		))
+		return nil, false
+	}
+
+	bankQuestion, httpErr := entities.GetBankQuestion(mdb, objId)
+	if httpErr != nil {
+		custErrors.AbortWithError(c, httpErr)
+		return nil, false
+	}
+
+	// the bank is personal, someone else's questions are as good as missing
+	if bankQuestion.OwnerId != userId {
+		custErrors.AbortWithError(c, custErrors.NewHttpError(
+			http.StatusNotFound,
+			custErrors.BankQuestionNotFound, objId.Hex(),
+		))
+		return nil, false
+	}
+
+	return bankQuestion, true
+}
+
+// Fills questions taken from the bank with the content of their bank questions, pinned ones
+// keep the content they have. New references must be to the user's own bank, the ones in
+// referenced are the pack already had and may belong to any user
+func resolveBankRefs(
+	mdb *mongo.Database,
+	userId primitive.ObjectID,
+	packDTO *entities.PackDTO,
+	referenced map[primitive.ObjectID]bool,
+) custErrors.HttpError {
+	ids := make([]primitive.ObjectID, 0)
+	for id := range packDTO.BankQuestionIds() {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	res, err := mdb.Collection(entities.BANK_QUESTIONS_COLLECTION).Find(
+		context.TODO(),
+		bson.M{"_id": bson.M{"$in": ids}},
+	)
+	if err != nil {
+		return custErrors.NewInternalError(err)
+	}
+	found := make([]entities.BankQuestion, 0, len(ids))
+	if err := res.All(context.TODO(), &found); err != nil {
+		return custErrors.NewInternalError(err)
+	}
+	bankQuestions := make(map[primitive.ObjectID]*entities.BankQuestion, len(found))
+	for i := range found {
+		bankQuestions[found[i].Id] = &found[i]
+	}
+
+	for _, question := range packDTO.BankQuestions() {
+		ref := question.BankRef
+		bankQuestion, ok := bankQuestions[ref.QuestionId]
+		if !ok || bankQuestion.OwnerId != userId && !referenced[ref.QuestionId] {
+			return custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.BankQuestionNotFound, ref.QuestionId.Hex(),
+			)
+		}
+		if ref.Pinned && ref.Version > 0 {
+			continue
+		}
+		bankQuestion.ApplyTo(question)
+	}
+	return nil
+}
+
+// Saves a new version of every pack using the bank question, unless the pack pinned it
+// or the owner of the question can no longer edit the pack
+func syncBankQuestion(
+	mdb *mongo.Database,
+	ms *media.Service,
+	savedBy entities.User,
+	bankQuestion *entities.BankQuestion,
+	lang i18n.Language,
+) (*bankSyncResult, error) {
+	res, err := mdb.Collection(entities.PACKS_COLLECTION).Find(
+		context.TODO(),
+		bson.M{"rounds.categories.questions.bankRef.questionId": bankQuestion.Id},
+	)
+	if err != nil {
+		return nil, err
+	}
+	packs := make([]entities.Pack, 0)
+	if err := res.All(context.TODO(), &packs); err != nil {
+		return nil, err
+	}
+
+	result := &bankSyncResult{
+		Updated: make([]primitive.ObjectID, 0),
+		Pinned:  make([]primitive.ObjectID, 0),
+		Failed:  make(map[string]custErrors.ErrorResponse),
+	}
+	for i := range packs {
+		pack := &packs[i]
+		if pack.Status == "" {
+			pack.Status = entities.Published
+		}
+
+		packDTO := pack.PackDTO.Clone()
+		changed, pinned := false, false
+		for _, question := range packDTO.BankQuestions() {
+			if question.BankRef.QuestionId != bankQuestion.Id {
+				continue
+			}
+			if question.BankRef.Pinned {
+				pinned = true
+				continue
+			}
+			bankQuestion.ApplyTo(question)
+			changed = true
+		}
+
+		switch {
+		case changed && !pack.HasRole(savedBy.Id, entities.EditorRole):
+			httpErr := custErrors.NewHttpError(http.StatusForbidden, custErrors.PackUpdateDenied)
+			correlationId := custErrors.NewCorrelationId()
+			custErrors.LogError(correlationId, httpErr)
+			result.Failed[pack.Id.Hex()] = httpErr.Response(lang, correlationId)
+		case changed:
+			if httpErr := savePackVersion(mdb, ms, pack, savedBy, packDTO, nil); httpErr != nil {
+				correlationId := custErrors.NewCorrelationId()
+				custErrors.LogError(correlationId, httpErr)
+				result.Failed[pack.Id.Hex()] = httpErr.Response(lang, correlationId)
+				continue
+			}
+			result.Updated = append(result.Updated, pack.Id)
+		case pinned:
+			result.Pinned = append(result.Pinned, pack.Id)
+		}
+	}
+	return result, nil
+}
+
+// Removes references to the bank questions from packs, the questions themselves stay
+func unlinkBankQuestions(mdb *mongo.Database, ids []primitive.ObjectID) error {
+	return unlinkPacksBankQuestions(mdb, bson.M{}, ids)
+}
+
+// Removes references of the pack to bank questions of the user who can no longer edit it,
+// so that later changes of the questions do not reach the pack. The pack keeps its copies
+func unlinkCollaboratorBankQuestions(mdb *mongo.Database, pack *entities.Pack, userId primitive.ObjectID) error {
+	ids := make([]primitive.ObjectID, 0)
+	for id := range pack.PackDTO.BankQuestionIds() {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	res, err := mdb.Collection(entities.BANK_QUESTIONS_COLLECTION).Find(
+		context.TODO(),
+		bson.M{"_id": bson.M{"$in": ids}, "ownerId": userId},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	owned := make([]entities.BankQuestion, 0)
+	if err := res.All(context.TODO(), &owned); err != nil {
+		return err
+	}
+	if len(owned) == 0 {
+		return nil
+	}
+
+	ownedIds := make([]primitive.ObjectID, len(owned))
+	for i, bankQuestion := range owned {
+		ownedIds[i] = bankQuestion.Id
+	}
+	return unlinkPacksBankQuestions(mdb, bson.M{"_id": pack.Id}, ownedIds)
+}
+
+func unlinkPacksBankQuestions(mdb *mongo.Database, filter bson.M, ids []primitive.ObjectID) error {
+	filter["rounds.categories.questions.bankRef.questionId"] = bson.M{"$in": ids}
+	_, err := mdb.Collection(entities.PACKS_COLLECTION).UpdateMany(
+		context.TODO(),
+		filter,
+		bson.M{"$unset": bson.M{"rounds.$[].categories.$[].questions.$[question].bankRef": ""}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []any{bson.M{"question.bankRef.questionId": bson.M{"$in": ids}}},
+		}),
+	)
+	return err
+}
+
+// Deletes the whole bank of the user, packs keep their copies of the questions
+func deleteBankQuestions(mdb *mongo.Database, ms *media.Service, ownerId primitive.ObjectID) error {
+	res, err := mdb.Collection(entities.BANK_QUESTIONS_COLLECTION).Find(
+		context.TODO(),
+		bson.M{"ownerId": ownerId},
+		options.Find().SetProjection(bson.M{"_id": 1, "attachment": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	bankQuestions := make([]entities.BankQuestion, 0)
+	if err := res.All(context.TODO(), &bankQuestions); err != nil {
+		return err
+	}
+	if len(bankQuestions) == 0 {
+		return nil
+	}
+
+	ids := make([]primitive.ObjectID, len(bankQuestions))
+	attachments := make([]*entities.Attachment, len(bankQuestions))
+	for i, bankQuestion := range bankQuestions {
+		ids[i] = bankQuestion.Id
+		attachments[i] = bankQuestion.Attachment
+	}
+
+	_, err = mdb.Collection(entities.BANK_QUESTIONS_COLLECTION).DeleteMany(
+		context.TODO(),
+		bson.M{"_id": bson.M{"$in": ids}},
+	)
+	if err != nil {
+		return err
+	}
+	if err := ms.UpdateAttachmentRefs(context.TODO(), attachments, nil); err != nil {
+		return err
+	}
+	return unlinkBankQuestions(mdb, ids)
+}
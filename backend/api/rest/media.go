@@ -0,0 +1,139 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/media"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const MEDIA_FORM_FIELD = "file"
+const MEDIA_TYPE_FORM_FIELD = "mediaType"
+
+// Room for the boundaries, part headers and other fields of multipart form
+const MULTIPART_OVERHEAD = 1 << 20
+
+// Reads the file from multipart form. The body is limited before parsing the form,
+// so that larger files are not spooled to disk just to be rejected
+func getFormFile(c *gin.Context, field string, name string, maxSize int64, tooLarge custErrors.HttpError) (*multipart.FileHeader, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+MULTIPART_OVERHEAD)
+	fileHeader, err := c.FormFile(field)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			custErrors.AbortWithError(c, tooLarge)
+			return nil, false
+		}
+		custErrors.AbortWithError(c, custErrors.NewHttpError(
+			http.StatusBadRequest,
+			custErrors.FileRequired, name,
+		))
+		return nil, false
+	}
+	return fileHeader, true
+}
+
+func abortWithMediaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, media.ErrMediaTooLarge):
+		custErrors.AbortWithError(c, custErrors.NewHttpError(
+			http.StatusRequestEntityTooLarge,
+			custErrors.MediaTooLarge,
+		).WithDetail(MEDIA_FORM_FIELD, err.Error()))
+	case errors.Is(err, media.ErrUnsupportedMedia):
+		custErrors.AbortWithError(c, custErrors.NewHttpError(
+			http.StatusUnsupportedMediaType,
+			custErrors.UnsupportedMedia,
+		).WithDetail(MEDIA_FORM_FIELD, err.Error()))
+	default:
+		custErrors.AbortWithInternalError(c, err)
+	}
+}
+
+func UploadMediaHandler(ms *media.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		maxSize := ms.MaxSize()
+		fileHeader, ok := getFormFile(c, MEDIA_FORM_FIELD, "media", maxSize, custErrors.NewHttpError(
+			http.StatusRequestEntityTooLarge,
+			custErrors.MediaTooLarge,
+		).WithDetail(MEDIA_FORM_FIELD, fmt.Sprintf("must be at most %d bytes", maxSize)))
+		if !ok {
+			return
+		}
+		declaredType := entities.MediaType(c.PostForm(MEDIA_TYPE_FORM_FIELD))
+		switch declaredType {
+		case "", entities.Image, entities.Audio, entities.Video:
+		default:
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.NotOneOf, "mediaType", "image, audio, video",
+			))
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		defer file.Close()
+
+		uploaded, err := ms.Upload(c.Request.Context(), userId, file, declaredType)
+		if err != nil {
+			abortWithMediaError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, uploaded)
+	}
+}
+
+// Serves hosted media to anyone, with range requests so that audio and video can be seeked
+func ServeMediaHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mediaId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.InvalidId, "mediaId",
+			))
+			return
+		}
+
+		hosted, httpErr := entities.GetMedia(mdb, mediaId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		blob, err := ms.Open(c.Request.Context(), mediaId)
+		if err != nil {
+			if errors.Is(err, media.ErrBlobNotFound) {
+				custErrors.AbortWithError(c, custErrors.NewHttpError(
+					http.StatusNotFound,
+					custErrors.MediaContentMissing,
+				))
+				return
+			}
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		defer blob.Close()
+
+		c.Header("Content-Type", hosted.ContentType)
+		c.Header("X-Content-Type-Options", "nosniff")
+		// content of a media never changes, new content gets new id
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		http.ServeContent(c.Writer, c.Request, "", hosted.CreatedAt, blob)
+	}
+}
@@ -6,37 +6,121 @@ import (
 	"encoding"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 	"github.com/holdennekt/sgame/api"
 	"github.com/holdennekt/sgame/custErrors"
 	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/media"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func validateRounds(packDTO entities.PackDTO) ([]string, custErrors.HttpError) {
-	content := []string{packDTO.Name}
+const DRAFT_QUERY_PARAM = "draft"
 
+func validateRounds(packDTO entities.PackDTO) custErrors.HttpError {
 	for _, round := range packDTO.Rounds {
 		questionsCount := len(round.Categories[0].Questions)
 		for _, category := range round.Categories {
 			if len(category.Questions) != questionsCount {
-				return nil, custErrors.NewHttpError(
+				return custErrors.NewHttpError(
 					http.StatusBadRequest,
-					gin.H{"error": "within the round every category must have equal number of questions"},
+					custErrors.UnequalCategories,
 				)
 			}
+		}
+	}
+
+	return nil
+}
+
+// Names the pack is searched by
+func packContent(packDTO entities.PackDTO) string {
+	content := []string{packDTO.Name}
+	content = append(content, packDTO.Tags...)
+	for _, round := range packDTO.Rounds {
+		for _, category := range round.Categories {
 			content = append(content, category.Name)
 		}
 		content = append(content, round.Name)
 	}
+	return strings.Join(content, ", ")
+}
 
-	return content, nil
+func packQuestionsContent(packDTO entities.PackDTO) string {
+	content := make([]string, 0)
+	for _, round := range packDTO.Rounds {
+		for _, category := range round.Categories {
+			for _, question := range category.Questions {
+				content = append(content, question.Text)
+			}
+		}
+	}
+	for _, finalCategory := range packDTO.FinalRound.Categories {
+		content = append(content, finalCategory.Question.Text)
+	}
+	return strings.Join(content, "\n")
+}
+
+// Drafts may be incomplete, so missing and too short values are allowed.
+// Only what could never become valid, like too long texts or wrong urls, is rejected
+func validateDraft(obj any) error {
+	err := binding.Validator.ValidateStruct(obj)
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err
+	}
+	draftErrs := make(validator.ValidationErrors, 0)
+	for _, fieldErr := range validationErrs {
+		isIncomplete := (fieldErr.Tag() == "required" || fieldErr.Tag() == "min") &&
+			(fieldErr.Kind() == reflect.String || fieldErr.Kind() == reflect.Slice)
+		if !isIncomplete {
+			draftErrs = append(draftErrs, fieldErr)
+		}
+	}
+	if len(draftErrs) == 0 {
+		return nil
+	}
+	return draftErrs
+}
+
+// Validates the pack as strictly as its status requires.
+// Published packs get the checksum of their rounds, drafts have none
+func validatePack(mdb *mongo.Database, packDTO entities.PackDTO, status entities.PackStatus, ignoreId primitive.ObjectID) ([]byte, custErrors.HttpError) {
+	validate := binding.Validator.ValidateStruct
+	if status == entities.Draft {
+		validate = validateDraft
+	}
+	if err := validate(packDTO); err != nil {
+		return nil, custErrors.NewValidationError(err)
+	}
+	if packDTO.Cover != nil && packDTO.Cover.MediaType != entities.Image {
+		return nil, custErrors.NewHttpError(
+			http.StatusBadRequest,
+			custErrors.CoverNotImage,
+		)
+	}
+	if status == entities.Draft {
+		return nil, nil
+	}
+
+	if httpErr := validateRounds(packDTO); httpErr != nil {
+		return nil, httpErr
+	}
+	return validateRoundsCheckSum(mdb, packDTO, ignoreId)
+}
+
+// Packs are bound without validation, it is up to validatePack once
+// drafts are told apart and questions taken from the bank are resolved
+func bindPackJSON(c *gin.Context, obj any) error {
+	return json.NewDecoder(c.Request.Body).Decode(obj)
 }
 
 func validateRoundsCheckSum(mdb *mongo.Database, packDTO entities.PackDTO, ignoreId primitive.ObjectID) ([]byte, custErrors.HttpError) {
@@ -64,7 +148,7 @@ func validateRoundsCheckSum(mdb *mongo.Database, packDTO entities.PackDTO, ignor
 	if err == nil {
 		return nil, custErrors.NewHttpError(
 			http.StatusConflict,
-			gin.H{"error": fmt.Sprintf("the pack with such rounds already exists and has id \"%s\"", packWithSameRounds.Id.Hex())},
+			custErrors.PackDuplicate, packWithSameRounds.Id.Hex(),
 		)
 	}
 	if !errors.Is(err, mongo.ErrNoDocuments) {
@@ -74,7 +158,7 @@ func validateRoundsCheckSum(mdb *mongo.Database, packDTO entities.PackDTO, ignor
 	return roundsCheckSum, nil
 }
 
-func CreatePackHandler(mdb *mongo.Database) gin.HandlerFunc {
+func CreatePackHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
 
@@ -84,42 +168,84 @@ func CreatePackHandler(mdb *mongo.Database) gin.HandlerFunc {
 			return
 		}
 
-		var packDTO entities.PackDTO
-		if err := c.ShouldBindJSON(&packDTO); err != nil {
-			c.AbortWithStatusJSON(
+		isDraft, err := strconv.ParseBool(c.DefaultQuery(DRAFT_QUERY_PARAM, "false"))
+		if err != nil {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
 				http.StatusBadRequest,
-				gin.H{"error": strings.Join(custErrors.ParseValidationErrors(err), ", ")},
-			)
+				custErrors.NotBoolean, "draft",
+			))
 			return
 		}
+		status := entities.Published
+		if isDraft {
+			status = entities.Draft
+		}
 
-		content, httpErr := validateRounds(packDTO)
-		if httpErr != nil {
+		var packDTO entities.PackDTO
+		if err := bindPackJSON(c, &packDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+		if httpErr := resolveBankRefs(mdb, userId, &packDTO, nil); httpErr != nil {
 			custErrors.AbortWithError(c, httpErr)
 			return
 		}
 
-		roundsCheckSum, httpErr := validateRoundsCheckSum(mdb, packDTO, primitive.NilObjectID)
+		packId, httpErr := insertPack(mdb, ms, *user, packDTO, status, nil)
 		if httpErr != nil {
 			custErrors.AbortWithError(c, httpErr)
 			return
 		}
 
-		pack := &entities.Pack{
-			Author:         *user,
-			RoundsCheckSum: roundsCheckSum,
-			Content:        strings.Join(content, ", "),
-			PackDTO:        packDTO,
-		}
+		c.JSON(http.StatusCreated, gin.H{"id": packId})
+	}
+}
 
-		res, err := mdb.Collection(entities.PACKS_COLLECTION).InsertOne(context.TODO(), pack)
-		if err != nil {
-			custErrors.AbortWithInternalError(c, err)
-			return
-		}
+func insertPack(
+	mdb *mongo.Database,
+	ms *media.Service,
+	author entities.User,
+	packDTO entities.PackDTO,
+	status entities.PackStatus,
+	forkedFrom *entities.ForkInfo,
+) (primitive.ObjectID, custErrors.HttpError) {
+	packDTO.Normalize()
+	roundsCheckSum, httpErr := validatePack(mdb, packDTO, status, primitive.NilObjectID)
+	if httpErr != nil {
+		return primitive.NilObjectID, httpErr
+	}
 
-		c.JSON(http.StatusCreated, gin.H{"id": res.InsertedID})
+	pack := &entities.Pack{
+		Author:           author,
+		RoundsCheckSum:   roundsCheckSum,
+		Content:          packContent(packDTO),
+		QuestionsContent: packQuestionsContent(packDTO),
+		Collaborators:    make([]entities.Collaborator, 0),
+		Status:           status,
+		Version:          1,
+		ForkedFrom:       forkedFrom,
+		PackDTO:          packDTO,
+	}
+
+	res, err := mdb.Collection(entities.PACKS_COLLECTION).InsertOne(context.TODO(), pack)
+	if err != nil {
+		return primitive.NilObjectID, custErrors.NewInternalError(err)
 	}
+	pack.Id = res.InsertedID.(primitive.ObjectID)
+
+	_, err = mdb.Collection(entities.PACK_VERSIONS_COLLECTION).InsertOne(
+		context.TODO(),
+		entities.NewPackVersion(pack, author),
+	)
+	if err != nil {
+		return primitive.NilObjectID, custErrors.NewInternalError(err)
+	}
+
+	if err := ms.UpdateRefs(context.TODO(), nil, &packDTO); err != nil {
+		return primitive.NilObjectID, custErrors.NewInternalError(err)
+	}
+
+	return pack.Id, nil
 }
 
 func GetPackHandler(mdb *mongo.Database) gin.HandlerFunc {
@@ -129,10 +255,10 @@ func GetPackHandler(mdb *mongo.Database) gin.HandlerFunc {
 		packId := c.Param("id")
 		objId, err := primitive.ObjectIDFromHex(packId)
 		if err != nil {
-			c.AbortWithStatusJSON(
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
 				http.StatusBadRequest,
-				gin.H{"error": "invalid packId"},
-			)
+				custErrors.InvalidId, "packId",
+			))
 			return
 		}
 
@@ -142,11 +268,11 @@ func GetPackHandler(mdb *mongo.Database) gin.HandlerFunc {
 			return
 		}
 
-		if userId != pack.Author.Id {
-			c.AbortWithStatusJSON(
+		if !pack.HasRole(userId, entities.ViewerRole) {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
 				http.StatusForbidden,
-				gin.H{"error": "can not get not yours pack"},
-			)
+				custErrors.PackAccessDenied,
+			))
 			return
 		}
 
@@ -154,17 +280,23 @@ func GetPackHandler(mdb *mongo.Database) gin.HandlerFunc {
 	}
 }
 
-func UpdatePackHandler(mdb *mongo.Database) gin.HandlerFunc {
+func UpdatePackHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
 
+		user, httpErr := entities.GetUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
 		packId := c.Param("id")
 		objId, err := primitive.ObjectIDFromHex(packId)
 		if err != nil {
-			c.AbortWithStatusJSON(
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
 				http.StatusBadRequest,
-				gin.H{"error": "invalid packId"},
-			)
+				custErrors.InvalidId, "packId",
+			))
 			return
 		}
 
@@ -174,53 +306,45 @@ func UpdatePackHandler(mdb *mongo.Database) gin.HandlerFunc {
 			return
 		}
 
-		if pack.Author.Id != userId {
-			c.AbortWithStatusJSON(
+		if !pack.HasRole(userId, entities.EditorRole) {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
 				http.StatusForbidden,
-				gin.H{"error": "cannot update not your pack"},
-			)
+				custErrors.PackUpdateDenied,
+			))
 			return
 		}
 
-		var packDTO entities.PackDTO
-		if err := c.ShouldBindJSON(&packDTO); err != nil {
-			c.AbortWithStatusJSON(
+		var packUpdateDTO entities.PackUpdateDTO
+		if err := bindPackJSON(c, &packUpdateDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+		if packUpdateDTO.Version == nil {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
 				http.StatusBadRequest,
-				gin.H{"error": strings.Join(custErrors.ParseValidationErrors(err), ", ")},
-			)
+				custErrors.VersionRequired,
+			))
 			return
 		}
 
-		content, httpErr := validateRounds(packDTO)
-		if httpErr != nil {
-			custErrors.AbortWithError(c, httpErr)
+		// the changes were made to an older version, saving them would discard someone else's
+		if *packUpdateDTO.Version != pack.Version {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusConflict,
+				custErrors.PackVersionChanged, pack.Version,
+			).WithData("version", pack.Version))
 			return
 		}
 
-		newRoundsCheckSum, httpErr := validateRoundsCheckSum(mdb, packDTO, objId)
+		// questions the pack already has may stay even if they belong to someone else's bank
+		httpErr = resolveBankRefs(mdb, userId, &packUpdateDTO.PackDTO, pack.PackDTO.BankQuestionIds())
 		if httpErr != nil {
 			custErrors.AbortWithError(c, httpErr)
 			return
 		}
 
-		pack.RoundsCheckSum = newRoundsCheckSum
-		pack.Content = strings.Join(content, ", ")
-		pack.PackDTO = packDTO
-
-		res, err := mdb.Collection(entities.PACKS_COLLECTION).ReplaceOne(
-			context.TODO(),
-			bson.D{{Key: "_id", Value: pack.Id}},
-			pack,
-		)
-		if err != nil {
-			custErrors.AbortWithInternalError(c, err)
-			return
-		}
-		if res.MatchedCount == 0 {
-			c.AbortWithStatusJSON(
-				http.StatusNotFound,
-				gin.H{"error": "provided pack was not found"},
-			)
+		if httpErr := savePackVersion(mdb, ms, pack, *user, packUpdateDTO.PackDTO, nil); httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
 			return
 		}
 
@@ -228,17 +352,17 @@ func UpdatePackHandler(mdb *mongo.Database) gin.HandlerFunc {
 	}
 }
 
-func DeletePackHandler(mdb *mongo.Database) gin.HandlerFunc {
+func DeletePackHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
 
 		packId := c.Param("id")
 		objId, err := primitive.ObjectIDFromHex(packId)
 		if err != nil {
-			c.AbortWithStatusJSON(
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
 				http.StatusBadRequest,
-				gin.H{"error": "invalid packId"},
-			)
+				custErrors.InvalidId, "packId",
+			))
 			return
 		}
 
@@ -249,22 +373,26 @@ func DeletePackHandler(mdb *mongo.Database) gin.HandlerFunc {
 		}
 
 		if pack.Author.Id != userId {
-			c.AbortWithStatusJSON(
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
 				http.StatusForbidden,
-				gin.H{"error": "cannot delete not your pack"},
-			)
+				custErrors.PackDeleteDenied,
+			))
 			return
 		}
 
-		_, err = mdb.Collection(entities.PACKS_COLLECTION).DeleteOne(
-			context.TODO(),
-			bson.D{{Key: "_id", Value: objId}},
-		)
+		contents, err := entities.DeletePack(mdb, objId)
 		if err != nil {
 			custErrors.AbortWithInternalError(c, err)
 			return
 		}
 
+		for i := range contents {
+			if err := ms.UpdateRefs(context.TODO(), &contents[i], nil); err != nil {
+				custErrors.AbortWithInternalError(c, err)
+				return
+			}
+		}
+
 		c.JSON(http.StatusNoContent, packId)
 	}
 }
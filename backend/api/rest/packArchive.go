@@ -0,0 +1,274 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/archive"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/media"
+	"github.com/holdennekt/sgame/siq"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const ARCHIVE_FORM_FIELD = "archive"
+const BUNDLE_MEDIA_QUERY_PARAM = "bundleMedia"
+const SIQ_FORM_FIELD = "package"
+
+const MAX_ARCHIVE_SIZE = 500 << 20
+
+// Requests urls of attachments given by users, only public addresses are allowed
+var mediaClient = media.NewExternalClient(30 * time.Second)
+
+func abortWithArchiveError(c *gin.Context, err error) {
+	var validationErr *archive.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		archiveErr := custErrors.NewHttpError(http.StatusBadRequest, custErrors.InvalidArchive)
+		for _, message := range validationErr.Errors {
+			archiveErr = archiveErr.WithDetail("", message)
+		}
+		custErrors.AbortWithError(c, archiveErr)
+	case errors.Is(err, archive.ErrInvalidArchive):
+		custErrors.AbortWithError(c, custErrors.NewHttpError(
+			http.StatusBadRequest,
+			custErrors.InvalidArchive,
+		).WithDetail("", err.Error()))
+	default:
+		custErrors.AbortWithInternalError(c, err)
+	}
+}
+
+func ExportPackHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		packId := c.Param("id")
+		objId, err := primitive.ObjectIDFromHex(packId)
+		if err != nil {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.InvalidId, "packId",
+			))
+			return
+		}
+
+		bundleMedia, err := strconv.ParseBool(c.DefaultQuery(BUNDLE_MEDIA_QUERY_PARAM, "false"))
+		if err != nil {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.NotBoolean, "bundleMedia",
+			))
+			return
+		}
+
+		pack, httpErr := entities.GetPack(mdb, objId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		if !pack.HasRole(userId, entities.ViewerRole) {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusForbidden,
+				custErrors.PackExportDenied,
+			))
+			return
+		}
+
+		// written aside first, so that a failed download is reported instead of a broken archive
+		file, err := os.CreateTemp("", "pack-*.zip")
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+
+		err = archive.Write(file, pack.PackDTO, bundleMedia, fetchAttachment(c.Request.Context(), ms))
+		if err != nil {
+			var fetchErr *archive.FetchError
+			if errors.As(err, &fetchErr) {
+				log.Println("Error while bundling attachment:", fetchErr)
+				custErrors.AbortWithError(c, custErrors.NewHttpError(
+					http.StatusBadRequest,
+					custErrors.UnreachableAttachments,
+				).WithCodeDetail(fetchErr.Url, custErrors.AttachmentUnreachable))
+				return
+			}
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		size, err := file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		c.DataFromReader(http.StatusOK, size, "application/zip", file, map[string]string{
+			"Content-Disposition": fmt.Sprintf("attachment; filename=\"%s.zip\"", packId),
+		})
+	}
+}
+
+// Hosted media is read from the store, external urls are requested
+func fetchAttachment(ctx context.Context, ms *media.Service) archive.Fetcher {
+	download := archive.Downloader(mediaClient)
+	return func(url string) ([]byte, string, error) {
+		if id, ok := ms.IdFromUrl(url); ok {
+			return ms.Read(ctx, id)
+		}
+		return download(url)
+	}
+}
+
+func ImportPackHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		user, httpErr := entities.GetUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		fileHeader, ok := getFormFile(c, ARCHIVE_FORM_FIELD, "archive", MAX_ARCHIVE_SIZE, custErrors.NewHttpError(
+			http.StatusRequestEntityTooLarge,
+			custErrors.FileTooLarge, "archive", MAX_ARCHIVE_SIZE,
+		))
+		if !ok {
+			return
+		}
+		if fileHeader.Size > MAX_ARCHIVE_SIZE {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusRequestEntityTooLarge,
+				custErrors.FileTooLarge, "archive", MAX_ARCHIVE_SIZE,
+			))
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		defer file.Close()
+
+		packArchive, err := archive.Read(file, fileHeader.Size)
+		if err != nil {
+			abortWithArchiveError(c, err)
+			return
+		}
+
+		err = packArchive.ResolveMedia(func(mediaFile archive.MediaFile, r io.Reader) (string, error) {
+			uploaded, err := ms.Upload(c.Request.Context(), userId, r, mediaFile.MediaType)
+			if err != nil {
+				if errors.Is(err, media.ErrUnsupportedMedia) || errors.Is(err, media.ErrMediaTooLarge) {
+					return "", &archive.ValidationError{Errors: []string{
+						fmt.Sprintf("bundled media \"%s\": %s", mediaFile.Path, err),
+					}}
+				}
+				return "", err
+			}
+			return uploaded.Url, nil
+		})
+		if err != nil {
+			abortWithArchiveError(c, err)
+			return
+		}
+
+		packArchive.Manifest.Pack.ClearBankRefs()
+		packId, httpErr := insertPack(mdb, ms, *user, packArchive.Manifest.Pack, entities.Published, nil)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"id": packId})
+	}
+}
+
+// Imports SIGame package. Everything that had to be changed or dropped
+// to fit the pack into this game is reported in warnings
+func ImportSiqHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		user, httpErr := entities.GetUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		fileHeader, ok := getFormFile(c, SIQ_FORM_FIELD, "package", MAX_ARCHIVE_SIZE, custErrors.NewHttpError(
+			http.StatusRequestEntityTooLarge,
+			custErrors.FileTooLarge, "package", MAX_ARCHIVE_SIZE,
+		))
+		if !ok {
+			return
+		}
+		if fileHeader.Size > MAX_ARCHIVE_SIZE {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusRequestEntityTooLarge,
+				custErrors.FileTooLarge, "package", MAX_ARCHIVE_SIZE,
+			))
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		defer file.Close()
+
+		result, err := siq.Import(file, fileHeader.Size, func(name string, mediaType entities.MediaType, r io.Reader) (string, error) {
+			uploaded, err := ms.Upload(c.Request.Context(), userId, r, mediaType)
+			if err != nil {
+				if errors.Is(err, media.ErrUnsupportedMedia) || errors.Is(err, media.ErrMediaTooLarge) {
+					return "", fmt.Errorf("%w: %w", siq.ErrMediaRejected, err)
+				}
+				return "", err
+			}
+			return uploaded.Url, nil
+		})
+		if err != nil {
+			if errors.Is(err, siq.ErrInvalidPackage) {
+				custErrors.AbortWithError(c, custErrors.NewHttpError(
+					http.StatusBadRequest,
+					custErrors.InvalidPackage,
+				).WithDetail("", err.Error()))
+				return
+			}
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		if err := binding.Validator.ValidateStruct(result.Pack); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err).WithData("warnings", result.Warnings))
+			return
+		}
+
+		packId, httpErr := insertPack(mdb, ms, *user, result.Pack, entities.Published, nil)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"id": packId, "warnings": result.Warnings})
+	}
+}
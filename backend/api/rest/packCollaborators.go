@@ -0,0 +1,153 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const MAX_COLLABORATORS = 20
+
+// Invites the user to the pack, or changes the role of already invited one
+func InviteCollaboratorHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pack, ok := getPackForRole(c, mdb, entities.OwnerRole)
+		if !ok {
+			return
+		}
+
+		var collaboratorDTO entities.CollaboratorDTO
+		if err := c.ShouldBindJSON(&collaboratorDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+
+		if collaboratorDTO.UserId == pack.Author.Id {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.AuthorIsCollaborator,
+			))
+			return
+		}
+
+		user, httpErr := entities.GetUser(mdb, collaboratorDTO.UserId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+		if user.IsGuest {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.GuestCollaborator,
+			))
+			return
+		}
+
+		res, err := mdb.Collection(entities.PACKS_COLLECTION).UpdateOne(
+			context.TODO(),
+			bson.M{"_id": pack.Id, "collaborators.user._id": user.Id},
+			bson.M{"$set": bson.M{"collaborators.$.role": collaboratorDTO.Role}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		// an editor turned into a viewer no longer changes the pack through the bank
+		if res.MatchedCount > 0 && !collaboratorDTO.Role.IsAtLeast(entities.EditorRole) {
+			if err := unlinkCollaboratorBankQuestions(mdb, pack, user.Id); err != nil {
+				custErrors.AbortWithInternalError(c, err)
+				return
+			}
+		}
+		if res.MatchedCount == 0 {
+			collaborator := entities.Collaborator{User: *user, Role: collaboratorDTO.Role}
+			res, err = mdb.Collection(entities.PACKS_COLLECTION).UpdateOne(
+				context.TODO(),
+				bson.M{
+					"_id":                    pack.Id,
+					"collaborators.user._id": bson.M{"$ne": user.Id},
+					"$expr": bson.M{"$lt": bson.A{
+						bson.M{"$size": bson.M{"$ifNull": bson.A{"$collaborators", bson.A{}}}},
+						MAX_COLLABORATORS,
+					}},
+				},
+				bson.M{"$push": bson.M{"collaborators": collaborator}},
+			)
+			if err != nil {
+				custErrors.AbortWithInternalError(c, err)
+				return
+			}
+			if res.MatchedCount == 0 {
+				custErrors.AbortWithError(c, custErrors.NewHttpError(
+					http.StatusConflict,
+					custErrors.TooManyCollaborators,
+				))
+				return
+			}
+		}
+
+		pack, httpErr = entities.GetPack(mdb, pack.Id)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		c.JSON(http.StatusOK, pack.Collaborators)
+	}
+}
+
+// The author removes any collaborator, a collaborator may leave the pack
+func RemoveCollaboratorHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		collaboratorId, err := primitive.ObjectIDFromHex(c.Param("userId"))
+		if err != nil {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.InvalidId, "userId",
+			))
+			return
+		}
+
+		role := entities.OwnerRole
+		if collaboratorId == userId {
+			role = entities.ViewerRole
+		}
+		pack, ok := getPackForRole(c, mdb, role)
+		if !ok {
+			return
+		}
+
+		res, err := mdb.Collection(entities.PACKS_COLLECTION).UpdateOne(
+			context.TODO(),
+			bson.M{"_id": pack.Id},
+			bson.M{"$pull": bson.M{"collaborators": bson.M{"user._id": collaboratorId}}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if res.ModifiedCount == 0 {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusNotFound,
+				custErrors.NotCollaborator,
+			))
+			return
+		}
+
+		if err := unlinkCollaboratorBankQuestions(mdb, pack, collaboratorId); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
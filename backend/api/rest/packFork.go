@@ -0,0 +1,116 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/media"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Only the latest forks are shown in the lineage
+const MAX_LINEAGE_FORKS = 50
+
+type PackLineage struct {
+	// The parent first, up to the original pack
+	Ancestors []entities.ForkInfo    `json:"ancestors"`
+	Forks     []entities.PackPreview `json:"forks"`
+}
+
+// Copies a public pack, or the one the user collaborates on, to the user as a new draft.
+// The draft has to be changed before publishing, the same rounds can not be published twice
+func ForkPackHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		user, httpErr := entities.GetUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		parent, ok := getVisiblePack(c, mdb, userId)
+		if !ok {
+			return
+		}
+
+		packDTO := parent.PackDTO.Clone()
+		// the bank of the parent's author is not the user's
+		packDTO.ClearBankRefs()
+		packDTO.Type = entities.Private
+
+		packId, httpErr := insertPack(mdb, ms, *user, packDTO, entities.Draft, entities.NewForkInfo(parent))
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		_, err := mdb.Collection(entities.PACKS_COLLECTION).UpdateByID(
+			context.TODO(),
+			parent.Id,
+			bson.M{"$inc": bson.M{"forksCount": 1}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"id": packId})
+	}
+}
+
+func GetPackLineageHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		pack, ok := getVisiblePack(c, mdb, userId)
+		if !ok {
+			return
+		}
+
+		ancestors, err := entities.GetPackAncestors(mdb, pack)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		res, err := mdb.Collection(entities.PACKS_COLLECTION).Find(
+			context.TODO(),
+			bson.M{
+				"forkedFrom.packId": pack.Id,
+				"$or": []bson.M{
+					{"type": "public", "isHidden": bson.M{"$ne": true}, "status": bson.M{"$ne": entities.Draft}},
+					{"author._id": userId},
+					{"collaborators.user._id": userId},
+				},
+			},
+			options.Find().
+				SetSort(bson.D{{Key: "_id", Value: -1}}).
+				SetLimit(MAX_LINEAGE_FORKS).
+				SetProjection(bson.M{"rounds": 0, "finalRound": 0}),
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		forks := make([]entities.Pack, 0)
+		if err := res.All(context.TODO(), &forks); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		previews := make([]entities.PackPreview, len(forks))
+		for i := range forks {
+			previews[i] = entities.NewPackPreview(&forks[i])
+		}
+
+		c.JSON(http.StatusOK, PackLineage{Ancestors: ancestors, Forks: previews})
+	}
+}
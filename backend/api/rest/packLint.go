@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/i18n"
+	"github.com/holdennekt/sgame/lint"
+	"github.com/holdennekt/sgame/media"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Requesting every attachment takes a while, it can be skipped for a quick report
+const CHECK_MEDIA_QUERY_PARAM = "checkMedia"
+
+func LintPackHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checkMedia, err := strconv.ParseBool(c.DefaultQuery(CHECK_MEDIA_QUERY_PARAM, "true"))
+		if err != nil {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.NotBoolean, "checkMedia",
+			))
+			return
+		}
+
+		pack, ok := getPackForRole(c, mdb, entities.ViewerRole)
+		if !ok {
+			return
+		}
+
+		report := lint.Lint(&pack.PackDTO)
+		if checkMedia {
+			unreachable, err := ms.CheckAttachments(c.Request.Context(), mediaClient, &pack.PackDTO)
+			if err != nil {
+				custErrors.AbortWithInternalError(c, err)
+				return
+			}
+			report.AddUnreachable(&pack.PackDTO, unreachable, false)
+		}
+		report.Translate(i18n.FromContext(c))
+
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/media"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Runs the full validation of the draft, including reachability of its media,
+// and makes it playable and publicly listed
+func PublishPackHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pack, ok := getPackForRole(c, mdb, entities.EditorRole)
+		if !ok {
+			return
+		}
+
+		if !pack.IsDraft() {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusConflict,
+				custErrors.AlreadyPublished,
+			))
+			return
+		}
+
+		roundsCheckSum, httpErr := validatePack(mdb, pack.PackDTO, entities.Published, pack.Id)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		unreachable, err := ms.CheckAttachments(c.Request.Context(), mediaClient, &pack.PackDTO)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if len(unreachable) > 0 {
+			unreachableErr := custErrors.NewHttpError(http.StatusBadRequest, custErrors.UnreachableAttachments)
+			// in the order of the pack, every url once
+			for _, attachment := range pack.PackDTO.Attachments() {
+				// transport errors would tell what is listening in the network of the server
+				if err, ok := unreachable[attachment.ContentUrl]; ok {
+					log.Printf("Attachment \"%s\" is unreachable: %v\n", attachment.ContentUrl, err)
+					unreachableErr = unreachableErr.WithCodeDetail(attachment.ContentUrl, custErrors.AttachmentUnreachable)
+					delete(unreachable, attachment.ContentUrl)
+				}
+			}
+			custErrors.AbortWithError(c, unreachableErr)
+			return
+		}
+
+		res, err := mdb.Collection(entities.PACKS_COLLECTION).UpdateOne(
+			context.TODO(),
+			bson.M{"_id": pack.Id, "version": pack.Version},
+			bson.M{"$set": bson.M{"status": entities.Published, "roundsCheckSum": roundsCheckSum}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if res.MatchedCount == 0 {
+			custErrors.AbortWithError(c, errPackChanged)
+			return
+		}
+		pack.Status = entities.Published
+		pack.RoundsCheckSum = roundsCheckSum
+
+		c.JSON(http.StatusOK, pack)
+	}
+}
@@ -0,0 +1,216 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Pack the user may see the reviews of, public ones or ones they collaborate on
+func getVisiblePack(c *gin.Context, mdb *mongo.Database, userId primitive.ObjectID) (*entities.Pack, bool) {
+	objId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		custErrors.AbortWithError(c, custErrors.NewHttpError(
+			http.StatusBadRequest,
+			custErrors.InvalidId, "packId",
+		))
+		return nil, false
+	}
+
+	pack, httpErr := entities.GetPack(mdb, objId)
+	if httpErr != nil {
+		custErrors.AbortWithError(c, httpErr)
+		return nil, false
+	}
+
+	isPublic := pack.Type == entities.Public && !pack.IsHidden && !pack.IsDraft()
+	if !isPublic && !pack.HasRole(userId, entities.ViewerRole) {
+		custErrors.AbortWithError(c, custErrors.NewHttpError(
+			http.StatusForbidden,
+			custErrors.PackNotPublic,
+		))
+		return nil, false
+	}
+
+	return pack, true
+}
+
+func RatePackHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		user, httpErr := entities.GetUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		pack, ok := getVisiblePack(c, mdb, userId)
+		if !ok {
+			return
+		}
+
+		if pack.HasRole(userId, entities.ViewerRole) {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusForbidden,
+				custErrors.RateOwnPack,
+			))
+			return
+		}
+
+		hasPlayed, err := entities.HasPlayedPack(mdb, userId, pack.Id)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if !hasPlayed {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusForbidden,
+				custErrors.RateWithoutMatch,
+			))
+			return
+		}
+
+		var packRatingDTO entities.PackRatingDTO
+		if err := c.ShouldBindJSON(&packRatingDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+		if packRatingDTO.Review != nil {
+			review := strings.TrimSpace(*packRatingDTO.Review)
+			packRatingDTO.Review = &review
+			if review == "" {
+				packRatingDTO.Review = nil
+			}
+		}
+
+		now := time.Now()
+		var packRating entities.PackRating
+		err = mdb.Collection(entities.PACK_RATINGS_COLLECTION).FindOneAndUpdate(
+			context.TODO(),
+			bson.M{"packId": pack.Id, "user._id": userId},
+			bson.M{
+				"$set": bson.M{
+					"user":      user,
+					"rating":    packRatingDTO.Rating,
+					"review":    packRatingDTO.Review,
+					"updatedAt": now,
+				},
+				"$setOnInsert": bson.M{"createdAt": now},
+			},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&packRating)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		if err := entities.UpdatePackRatings(mdb, pack.Id); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, packRating)
+	}
+}
+
+func DeletePackRatingHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		packId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.InvalidId, "packId",
+			))
+			return
+		}
+
+		res, err := mdb.Collection(entities.PACK_RATINGS_COLLECTION).DeleteOne(
+			context.TODO(),
+			bson.M{"packId": packId, "user._id": userId},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if res.DeletedCount == 0 {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusNotFound,
+				custErrors.RatingNotFound,
+			))
+			return
+		}
+
+		if err := entities.UpdatePackRatings(mdb, packId); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// Newest reviews first, ratings without review text are not listed
+func GetPackReviewsHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		pack, ok := getVisiblePack(c, mdb, userId)
+		if !ok {
+			return
+		}
+
+		pr, httpErr := parsePageRequest(c, false)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+		if pr.sort != SortNewest {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.NotOneOf, "sort", SortNewest,
+			))
+			return
+		}
+
+		filter := bson.M{"packId": pack.Id, "review": bson.M{"$ne": nil}}
+		if pr.cursor != nil {
+			filter["_id"] = bson.M{"$lt": pr.cursor.Id}
+		}
+		res, err := mdb.Collection(entities.PACK_RATINGS_COLLECTION).Find(
+			context.TODO(),
+			filter,
+			options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(pr.limit+1),
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		reviews := make([]entities.PackRating, 0)
+		if err := res.All(context.TODO(), &reviews); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		var nextCursor *string
+		if int64(len(reviews)) > pr.limit {
+			reviews = reviews[:pr.limit]
+			encoded := cursor{Sort: SortNewest, Id: reviews[len(reviews)-1].Id}.encode()
+			nextCursor = &encoded
+		}
+
+		c.JSON(http.StatusOK, Page[entities.PackRating]{Items: reviews, NextCursor: nextCursor})
+	}
+}
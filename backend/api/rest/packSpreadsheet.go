@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/media"
+	"github.com/holdennekt/sgame/spreadsheet"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const SPREADSHEET_FORM_FIELD = "file"
+const PACK_NAME_FORM_FIELD = "name"
+const PACK_TYPE_FORM_FIELD = "type"
+
+// Imports pack drafted in CSV or XLSX spreadsheet, see spreadsheet.BuildPack for the columns.
+// Errors are reported per row, so that they can be fixed right in the spreadsheet
+func ImportSpreadsheetHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		user, httpErr := entities.GetUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		fileHeader, ok := getFormFile(c, SPREADSHEET_FORM_FIELD, "spreadsheet", spreadsheet.MAX_SHEET_SIZE, custErrors.NewHttpError(
+			http.StatusRequestEntityTooLarge,
+			custErrors.FileTooLarge, "spreadsheet", spreadsheet.MAX_SHEET_SIZE,
+		))
+		if !ok {
+			return
+		}
+		if fileHeader.Size > spreadsheet.MAX_SHEET_SIZE {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusRequestEntityTooLarge,
+				custErrors.FileTooLarge, "spreadsheet", spreadsheet.MAX_SHEET_SIZE,
+			))
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		defer file.Close()
+		content, err := io.ReadAll(file)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		name := c.PostForm(PACK_NAME_FORM_FIELD)
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(fileHeader.Filename), filepath.Ext(fileHeader.Filename))
+		}
+		packType := entities.PrivacyType(c.DefaultPostForm(PACK_TYPE_FORM_FIELD, string(entities.Private)))
+
+		rows, err := spreadsheet.ReadRows(content)
+		if err != nil {
+			abortWithSpreadsheetError(c, err)
+			return
+		}
+
+		packDTO, err := spreadsheet.BuildPack(rows, name, packType)
+		if err != nil {
+			abortWithSpreadsheetError(c, err)
+			return
+		}
+
+		// rows are validated by BuildPack, what is left is the pack itself
+		if err := binding.Validator.ValidateStruct(packDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+
+		packId, httpErr := insertPack(mdb, ms, *user, packDTO, entities.Published, nil)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"id": packId})
+	}
+}
+
+func abortWithSpreadsheetError(c *gin.Context, err error) {
+	var validationErr *spreadsheet.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		spreadsheetErr := custErrors.NewHttpError(http.StatusBadRequest, custErrors.InvalidSpreadsheet)
+		for _, rowErr := range validationErr.Errors {
+			spreadsheetErr = spreadsheetErr.WithDetail(rowErr.Location(), rowErr.Error)
+		}
+		custErrors.AbortWithError(c, spreadsheetErr)
+	case errors.Is(err, spreadsheet.ErrInvalidSpreadsheet):
+		custErrors.AbortWithError(c, custErrors.NewHttpError(
+			http.StatusBadRequest,
+			custErrors.InvalidSpreadsheet,
+		).WithDetail("", err.Error()))
+	default:
+		custErrors.AbortWithInternalError(c, err)
+	}
+}
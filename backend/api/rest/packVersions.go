@@ -0,0 +1,285 @@
+package rest
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/media"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const FROM_VERSION_QUERY_PARAM = "from"
+const TO_VERSION_QUERY_PARAM = "to"
+
+type packContentUpdate struct {
+	RoundsCheckSum   []byte `bson:"roundsCheckSum"`
+	Content          string `bson:"content"`
+	QuestionsContent string `bson:"questionsContent"`
+	Version          int    `bson:"version"`
+	entities.PackDTO `bson:"inline"`
+}
+
+var errPackChanged = custErrors.NewHttpError(
+	http.StatusConflict,
+	custErrors.PackChanged,
+)
+
+// Saves packDTO as the next version of the pack. The version the pack was read at
+// guards against concurrent saves, the one that saves second gets a conflict
+func savePackVersion(
+	mdb *mongo.Database,
+	ms *media.Service,
+	pack *entities.Pack,
+	savedBy entities.User,
+	packDTO entities.PackDTO,
+	restoredFrom *int,
+) custErrors.HttpError {
+	packDTO.Normalize()
+	roundsCheckSum, httpErr := validatePack(mdb, packDTO, pack.Status, pack.Id)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	filter := bson.M{"_id": pack.Id, "version": pack.Version}
+	if pack.Version == 0 {
+		// packs saved before versioning have no history, their content becomes the first version
+		filter["version"] = bson.M{"$in": bson.A{nil, 0}}
+		pack.Version = 1
+		_, err := mdb.Collection(entities.PACK_VERSIONS_COLLECTION).InsertOne(
+			context.TODO(),
+			entities.NewPackVersion(pack, pack.Author),
+		)
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return custErrors.NewInternalError(err)
+		}
+	}
+
+	pack.Version++
+	pack.RoundsCheckSum = roundsCheckSum
+	pack.Content = packContent(packDTO)
+	pack.QuestionsContent = packQuestionsContent(packDTO)
+	pack.PackDTO = packDTO
+	packVersion := entities.NewPackVersion(pack, savedBy)
+	packVersion.RestoredFrom = restoredFrom
+
+	res, err := mdb.Collection(entities.PACK_VERSIONS_COLLECTION).InsertOne(context.TODO(), packVersion)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errPackChanged
+		}
+		return custErrors.NewInternalError(err)
+	}
+
+	// only the content is set, so that collaborators changed meanwhile are kept
+	updateRes, err := mdb.Collection(entities.PACKS_COLLECTION).UpdateOne(
+		context.TODO(),
+		filter,
+		bson.M{"$set": packContentUpdate{
+			RoundsCheckSum:   pack.RoundsCheckSum,
+			Content:          pack.Content,
+			QuestionsContent: pack.QuestionsContent,
+			Version:          pack.Version,
+			PackDTO:          pack.PackDTO,
+		}},
+	)
+	if err != nil || updateRes.MatchedCount == 0 {
+		// the version is left dangling if this fails too, it is not referenced by the pack
+		_, deleteErr := mdb.Collection(entities.PACK_VERSIONS_COLLECTION).DeleteOne(
+			context.TODO(),
+			bson.D{{Key: "_id", Value: res.InsertedID}},
+		)
+		if deleteErr != nil {
+			log.Println("Error while deleting unused pack version:", deleteErr)
+		}
+		if err != nil {
+			return custErrors.NewInternalError(err)
+		}
+		return errPackChanged
+	}
+
+	// every version references its media, so that any of them can be restored
+	if err := ms.UpdateRefs(context.TODO(), nil, &pack.PackDTO); err != nil {
+		return custErrors.NewInternalError(err)
+	}
+
+	return nil
+}
+
+func getPackForRole(c *gin.Context, mdb *mongo.Database, role entities.CollaboratorRole) (*entities.Pack, bool) {
+	userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+	objId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		custErrors.AbortWithError(c, custErrors.NewHttpError(
+			http.StatusBadRequest,
+			custErrors.InvalidId, "packId",
+		))
+		return nil, false
+	}
+
+	pack, httpErr := entities.GetPack(mdb, objId)
+	if httpErr != nil {
+		custErrors.AbortWithError(c, httpErr)
+		return nil, false
+	}
+
+	if !pack.HasRole(userId, role) {
+		custErrors.AbortWithError(c, custErrors.NewHttpError(
+			http.StatusForbidden,
+			custErrors.PackRoleRequired, role,
+		))
+		return nil, false
+	}
+
+	return pack, true
+}
+
+func parseVersion(value string, name string) (int, custErrors.HttpError) {
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, custErrors.NewHttpError(
+			http.StatusBadRequest,
+			custErrors.NotPositiveInteger, name,
+		)
+	}
+	return version, nil
+}
+
+// Content of the version, the latest version is read from the pack itself
+func getPackContent(mdb *mongo.Database, pack *entities.Pack, version int) (*entities.PackDTO, custErrors.HttpError) {
+	if version == pack.Version {
+		return &pack.PackDTO, nil
+	}
+	packVersion, httpErr := entities.GetPackVersion(mdb, pack.Id, version)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return &packVersion.PackDTO, nil
+}
+
+func GetPackVersionsHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pack, ok := getPackForRole(c, mdb, entities.ViewerRole)
+		if !ok {
+			return
+		}
+
+		previews, httpErr := entities.GetPackVersionPreviews(mdb, pack.Id)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"currentVersion": pack.Version,
+			"versions":       previews,
+		})
+	}
+}
+
+func GetPackVersionHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pack, ok := getPackForRole(c, mdb, entities.ViewerRole)
+		if !ok {
+			return
+		}
+
+		version, httpErr := parseVersion(c.Param("version"), "version")
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		packVersion, httpErr := entities.GetPackVersion(mdb, pack.Id, version)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		c.JSON(http.StatusOK, packVersion)
+	}
+}
+
+func DiffPackVersionsHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pack, ok := getPackForRole(c, mdb, entities.ViewerRole)
+		if !ok {
+			return
+		}
+
+		from, httpErr := parseVersion(c.Query(FROM_VERSION_QUERY_PARAM), FROM_VERSION_QUERY_PARAM)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+		to := pack.Version
+		if c.Query(TO_VERSION_QUERY_PARAM) != "" {
+			to, httpErr = parseVersion(c.Query(TO_VERSION_QUERY_PARAM), TO_VERSION_QUERY_PARAM)
+			if httpErr != nil {
+				custErrors.AbortWithError(c, httpErr)
+				return
+			}
+		}
+
+		fromContent, httpErr := getPackContent(mdb, pack, from)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+		toContent, httpErr := getPackContent(mdb, pack, to)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"from":    from,
+			"to":      to,
+			"changes": entities.DiffPacks(fromContent, toContent),
+		})
+	}
+}
+
+func RestorePackVersionHandler(mdb *mongo.Database, ms *media.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		user, httpErr := entities.GetUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		pack, ok := getPackForRole(c, mdb, entities.EditorRole)
+		if !ok {
+			return
+		}
+
+		version, httpErr := parseVersion(c.Param("version"), "version")
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		packVersion, httpErr := entities.GetPackVersion(mdb, pack.Id, version)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		if httpErr := savePackVersion(mdb, ms, pack, *user, packVersion.PackDTO, &version); httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		c.JSON(http.StatusOK, pack)
+	}
+}
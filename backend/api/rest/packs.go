@@ -1,8 +1,8 @@
 package rest
 
 import (
-	"context"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -13,55 +13,163 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const FILTER_QUERY_PARAM = "filter"
-const PAGE_QUERY_PARAM = "page"
+const IN_QUESTIONS_QUERY_PARAM = "inQuestions"
+const AUTHOR_QUERY_PARAM = "author"
+const MIN_ROUNDS_QUERY_PARAM = "minRounds"
+const MAX_ROUNDS_QUERY_PARAM = "maxRounds"
+const LANGUAGE_QUERY_PARAM = "language"
+const DIFFICULTY_QUERY_PARAM = "difficulty"
+
+// Comma separated, packs having every tag are found
+const TAGS_QUERY_PARAM = "tags"
 const LIMIT_QUERY_PARAM = "limit"
 
-const DEFAULT_PAGE = "1"
 const DEFAULT_LIMIT = "50"
 
-func GetPacksPreviewHandler(mdb *mongo.Database) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+// Removes what the text search would treat as phrases or negations,
+// so that user input is only ever searched as plain words
+func searchTerms(searchFilter string) []string {
+	terms := make([]string, 0)
+	for _, field := range strings.Fields(strings.ReplaceAll(searchFilter, "\"", " ")) {
+		term := strings.TrimLeft(field, "-")
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Filter of packs visible to the user that match the search query parameters
+func packsFilter(c *gin.Context, userId primitive.ObjectID) (bson.M, custErrors.HttpError) {
+	filter := bson.M{
+		"$or": []bson.M{
+			{"type": "public", "isHidden": bson.M{"$ne": true}, "status": bson.M{"$ne": entities.Draft}},
+			{"author._id": userId},
+			{"collaborators.user._id": userId},
+		},
+	}
+
+	terms := searchTerms(c.Query(FILTER_QUERY_PARAM))
+	if len(terms) > 0 {
+		filter["$text"] = bson.M{"$search": strings.Join(terms, " ")}
+
+		inQuestions, err := strconv.ParseBool(c.DefaultQuery(IN_QUESTIONS_QUERY_PARAM, "false"))
+		if err != nil {
+			return nil, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.NotBoolean, "inQuestions",
+			)
+		}
+		// the text index covers question texts too, names must match on their own
+		if !inQuestions {
+			patterns := make([]string, len(terms))
+			for i, term := range terms {
+				patterns[i] = regexp.QuoteMeta(term)
+			}
+			filter["content"] = primitive.Regex{Pattern: strings.Join(patterns, "|"), Options: "i"}
+		}
+	}
 
-		searchFilter := strings.TrimSpace(c.Query(FILTER_QUERY_PARAM))
-		limit, err := strconv.ParseInt(c.DefaultQuery(LIMIT_QUERY_PARAM, DEFAULT_LIMIT), 10, 64)
+	if author := c.Query(AUTHOR_QUERY_PARAM); author != "" {
+		authorId, err := primitive.ObjectIDFromHex(author)
 		if err != nil {
-			c.AbortWithStatusJSON(
+			return nil, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.InvalidId, "author",
+			)
+		}
+		filter["author._id"] = authorId
+	}
+
+	roundsCount := bson.M{"$size": bson.M{"$ifNull": bson.A{"$rounds", bson.A{}}}}
+	roundsConditions := bson.A{}
+	for param, operator := range map[string]string{MIN_ROUNDS_QUERY_PARAM: "$gte", MAX_ROUNDS_QUERY_PARAM: "$lte"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			return nil, custErrors.NewHttpError(
 				http.StatusBadRequest,
-				gin.H{"error": "limit must be a number"},
+				custErrors.NotNonNegativeInteger, param,
 			)
+		}
+		roundsConditions = append(roundsConditions, bson.M{operator: bson.A{roundsCount, count}})
+	}
+	if len(roundsConditions) > 0 {
+		filter["$expr"] = bson.M{"$and": roundsConditions}
+	}
+
+	if language := c.Query(LANGUAGE_QUERY_PARAM); language != "" {
+		filter["language"] = strings.ToLower(language)
+	}
+	if difficulty := c.Query(DIFFICULTY_QUERY_PARAM); difficulty != "" {
+		filter["difficulty"] = difficulty
+	}
+	if tagsParam := c.Query(TAGS_QUERY_PARAM); tagsParam != "" {
+		tags := make([]string, 0)
+		for _, tag := range strings.Split(tagsParam, ",") {
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) > 0 {
+			filter["tags"] = bson.M{"$all": tags}
+		}
+	}
+
+	return filter, nil
+}
+
+func GetPacksPreviewHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		filter, httpErr := packsFilter(c, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+		_, isSearch := filter["$text"]
+		pr, httpErr := parsePageRequest(c, isSearch)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
 			return
 		}
 
-		packs := make([]entities.PackPreview, 0)
-		res, err := mdb.Collection(entities.PACKS_COLLECTION).Find(
-			context.TODO(),
+		packs, nextCursor, err := findPacksPage(
+			mdb,
+			filter,
 			bson.M{
-				"name": primitive.Regex{
-					Pattern: searchFilter,
-					Options: "i",
-				},
-				"$or": []bson.M{
-					{"type": "public"},
-					{"author": userId},
-				},
+				"_id":          1,
+				"name":         1,
+				"description":  1,
+				"tags":         1,
+				"language":     1,
+				"difficulty":   1,
+				"cover":        1,
+				"version":      1,
+				"playCount":    1,
+				"rating":       1,
+				"ratingsCount": 1,
 			},
-			options.Find().SetLimit(limit).SetProjection(bson.M{"_id": 1, "name": 1}),
+			pr,
 		)
 		if err != nil {
 			custErrors.AbortWithInternalError(c, err)
 			return
 		}
-		if err := res.All(context.TODO(), &packs); err != nil {
-			custErrors.AbortWithInternalError(c, err)
-			return
+
+		previews := make([]entities.PackPreview, len(packs))
+		for i, pack := range packs {
+			previews[i] = entities.NewPackPreview(&pack)
 		}
 
-		c.JSON(http.StatusOK, packs)
+		c.JSON(http.StatusOK, Page[entities.PackPreview]{Items: previews, NextCursor: nextCursor})
 	}
 }
 
@@ -69,78 +177,29 @@ func GetHiddenPacksHandler(mdb *mongo.Database) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
 
-		searchFilter := strings.TrimSpace(c.Query(FILTER_QUERY_PARAM))
-		page, err := strconv.ParseInt(c.DefaultQuery(PAGE_QUERY_PARAM, DEFAULT_PAGE), 10, 64)
-		if err != nil || page < 1 {
-			c.AbortWithStatusJSON(
-				http.StatusBadRequest,
-				gin.H{"error": "page must be an integer number greater than 0"},
-			)
+		filter, httpErr := packsFilter(c, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
 			return
 		}
-		limit, err := strconv.ParseInt(c.DefaultQuery(LIMIT_QUERY_PARAM, DEFAULT_LIMIT), 10, 64)
-		if err != nil || limit < 1 {
-			c.AbortWithStatusJSON(
-				http.StatusBadRequest,
-				gin.H{"error": "limit must be an integer number greater than 0"},
-			)
+		_, isSearch := filter["$text"]
+		pr, httpErr := parsePageRequest(c, isSearch)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
 			return
 		}
 
-		packs := make([]entities.Pack, 0)
-		res, err := mdb.Collection(entities.PACKS_COLLECTION).Find(
-			context.TODO(),
-			bson.M{
-				"name": primitive.Regex{
-					Pattern: searchFilter,
-					Options: "i",
-				},
-				"$or": []bson.M{
-					{"type": "public"},
-					{"author": userId},
-				},
-			},
-			options.
-				Find().
-				SetSort(bson.D{{Key: "_id", Value: 1}}).
-				SetSkip((page-1)*limit).
-				SetLimit(limit),
-		)
+		packs, nextCursor, err := findPacksPage(mdb, filter, nil, pr)
 		if err != nil {
 			custErrors.AbortWithInternalError(c, err)
 			return
 		}
-		if err := res.All(context.TODO(), &packs); err != nil {
-			custErrors.AbortWithInternalError(c, err)
-			return
-		}
 
 		hiddenPacks := make([]entities.HiddenPack, len(packs))
 		for i, pack := range packs {
 			hiddenPacks[i] = entities.NewHiddenPack(pack)
 		}
 
-		count, err := mdb.Collection(entities.PACKS_COLLECTION).CountDocuments(
-			context.TODO(),
-			bson.M{
-				"name": primitive.Regex{
-					Pattern: searchFilter,
-					Options: "i",
-				},
-				"$or": []bson.M{
-					{"type": "public"},
-					{"author": userId},
-				},
-			},
-		)
-		if err != nil {
-			custErrors.AbortWithInternalError(c, err)
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"count":      count,
-			"portionRes": hiddenPacks,
-		})
+		c.JSON(http.StatusOK, Page[entities.HiddenPack]{Items: hiddenPacks, NextCursor: nextCursor})
 	}
 }
@@ -0,0 +1,188 @@
+package rest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const CURSOR_QUERY_PARAM = "cursor"
+const SORT_QUERY_PARAM = "sort"
+
+const MAX_LIMIT = 100
+
+type PackSort string
+
+const (
+	SortRelevance  PackSort = "relevance"
+	SortNewest     PackSort = "newest"
+	SortMostPlayed PackSort = "mostPlayed"
+	SortTopRated   PackSort = "topRated"
+)
+
+// Packs sorted by these are paged by the value of the field and id
+var packSortFields = map[PackSort]string{
+	SortMostPlayed: "playCount",
+	SortTopRated:   "rating",
+}
+
+// Page of results, NextCursor is null on the last one
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"nextCursor"`
+}
+
+// Position after the last item of the page. Relevance can not be
+// compared between queries, so those pages are continued by offset
+type cursor struct {
+	Sort   PackSort           `json:"s"`
+	Value  float64            `json:"v,omitempty"`
+	Id     primitive.ObjectID `json:"id,omitempty"`
+	Offset int64              `json:"o,omitempty"`
+}
+
+func (cr cursor) encode() string {
+	marshaled, _ := json.Marshal(cr)
+	return base64.RawURLEncoding.EncodeToString(marshaled)
+}
+
+func decodeCursor(encoded string) (*cursor, error) {
+	marshaled, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var cr cursor
+	if err := json.Unmarshal(marshaled, &cr); err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+type pageRequest struct {
+	sort   PackSort
+	limit  int64
+	cursor *cursor
+}
+
+func parsePageRequest(c *gin.Context, isSearch bool) (*pageRequest, custErrors.HttpError) {
+	limit, err := strconv.ParseInt(c.DefaultQuery(LIMIT_QUERY_PARAM, DEFAULT_LIMIT), 10, 64)
+	if err != nil || limit < 1 {
+		return nil, custErrors.NewHttpError(
+			http.StatusBadRequest,
+			custErrors.NotPositiveInteger, "limit",
+		)
+	}
+	if limit > MAX_LIMIT {
+		limit = MAX_LIMIT
+	}
+
+	defaultSort := SortNewest
+	if isSearch {
+		defaultSort = SortRelevance
+	}
+	sort := PackSort(c.DefaultQuery(SORT_QUERY_PARAM, string(defaultSort)))
+	switch sort {
+	case SortNewest, SortMostPlayed, SortTopRated:
+	case SortRelevance:
+		if !isSearch {
+			return nil, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.RelevanceNeedsSearch,
+			)
+		}
+	default:
+		return nil, custErrors.NewHttpError(
+			http.StatusBadRequest,
+			custErrors.NotOneOf, "sort", fmt.Sprintf("%s, %s, %s, %s", SortRelevance, SortNewest, SortMostPlayed, SortTopRated),
+		)
+	}
+
+	pr := &pageRequest{sort: sort, limit: limit}
+	if encoded := c.Query(CURSOR_QUERY_PARAM); encoded != "" {
+		cr, err := decodeCursor(encoded)
+		if err != nil || cr.Sort != sort {
+			return nil, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.InvalidCursor,
+			)
+		}
+		pr.cursor = cr
+	}
+	return pr, nil
+}
+
+// Finds the page of packs matching the filter. Projection may be nil to get whole packs
+func findPacksPage(mdb *mongo.Database, filter bson.M, projection bson.M, pr *pageRequest) ([]entities.Pack, *string, error) {
+	findOptions := options.Find().SetLimit(pr.limit + 1)
+	if projection == nil {
+		projection = bson.M{}
+	}
+
+	field, byField := packSortFields[pr.sort]
+	switch {
+	case pr.sort == SortRelevance:
+		score := bson.M{"$meta": "textScore"}
+		projection["score"] = score
+		findOptions.SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: -1}})
+		if pr.cursor != nil {
+			findOptions.SetSkip(pr.cursor.Offset)
+		}
+	case byField:
+		findOptions.SetSort(bson.D{{Key: field, Value: -1}, {Key: "_id", Value: -1}})
+		if pr.cursor != nil {
+			filter["$and"] = bson.A{bson.M{"$or": bson.A{
+				bson.M{field: bson.M{"$lt": pr.cursor.Value}},
+				bson.M{field: pr.cursor.Value, "_id": bson.M{"$lt": pr.cursor.Id}},
+			}}}
+		}
+	default:
+		findOptions.SetSort(bson.D{{Key: "_id", Value: -1}})
+		if pr.cursor != nil {
+			filter["_id"] = bson.M{"$lt": pr.cursor.Id}
+		}
+	}
+	if len(projection) > 0 {
+		findOptions.SetProjection(projection)
+	}
+
+	packs := make([]entities.Pack, 0)
+	res, err := mdb.Collection(entities.PACKS_COLLECTION).Find(context.TODO(), filter, findOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := res.All(context.TODO(), &packs); err != nil {
+		return nil, nil, err
+	}
+
+	if int64(len(packs)) <= pr.limit {
+		return packs, nil, nil
+	}
+	packs = packs[:pr.limit]
+
+	last := packs[len(packs)-1]
+	next := cursor{Sort: pr.sort, Id: last.Id}
+	switch pr.sort {
+	case SortRelevance:
+		next = cursor{Sort: pr.sort, Offset: pr.limit}
+		if pr.cursor != nil {
+			next.Offset += pr.cursor.Offset
+		}
+	case SortMostPlayed:
+		next.Value = float64(last.PlayCount)
+	case SortTopRated:
+		next.Value = last.Rating
+	}
+	encoded := next.encode()
+	return packs, &encoded, nil
+}
@@ -0,0 +1,104 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func ReportPackHandler(mdb *mongo.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		user, httpErr := entities.GetUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		packId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.InvalidId, "packId",
+			))
+			return
+		}
+
+		var reportDTO entities.ReportDTO
+		if err := c.ShouldBindJSON(&reportDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+
+		pack, httpErr := entities.GetPack(mdb, packId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		if pack.Type != entities.Public || pack.Author.Id == userId {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusForbidden,
+				custErrors.ReportOwnPack,
+			))
+			return
+		}
+
+		if reportDTO.Question != nil && !reportDTO.Question.ExistsIn(pack) {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusBadRequest,
+				custErrors.QuestionNotInPack,
+			))
+			return
+		}
+
+		sameReportFilter := bson.M{
+			"packId":         packId,
+			"reportedBy._id": userId,
+			"status":         entities.ReportOpen,
+			"question":       reportDTO.Question,
+		}
+		if reportDTO.Question == nil {
+			sameReportFilter["question"] = bson.M{"$exists": false}
+		}
+		err = mdb.Collection(entities.REPORTS_COLLECTION).FindOne(context.TODO(), sameReportFilter).Err()
+		if err == nil {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusConflict,
+				custErrors.AlreadyReported,
+			))
+			return
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		report := &entities.Report{
+			PackId:     packId,
+			PackName:   pack.Name,
+			ReportedBy: *user,
+			CreatedAt:  time.Now(),
+			Status:     entities.ReportOpen,
+			ReportDTO:  reportDTO,
+		}
+
+		res, err := mdb.Collection(entities.REPORTS_COLLECTION).InsertOne(context.TODO(), report)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"id": res.InsertedID})
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"log"
 	"net/http"
 	"slices"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +21,9 @@ import (
 )
 
 const PASSWORD_QUERY_PARAM = "password"
+const ROLE_QUERY_PARAM = "role"
+
+const SPECTATOR_ROLE = "spectator"
 
 func CreateRoomHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -35,29 +37,44 @@ func CreateRoomHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 
 		var roomDTO entities.RoomDTO
 		if err := c.ShouldBindJSON(&roomDTO); err != nil {
-			c.AbortWithStatusJSON(
-				http.StatusBadRequest,
-				gin.H{"error": strings.Join(custErrors.ParseValidationErrors(err), ", ")},
-			)
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
 			return
 		}
 
-		pack, httpErr := entities.GetPack(mdb, roomDTO.PackId)
-		if httpErr != nil {
-			custErrors.AbortWithError(c, httpErr)
-			return
+		var pack *entities.Pack
+		var generatedPack *entities.Pack
+		if roomDTO.Random != nil {
+			if !roomDTO.PackId.IsZero() {
+				custErrors.AbortWithError(c, custErrors.NewHttpError(
+					http.StatusBadRequest,
+					custErrors.PackOrRandomRequired,
+				))
+				return
+			}
+			// nobody has joined yet, the pack is assembled again when the game starts
+			generatedPack, httpErr = entities.GenerateRandomPack(mdb, *roomDTO.Random, nil)
+			if httpErr != nil {
+				custErrors.AbortWithError(c, httpErr)
+				return
+			}
+			pack = generatedPack
+		} else {
+			pack, httpErr = getPlayablePack(mdb, userId, roomDTO.PackId)
+			if httpErr != nil {
+				custErrors.AbortWithError(c, httpErr)
+				return
+			}
 		}
 
 		room := &entities.Room{
-			Id:      primitive.NewObjectID(),
-			RoomDTO: roomDTO,
-			PackPreview: entities.PackPreview{
-				Id:   pack.Id,
-				Name: pack.Name,
-			},
-			Players:   make([]entities.Player, 0),
-			CreatedBy: userId,
-			Host:      &entities.Host{User: *user},
+			Id:            primitive.NewObjectID(),
+			RoomDTO:       roomDTO,
+			PackPreview:   entities.NewPackPreview(pack),
+			Players:       make([]entities.Player, 0),
+			Spectators:    make([]entities.Spectator, 0),
+			CreatedBy:     userId,
+			Host:          &entities.Host{User: *user},
+			GeneratedPack: generatedPack,
 		}
 
 		key := entities.GetRoomRedisKey(room.Id.Hex())
@@ -75,6 +92,29 @@ func CreateRoomHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 	}
 }
 
+func getPlayablePack(mdb *mongo.Database, userId primitive.ObjectID, packId primitive.ObjectID) (*entities.Pack, custErrors.HttpError) {
+	pack, httpErr := entities.GetPack(mdb, packId)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	if pack.IsHidden && !pack.HasRole(userId, entities.ViewerRole) {
+		return nil, custErrors.NewHttpError(
+			http.StatusForbidden,
+			custErrors.PackHidden,
+		)
+	}
+
+	if pack.IsDraft() {
+		return nil, custErrors.NewHttpError(
+			http.StatusConflict,
+			custErrors.PackIsDraft,
+		)
+	}
+
+	return pack, nil
+}
+
 func GetRoomHandler(rds *redis.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
@@ -90,7 +130,7 @@ func GetRoomHandler(rds *redis.Client) gin.HandlerFunc {
 		if room.Options.Type == entities.Private && password != *room.Options.Password {
 			custErrors.AbortWithError(c, custErrors.NewHttpError(
 				http.StatusForbidden,
-				gin.H{"error": "wrong password"},
+				custErrors.WrongRoomPassword,
 			))
 			return
 		}
@@ -121,10 +161,20 @@ func EnterRoomHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 			return
 		}
 
-		if room.CurrentRound == nil && !room.FinalRoundState.IsActive {
+		if user.IsGuest && (room.Options.AreGuestsDisallowed || room.Options.Type == entities.Private) {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusForbidden,
+				custErrors.GuestsNotAllowedInRoom,
+			))
+			return
+		}
+
+		isSpectator := c.Query(ROLE_QUERY_PARAM) == SPECTATOR_ROLE
+
+		if !isSpectator && room.CurrentRound == nil && !room.FinalRoundState.IsActive {
 			custErrors.AbortWithError(c, custErrors.NewHttpError(
 				http.StatusForbidden,
-				gin.H{"error": "game already started"},
+				custErrors.GameAlreadyStarted,
 			))
 			return
 		}
@@ -133,7 +183,7 @@ func EnterRoomHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 		if room.Options.Type == entities.Private && password != *room.Options.Password {
 			custErrors.AbortWithError(c, custErrors.NewHttpError(
 				http.StatusForbidden,
-				gin.H{"error": "wrong password"},
+				custErrors.WrongRoomPassword,
 			))
 			return
 		}
@@ -145,23 +195,27 @@ func EnterRoomHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 			}
 
 			isFull := len(room.Players) >= room.Options.MaxPlayers
-			canBeHost := user.Id == room.CreatedBy && room.Host == nil
+			canBeHost := !isSpectator && user.Id == room.CreatedBy && room.Host == nil
 
-			if isFull && !canBeHost {
+			if !isSpectator && isFull && !canBeHost {
 				return custErrors.NewHttpError(
 					http.StatusConflict,
-					gin.H{"error": "the room is already full"},
+					custErrors.RoomFull,
 				)
 			}
 
 			_, err := tx.TxPipelined(context.TODO(), func(p redis.Pipeliner) error {
-				if canBeHost {
+				if isSpectator {
+					room.Spectators = append(room.Spectators, entities.Spectator{User: *user})
+					p.JSONSet(context.TODO(), roomKey, "$.spectators", room.Spectators)
+				} else if canBeHost {
 					room.Host = &entities.Host{User: *user}
 					p.JSONSet(context.TODO(), roomKey, "$.host", room.Host)
 				} else {
 					room.Players = append(room.Players, entities.Player{User: *user})
 					p.JSONSet(context.TODO(), roomKey, "$.players", room.Players)
 				}
+				p.SAdd(context.TODO(), entities.GetUserRoomsRedisKey(user.Id.Hex()), room.Id.Hex())
 				connectedPlayerIndex := slices.IndexFunc(room.Players, func(p entities.Player) bool {
 					return p.IsConnected
 				})
@@ -0,0 +1,496 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/api/ws"
+	wsLobby "github.com/holdennekt/sgame/api/ws/lobby"
+	lobbyEvents "github.com/holdennekt/sgame/api/ws/lobby/events"
+	roomEvents "github.com/holdennekt/sgame/api/ws/room/events"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/media"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func checkUserPassword(dbUser *entities.DbUser, password string) custErrors.HttpError {
+	err := api.CheckPasswordHash(password, dbUser.Password)
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return custErrors.NewHttpError(
+				http.StatusUnauthorized,
+				custErrors.WrongPassword,
+			)
+		}
+		return custErrors.NewInternalError(err)
+	}
+	return nil
+}
+
+func updateUserInRooms(rds *redis.Client, user entities.User) error {
+	roomIds, err := entities.GetUserRoomIds(rds, user.Id)
+	if err != nil {
+		return err
+	}
+
+	left := make([]any, 0)
+	for _, roomId := range roomIds {
+		id, err := primitive.ObjectIDFromHex(roomId)
+		if err != nil {
+			return err
+		}
+		roomKey := entities.GetRoomRedisKey(roomId)
+		var room *entities.Room
+		isUserIn := false
+		err = api.TryUpdateRoom(rds, id, func(tx *redis.Tx) error {
+			var httpErr custErrors.HttpError
+			room, httpErr = entities.GetRoomByKey(rds, roomKey)
+			if httpErr != nil {
+				return httpErr
+			}
+			isUserIn = room.IsUserIn(user.Id)
+			if !isUserIn {
+				return nil
+			}
+
+			_, err := tx.TxPipelined(context.TODO(), func(p redis.Pipeliner) error {
+				if room.IsUserHost(user.Id) {
+					room.Host.User = user
+					p.JSONSet(context.TODO(), roomKey, "$.host", room.Host)
+				}
+				for i := range room.Players {
+					if room.Players[i].Id == user.Id {
+						room.Players[i].User = user
+						p.JSONSet(context.TODO(), roomKey, "$.players", room.Players)
+					}
+				}
+				for i := range room.Spectators {
+					if room.Spectators[i].Id == user.Id {
+						room.Spectators[i].User = user
+						p.JSONSet(context.TODO(), roomKey, "$.spectators", room.Spectators)
+					}
+				}
+				return nil
+			})
+			return err
+		}, roomEvents.UPDATE_ROOM_RETRIES)
+		if err != nil {
+			// the room has expired or was closed since the user entered it
+			if httpErr := custErrors.AsHttpError(err); httpErr.ErrorCode() == custErrors.RoomNotFound {
+				left = append(left, roomId)
+				continue
+			}
+			return err
+		}
+		if !isUserIn {
+			left = append(left, roomId)
+			continue
+		}
+
+		roomMessage := roomEvents.RoomInternalMessage()
+		if err := ws.PublishRdsMessage(rds, roomKey, roomMessage); err != nil {
+			log.Println(err)
+		}
+
+		lobbyMessage := lobbyEvents.NewLobbyRoomInternalMessage(room)
+		if err := ws.PublishRdsMessage(rds, wsLobby.LOBBY, lobbyMessage); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if len(left) > 0 {
+		userRoomsKey := entities.GetUserRoomsRedisKey(user.Id.Hex())
+		if err := rds.SRem(context.TODO(), userRoomsKey, left...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func UpdateProfileHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		var profileDTO entities.ProfileDTO
+		if err := c.ShouldBindJSON(&profileDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+
+		res, err := mdb.Collection(entities.USERS_COLLECTION).UpdateByID(
+			context.TODO(),
+			userId,
+			bson.M{"$set": bson.M{"name": profileDTO.Name, "avatar": profileDTO.Avatar}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if res.MatchedCount == 0 {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusNotFound,
+				custErrors.UserNotFound, userId.Hex(),
+			))
+			return
+		}
+
+		user := entities.User{Id: userId, Name: profileDTO.Name, Avatar: profileDTO.Avatar}
+
+		_, err = mdb.Collection(entities.PACKS_COLLECTION).UpdateMany(
+			context.TODO(),
+			bson.M{"author._id": userId},
+			bson.M{"$set": bson.M{"author": user}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		_, err = mdb.Collection(entities.PACK_VERSIONS_COLLECTION).UpdateMany(
+			context.TODO(),
+			bson.M{"savedBy._id": userId},
+			bson.M{"$set": bson.M{"savedBy": user}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		_, err = mdb.Collection(entities.PACKS_COLLECTION).UpdateMany(
+			context.TODO(),
+			bson.M{"forkedFrom.author._id": userId},
+			bson.M{"$set": bson.M{"forkedFrom.author": user}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		_, err = mdb.Collection(entities.PACKS_COLLECTION).UpdateMany(
+			context.TODO(),
+			bson.M{"collaborators.user._id": userId},
+			bson.M{"$set": bson.M{"collaborators.$[collaborator].user": user}},
+			options.Update().SetArrayFilters(options.ArrayFilters{
+				Filters: []any{bson.M{"collaborator.user._id": userId}},
+			}),
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		_, err = mdb.Collection(entities.PACK_RATINGS_COLLECTION).UpdateMany(
+			context.TODO(),
+			bson.M{"user._id": userId},
+			bson.M{"$set": bson.M{"user": user}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		if err := updateUserInRooms(rds, user); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+func ChangePasswordHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+		sessionId := c.MustGet(api.SESSION_ID_CONTEXT_KEY).(string)
+
+		var passwordDTO entities.PasswordDTO
+		if err := c.ShouldBindJSON(&passwordDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+
+		dbUser, httpErr := entities.GetDbUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		// users logging in only with third party identities have no password to confirm
+		if dbUser.Password != "" {
+			if httpErr := checkUserPassword(dbUser, passwordDTO.CurrentPassword); httpErr != nil {
+				custErrors.AbortWithError(c, httpErr)
+				return
+			}
+		}
+
+		hashed, err := api.HashPassword(passwordDTO.NewPassword)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		_, err = mdb.Collection(entities.USERS_COLLECTION).UpdateByID(
+			context.TODO(),
+			userId,
+			bson.M{"$set": bson.M{"password": hashed}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		userSessions, err := api.GetUserSessions(rds, userId.Hex())
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		for otherSessionId := range userSessions {
+			if otherSessionId == sessionId {
+				continue
+			}
+			if err := api.DeleteSession(rds, otherSessionId, userId.Hex()); err != nil {
+				custErrors.AbortWithInternalError(c, err)
+				return
+			}
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func DeleteUserHandler(mdb *mongo.Database, rds *redis.Client, ms *media.Service, so api.SessionOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		var deleteUserDTO entities.DeleteUserDTO
+		if err := c.ShouldBindJSON(&deleteUserDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+
+		dbUser, httpErr := entities.GetDbUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		// users logging in only with third party identities have no password to confirm
+		if dbUser.Password != "" {
+			if httpErr := checkUserPassword(dbUser, deleteUserDTO.Password); httpErr != nil {
+				custErrors.AbortWithError(c, httpErr)
+				return
+			}
+		}
+
+		_, err := mdb.Collection(entities.PACKS_COLLECTION).UpdateMany(
+			context.TODO(),
+			bson.M{"author._id": userId},
+			bson.M{"$set": bson.M{"author": entities.DELETED_USER}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		_, err = mdb.Collection(entities.PACK_VERSIONS_COLLECTION).UpdateMany(
+			context.TODO(),
+			bson.M{"savedBy._id": userId},
+			bson.M{"$set": bson.M{"savedBy": entities.DELETED_USER}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		_, err = mdb.Collection(entities.PACKS_COLLECTION).UpdateMany(
+			context.TODO(),
+			bson.M{"forkedFrom.author._id": userId},
+			bson.M{"$set": bson.M{"forkedFrom.author": entities.DELETED_USER}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		_, err = mdb.Collection(entities.PACKS_COLLECTION).UpdateMany(
+			context.TODO(),
+			bson.M{"collaborators.user._id": userId},
+			bson.M{"$pull": bson.M{"collaborators": bson.M{"user._id": userId}}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		// ratings still count, but nothing tells who played or rated
+		_, err = mdb.Collection(entities.PACK_RATINGS_COLLECTION).UpdateMany(
+			context.TODO(),
+			bson.M{"user._id": userId},
+			bson.M{"$set": bson.M{"user": entities.DELETED_USER}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		_, err = mdb.Collection(entities.MATCHES_COLLECTION).UpdateMany(
+			context.TODO(),
+			bson.M{"players.user._id": userId},
+			bson.M{"$set": bson.M{"players.$[player].user": entities.DELETED_USER}},
+			options.Update().SetArrayFilters(options.ArrayFilters{
+				Filters: []any{bson.M{"player.user._id": userId}},
+			}),
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		_, err = mdb.Collection(entities.MATCHES_COLLECTION).UpdateMany(
+			context.TODO(),
+			bson.M{"host._id": userId},
+			bson.M{"$set": bson.M{"host": entities.DELETED_USER}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		if err := deleteBankQuestions(mdb, ms, userId); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		_, err = mdb.Collection(entities.REPORTS_COLLECTION).UpdateMany(
+			context.TODO(),
+			bson.M{"reportedBy._id": userId},
+			bson.M{"$set": bson.M{"reportedBy": entities.DELETED_USER}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		_, err = mdb.Collection(entities.USERS_COLLECTION).DeleteOne(
+			context.TODO(),
+			bson.D{{Key: "_id", Value: userId}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		_, err = mdb.Collection(entities.ACCESS_TOKENS_COLLECTION).DeleteMany(
+			context.TODO(),
+			bson.D{{Key: "userId", Value: userId}},
+		)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		if err := api.DeleteUserSessions(rds, userId.Hex()); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		userLanguageKey := api.GetUserLanguageRedisKey(userId.Hex())
+		userRoomsKey := entities.GetUserRoomsRedisKey(userId.Hex())
+		if err := rds.Del(context.TODO(), userLanguageKey, userRoomsKey).Err(); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		so.ClearCookie(c)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func UpgradeGuestHandler(mdb *mongo.Database, rds *redis.Client, so api.SessionOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(api.USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+		sessionId := c.MustGet(api.SESSION_ID_CONTEXT_KEY).(string)
+
+		if !c.GetBool(api.IS_GUEST_CONTEXT_KEY) {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusConflict,
+				custErrors.AlreadyRegistered,
+			))
+			return
+		}
+
+		var dbUserDTO entities.DbUserDTO
+		if err := c.ShouldBind(&dbUserDTO); err != nil {
+			custErrors.AbortWithError(c, custErrors.NewValidationError(err))
+			return
+		}
+
+		hashed, err := api.HashPassword(dbUserDTO.Password)
+		if err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		res, err := mdb.Collection(entities.USERS_COLLECTION).UpdateByID(
+			context.TODO(),
+			userId,
+			bson.M{
+				"$set":   bson.M{"login": dbUserDTO.Login, "password": hashed, "isGuest": false},
+				"$unset": bson.M{"expiresAt": ""},
+			},
+		)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				custErrors.AbortWithError(c, custErrors.NewHttpError(
+					http.StatusConflict,
+					custErrors.LoginTaken,
+				))
+				return
+			}
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		if res.MatchedCount == 0 {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusNotFound,
+				custErrors.GuestExpired,
+			))
+			return
+		}
+
+		if err := api.RenewSession(rds, so, sessionId, userId.Hex()); err != nil {
+			if errors.Is(err, redis.Nil) {
+				custErrors.AbortWithError(c, custErrors.NewHttpError(
+					http.StatusUnauthorized,
+					custErrors.InvalidSession,
+				))
+				return
+			}
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+		so.SetCookie(c, sessionId)
+
+		user, httpErr := entities.GetUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		if err := updateUserInRooms(rds, *user); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, user)
+	}
+}
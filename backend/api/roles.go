@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const ROLE_CONTEXT_KEY = "role"
+
+func RequireRole(mdb *mongo.Database, role entities.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		dbUser, httpErr := entities.GetDbUser(mdb, userId)
+		if httpErr != nil {
+			custErrors.AbortWithError(c, httpErr)
+			return
+		}
+
+		if !dbUser.GetRole().Includes(role) {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusForbidden,
+				custErrors.RoleRequired, role,
+			))
+			return
+		}
+
+		c.Set(ROLE_CONTEXT_KEY, dbUser.GetRole())
+	}
+}
@@ -4,46 +4,336 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/holdennekt/sgame/custErrors"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 const SESSION_ID_COOKIE_NAME = "sessionId"
-const SESSIONS_KEY = "sessions"
+const SESSION_PREFIX = "session:"
+const USER_SESSIONS_PREFIX = "userSessions:"
 const USER_ID_CONTEXT_KEY = "userId"
+const SESSION_ID_CONTEXT_KEY = "sessionId"
+const IS_GUEST_CONTEXT_KEY = "isGuest"
 
-func AuthorizeConnection(rds *redis.Client) gin.HandlerFunc {
+type CookieOptions struct {
+	MaxAge   int
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+type SessionOptions struct {
+	TTL      time.Duration
+	GuestTTL time.Duration
+	Cookie   CookieOptions
+}
+
+type Session struct {
+	UserId     primitive.ObjectID `json:"-"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	LastSeenAt time.Time          `json:"lastSeenAt"`
+	UserAgent  string             `json:"userAgent"`
+	IsGuest    bool               `json:"-"`
+	IsCurrent  bool               `json:"isCurrent"`
+}
+
+func (so SessionOptions) SetCookie(c *gin.Context, sessionId string) {
+	c.SetSameSite(so.Cookie.SameSite)
+	c.SetCookie(SESSION_ID_COOKIE_NAME, sessionId, so.Cookie.MaxAge, "/", so.Cookie.Domain, so.Cookie.Secure, true)
+}
+
+func (so SessionOptions) SetGuestCookie(c *gin.Context, sessionId string) {
+	c.SetSameSite(so.Cookie.SameSite)
+	c.SetCookie(SESSION_ID_COOKIE_NAME, sessionId, int(so.GuestTTL.Seconds()), "/", so.Cookie.Domain, so.Cookie.Secure, true)
+}
+
+func (so SessionOptions) ClearCookie(c *gin.Context) {
+	c.SetSameSite(so.Cookie.SameSite)
+	c.SetCookie(SESSION_ID_COOKIE_NAME, "", -1, "/", so.Cookie.Domain, so.Cookie.Secure, true)
+}
+
+func GetSessionRedisKey(sessionId string) string {
+	return SESSION_PREFIX + sessionId
+}
+
+func GetUserSessionsRedisKey(userId string) string {
+	return USER_SESSIONS_PREFIX + userId
+}
+
+func SetSession(rds *redis.Client, so SessionOptions, userId string, userAgent string, isGuest bool) (string, error) {
+	sessionId, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+
+	ttl := so.TTL
+	if isGuest {
+		ttl = so.GuestTTL
+	}
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	sessionKey := GetSessionRedisKey(sessionId.String())
+	userSessionsKey := GetUserSessionsRedisKey(userId)
+	_, err = rds.TxPipelined(context.TODO(), func(p redis.Pipeliner) error {
+		p.HSet(context.TODO(), sessionKey,
+			"userId", userId,
+			"createdAt", now,
+			"lastSeenAt", now,
+			"userAgent", userAgent,
+			"isGuest", isGuest,
+		)
+		p.Expire(context.TODO(), sessionKey, ttl)
+		p.SAdd(context.TODO(), userSessionsKey, sessionId.String())
+		p.Expire(context.TODO(), userSessionsKey, ttl)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return sessionId.String(), nil
+}
+
+func GetSession(rds *redis.Client, sessionId string) (*Session, error) {
+	fields, err := rds.HGetAll(context.TODO(), GetSessionRedisKey(sessionId)).Result()
+	if err != nil {
+		return nil, err
+	}
+	// a hash without userId is what is left of a session deleted while it was being renewed
+	if len(fields) == 0 || fields["userId"] == "" {
+		return nil, redis.Nil
+	}
+
+	userId, err := primitive.ObjectIDFromHex(fields["userId"])
+	if err != nil {
+		return nil, err
+	}
+	createdAt, _ := strconv.ParseInt(fields["createdAt"], 10, 64)
+	lastSeenAt, _ := strconv.ParseInt(fields["lastSeenAt"], 10, 64)
+
+	return &Session{
+		UserId:     userId,
+		CreatedAt:  time.Unix(createdAt, 0),
+		LastSeenAt: time.Unix(lastSeenAt, 0),
+		UserAgent:  fields["userAgent"],
+		IsGuest:    fields["isGuest"] == "1",
+	}, nil
+}
+
+const RENEW_SESSION_RETRIES = 3
+
+// Returns redis.Nil if the session no longer exists, so a deleted session is not recreated by renewing it
+func RenewSession(rds *redis.Client, so SessionOptions, sessionId string, userId string) error {
+	sessionKey := GetSessionRedisKey(sessionId)
+	userSessionsKey := GetUserSessionsRedisKey(userId)
+	renew := func(tx *redis.Tx) error {
+		exists, err := tx.Exists(context.TODO(), sessionKey).Result()
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			return redis.Nil
+		}
+
+		_, err = tx.TxPipelined(context.TODO(), func(p redis.Pipeliner) error {
+			p.HSet(context.TODO(), sessionKey,
+				"lastSeenAt", strconv.FormatInt(time.Now().Unix(), 10),
+				"isGuest", false,
+			)
+			p.Expire(context.TODO(), sessionKey, so.TTL)
+			p.Expire(context.TODO(), userSessionsKey, so.TTL)
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < RENEW_SESSION_RETRIES; i++ {
+		err := rds.Watch(context.TODO(), renew, sessionKey)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return custErrors.ErrInternal
+}
+
+func DeleteSession(rds *redis.Client, sessionId string, userId string) error {
+	_, err := rds.TxPipelined(context.TODO(), func(p redis.Pipeliner) error {
+		p.Del(context.TODO(), GetSessionRedisKey(sessionId))
+		p.SRem(context.TODO(), GetUserSessionsRedisKey(userId), sessionId)
+		return nil
+	})
+	return err
+}
+
+func DeleteUserSessions(rds *redis.Client, userId string) error {
+	userSessionsKey := GetUserSessionsRedisKey(userId)
+	sessionIds, err := rds.SMembers(context.TODO(), userSessionsKey).Result()
+	if err != nil {
+		return err
+	}
+
+	_, err = rds.TxPipelined(context.TODO(), func(p redis.Pipeliner) error {
+		for _, sessionId := range sessionIds {
+			p.Del(context.TODO(), GetSessionRedisKey(sessionId))
+		}
+		p.Del(context.TODO(), userSessionsKey)
+		return nil
+	})
+	return err
+}
+
+func GetUserSessions(rds *redis.Client, userId string) (map[string]Session, error) {
+	userSessionsKey := GetUserSessionsRedisKey(userId)
+	sessionIds, err := rds.SMembers(context.TODO(), userSessionsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string]Session, len(sessionIds))
+	expired := make([]any, 0)
+	for _, sessionId := range sessionIds {
+		session, err := GetSession(rds, sessionId)
+		if err != nil {
+			if errors.Is(err, redis.Nil) {
+				expired = append(expired, sessionId)
+				continue
+			}
+			return nil, err
+		}
+		sessions[sessionId] = *session
+	}
+
+	if len(expired) > 0 {
+		if err := rds.SRem(context.TODO(), userSessionsKey, expired...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
+}
+
+func AuthorizeConnection(mdb *mongo.Database, rds *redis.Client, so SessionOptions) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if token, ok := getAccessToken(c); ok {
+			authorizeAccessToken(c, mdb, token)
+			if !c.IsAborted() {
+				setPreferredLanguage(c, mdb, rds)
+			}
+			return
+		}
+
 		sessionId, err := c.Cookie(SESSION_ID_COOKIE_NAME)
 		if err != nil {
 			custErrors.AbortWithError(c, custErrors.NewHttpError(
 				http.StatusUnauthorized,
-				gin.H{"error": "missing sessionId cookie"},
+				custErrors.MissingSession,
 			))
 			return
 		}
 
-		userId, err := rds.HGet(context.TODO(), SESSIONS_KEY, sessionId).Result()
+		session, err := GetSession(rds, sessionId)
 		if err != nil {
 			if errors.Is(err, redis.Nil) {
 				custErrors.AbortWithError(c, custErrors.NewHttpError(
 					http.StatusUnauthorized,
-					gin.H{"error": "invalid sessionId"},
+					custErrors.InvalidSession,
 				))
 				return
 			}
 			custErrors.AbortWithInternalError(c, err)
 			return
 		}
-		userObjectId, err := primitive.ObjectIDFromHex(userId)
+
+		// guest sessions are not renewed, they live as long as the guest user itself
+		if !session.IsGuest {
+			if err := RenewSession(rds, so, sessionId, session.UserId.Hex()); err != nil {
+				if errors.Is(err, redis.Nil) {
+					custErrors.AbortWithError(c, custErrors.NewHttpError(
+						http.StatusUnauthorized,
+						custErrors.InvalidSession,
+					))
+					return
+				}
+				custErrors.AbortWithInternalError(c, err)
+				return
+			}
+			so.SetCookie(c, sessionId)
+		}
+
+		c.Set(USER_ID_CONTEXT_KEY, session.UserId)
+		c.Set(SESSION_ID_CONTEXT_KEY, sessionId)
+		c.Set(IS_GUEST_CONTEXT_KEY, session.IsGuest)
+		setPreferredLanguage(c, mdb, rds)
+	}
+}
+
+func RequireRegistered() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool(IS_GUEST_CONTEXT_KEY) {
+			custErrors.AbortWithError(c, custErrors.NewHttpError(
+				http.StatusForbidden,
+				custErrors.GuestsNotAllowed,
+			))
+			return
+		}
+	}
+}
+
+func LogoutHandler(rds *redis.Client, so SessionOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+		sessionId := c.MustGet(SESSION_ID_CONTEXT_KEY).(string)
+
+		if err := DeleteSession(rds, sessionId, userId.Hex()); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		so.ClearCookie(c)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func LogoutAllHandler(rds *redis.Client, so SessionOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+
+		if err := DeleteUserSessions(rds, userId.Hex()); err != nil {
+			custErrors.AbortWithInternalError(c, err)
+			return
+		}
+
+		so.ClearCookie(c)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func GetSessionsHandler(rds *redis.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId := c.MustGet(USER_ID_CONTEXT_KEY).(primitive.ObjectID)
+		currentSessionId := c.MustGet(SESSION_ID_CONTEXT_KEY).(string)
+
+		userSessions, err := GetUserSessions(rds, userId.Hex())
 		if err != nil {
 			custErrors.AbortWithInternalError(c, err)
 			return
 		}
 
-		c.Set(USER_ID_CONTEXT_KEY, userObjectId)
+		sessions := make([]Session, 0, len(userSessions))
+		for sessionId, session := range userSessions {
+			session.IsCurrent = sessionId == currentSessionId
+			sessions = append(sessions, session)
+		}
+		slices.SortFunc(sessions, func(a, b Session) int {
+			return b.LastSeenAt.Compare(a.LastSeenAt)
+		})
+
+		c.JSON(http.StatusOK, sessions)
 	}
 }
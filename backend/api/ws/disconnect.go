@@ -0,0 +1,29 @@
+package ws
+
+import (
+	"github.com/holdennekt/sgame/entities"
+	"github.com/redis/go-redis/v9"
+)
+
+const DISCONNECT Event = "disconnect"
+
+// Every connection of a user listens to this channel besides the one of its lobby or room
+const USER_CHANNEL_PREFIX = "userChannel:"
+
+func GetUserChannel(userId string) string {
+	return USER_CHANNEL_PREFIX + userId
+}
+
+func DisconnectInternalMessage() InternalMessage {
+	return InternalMessage{
+		From: entities.SYSTEM,
+		Message: Message{
+			Event: DISCONNECT,
+		},
+	}
+}
+
+// Closes every websocket connection of the user, in the lobby and in rooms
+func DisconnectUser(rds *redis.Client, userId string) error {
+	return PublishRdsMessage(rds, GetUserChannel(userId), DisconnectInternalMessage())
+}
@@ -2,25 +2,30 @@ package ws
 
 import (
 	"encoding/json"
+
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/i18n"
 )
 
 const ERROR Event = "error"
 
 type errorMessage struct {
-	Event   Event        `json:"event"`
-	Payload errorPayload `json:"payload"`
+	Event   Event                    `json:"event"`
+	Payload custErrors.ErrorResponse `json:"payload"`
 }
 
-type errorPayload struct {
-	Error string `json:"error"`
-}
+// messageId is the id of the client message that has caused the error, if any.
+// Every error gets its own correlation id, it is logged along with the error
+func NewErrorMessage(err error, lang i18n.Language, messageId string) errorMessage {
+	httpErr := custErrors.AsHttpError(err)
+	correlationId := custErrors.NewCorrelationId()
+	custErrors.LogError(correlationId, httpErr)
 
-func NewErrorMessage(err error) errorMessage {
+	response := httpErr.Response(lang, correlationId)
+	response.MessageId = messageId
 	return errorMessage{
-		Event: ERROR,
-		Payload: errorPayload{
-			Error: err.Error(),
-		},
+		Event:   ERROR,
+		Payload: response,
 	}
 }
 
@@ -44,7 +44,7 @@ func ConnectHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 				}
 
 				log.Printf("User \"%s\" has sent ws message with event \"%s\": %v\n", userId, msg.Event, string(msg.Payload))
-				handleWsMessage(pubSubConn, msg)
+				handleWsMessage(wsConn, pubSubConn, msg)
 
 			case rdsMsg, ok := <-pubSubConn.Messages:
 				if !ok {
@@ -52,7 +52,10 @@ func ConnectHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 					return
 				}
 				var msg ws.InternalMessage
-				json.Unmarshal([]byte(rdsMsg.Payload), &msg)
+				if err := json.Unmarshal([]byte(rdsMsg.Payload), &msg); err != nil {
+					log.Println("Error while decoding pubSub message:", err)
+					continue
+				}
 
 				log.Printf("User \"%s\" has recieved pubSub message from %s with event \"%s\": %v\n", userId, msg.From.Id, msg.Event, string(msg.Payload))
 				handleRdsMessage(wsConn, msg)
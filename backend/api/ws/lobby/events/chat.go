@@ -2,8 +2,10 @@ package events
 
 import (
 	"encoding/json"
+	"net/http"
 
 	"github.com/holdennekt/sgame/api/ws"
+	"github.com/holdennekt/sgame/custErrors"
 	"github.com/holdennekt/sgame/entities"
 )
 
@@ -45,6 +47,13 @@ func (cm chatMessage) ToMessage() ws.Message {
 	}
 }
 
-func HandleWsChatMessage(pubSubConn *ws.PubSubConn, msg ws.InternalMessage) {
-	pubSubConn.Publish(msg)
+// Malformed messages are rejected here, so that the error reaches the sender and not every user in the lobby
+func HandleWsChatMessage(wsConn *ws.WsConn, pubSubConn *ws.PubSubConn, msg ws.InternalMessage) {
+	if _, err := NewChatMessage(msg); err != nil {
+		wsConn.PublishError(custErrors.NewHttpError(http.StatusBadRequest, custErrors.MalformedMessage, err.Error()), msg.Id)
+		return
+	}
+	if err := pubSubConn.Publish(msg); err != nil {
+		wsConn.PublishError(err, msg.Id)
+	}
 }
@@ -1,33 +1,47 @@
 package wsLobby
 
 import (
+	"log"
+
 	"github.com/holdennekt/sgame/api/ws"
 	"github.com/holdennekt/sgame/api/ws/lobby/events"
 )
 
 func handleRdsMessage(wsConn *ws.WsConn, msg ws.InternalMessage) {
+	var err error
 	switch msg.Event {
 	case events.CHAT:
-		handleRdsChatMessage(wsConn, msg)
+		err = handleRdsChatMessage(wsConn, msg)
 	case events.LOBBY_ROOM:
-		handleRdsLobbyRoomMessage(wsConn, msg)
+		err = handleRdsLobbyRoomMessage(wsConn, msg)
 	case events.ROOM_DELETED:
-		handleRdsRoomDeletedMessage(wsConn, msg)
+		err = handleRdsRoomDeletedMessage(wsConn, msg)
+	case ws.DISCONNECT:
+		err = handleRdsDisconnectMessage(wsConn, msg)
+	}
+	if err != nil {
+		log.Printf("Error while handling pubSub message with event \"%s\": %v\n", msg.Event, err)
 	}
 }
 
-func handleRdsChatMessage(wsConn *ws.WsConn, msg ws.InternalMessage) {
+// Chat messages have been checked before publishing, the error is not the fault of this client
+func handleRdsChatMessage(wsConn *ws.WsConn, msg ws.InternalMessage) error {
 	chatMessage, err := events.NewChatMessage(msg)
 	if err != nil {
-		wsConn.PublishError(err)
+		return err
 	}
-	wsConn.Publish(chatMessage.ToMessage())
+	return wsConn.Publish(chatMessage.ToMessage())
+}
+
+func handleRdsLobbyRoomMessage(wsConn *ws.WsConn, msg ws.InternalMessage) error {
+	return wsConn.Publish(ws.Message{Event: events.LOBBY_ROOM, Payload: msg.Payload})
 }
 
-func handleRdsLobbyRoomMessage(wsConn *ws.WsConn, msg ws.InternalMessage) {
-	wsConn.Publish(ws.Message{Event: events.LOBBY_ROOM, Payload: msg.Payload})
+func handleRdsRoomDeletedMessage(wsConn *ws.WsConn, msg ws.InternalMessage) error {
+	return wsConn.Publish(ws.Message{Event: events.ROOM_DELETED, Payload: msg.Payload})
 }
 
-func handleRdsRoomDeletedMessage(wsConn *ws.WsConn, msg ws.InternalMessage) {
-	wsConn.Publish(ws.Message{Event: events.ROOM_DELETED, Payload: msg.Payload})
+func handleRdsDisconnectMessage(wsConn *ws.WsConn, msg ws.InternalMessage) error {
+	defer wsConn.Conn.Close()
+	return wsConn.Publish(msg.Message)
 }
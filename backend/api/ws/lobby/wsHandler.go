@@ -5,9 +5,9 @@ import (
 	"github.com/holdennekt/sgame/api/ws/lobby/events"
 )
 
-func handleWsMessage(pubSubConn *ws.PubSubConn, msg ws.InternalMessage) {
+func handleWsMessage(wsConn *ws.WsConn, pubSubConn *ws.PubSubConn, msg ws.InternalMessage) {
 	switch msg.Event {
 	case events.CHAT:
-		events.HandleWsChatMessage(pubSubConn, msg)
+		events.HandleWsChatMessage(wsConn, pubSubConn, msg)
 	}
 }
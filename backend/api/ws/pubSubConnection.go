@@ -16,7 +16,7 @@ type PubSubConn struct {
 }
 
 func ConnectUserToPubSub(rds *redis.Client, userId primitive.ObjectID, chanName string) *PubSubConn {
-	pubSub := rds.Subscribe(context.TODO(), chanName)
+	pubSub := rds.Subscribe(context.TODO(), chanName, GetUserChannel(userId.Hex()))
 	return &PubSubConn{
 		userId:   userId,
 		Conn:     pubSub,
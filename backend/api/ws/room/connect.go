@@ -38,15 +38,19 @@ func ConnectHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 		if !room.IsUserIn(userId) {
 			custErrors.AbortWithError(c, custErrors.NewHttpError(
 				http.StatusForbidden,
-				gin.H{"error": "you are not in the room"},
+				custErrors.NotInRoom,
 			))
 			return
 		}
 
-		pack, httpErr := entities.GetPack(mdb, room.PackId)
-		if httpErr != nil {
-			custErrors.AbortWithError(c, httpErr)
-			return
+		// the game goes on with the version the room was created with
+		pack := room.GeneratedPack
+		if !room.IsRandom() {
+			pack, httpErr = entities.GetPackAtVersion(mdb, room.PackId, room.PackPreview.Version)
+			if httpErr != nil {
+				custErrors.AbortWithError(c, httpErr)
+				return
+			}
 		}
 
 		wsConn, err := ws.ConnectUserToWs(c, *user)
@@ -68,6 +72,12 @@ func ConnectHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 				if room.IsUserHost(userId) {
 					room.Host.IsConnected = true
 					p.JSONSet(context.TODO(), roomKey, "$.host", room.Host)
+				} else if room.IsUserSpectator(userId) {
+					i := slices.IndexFunc(room.Spectators, func(s entities.Spectator) bool {
+						return userId == s.Id
+					})
+					room.Spectators[i].IsConnected = true
+					p.JSONSet(context.TODO(), roomKey, "$.spectators", room.Spectators)
 				} else {
 					i := slices.IndexFunc(room.Players, func(p entities.Player) bool {
 						return userId == p.Id
@@ -81,7 +91,7 @@ func ConnectHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 			return err
 		}, UPDATE_ROOM_RETRIES)
 		if err != nil {
-			wsConn.PublishError(err)
+			wsConn.PublishError(err, "")
 			wsConn.Conn.Close()
 			pubSubConn.Conn.Close()
 			return
@@ -89,7 +99,7 @@ func ConnectHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 
 		roomMessage := events.RoomInternalMessage()
 		if err := pubSubConn.Publish(roomMessage); err != nil {
-			wsConn.PublishError(err)
+			wsConn.PublishError(err, "")
 			wsConn.Conn.Close()
 			pubSubConn.Conn.Close()
 			return
@@ -112,7 +122,10 @@ func ConnectHandler(mdb *mongo.Database, rds *redis.Client) gin.HandlerFunc {
 					return
 				}
 				var msg ws.InternalMessage
-				json.Unmarshal([]byte(rdsMsg.Payload), &msg)
+				if err := json.Unmarshal([]byte(rdsMsg.Payload), &msg); err != nil {
+					log.Println("Error while decoding pubSub message:", err)
+					continue
+				}
 
 				log.Printf("User \"%s\" has recieved pubSub message from %s with event \"%s\": %v\n", userId, msg.From.Id, msg.Event, string(msg.Payload))
 				handleRdsMessage(mdb, rds, wsConn, pubSubConn, pack, room, userId, msg)
@@ -3,11 +3,12 @@ package events
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"net/http"
 	"slices"
 
 	"github.com/holdennekt/sgame/api"
 	"github.com/holdennekt/sgame/api/ws"
+	"github.com/holdennekt/sgame/custErrors"
 	"github.com/holdennekt/sgame/entities"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -20,10 +21,14 @@ type AnswerMessage struct {
 }
 
 func HandleRdsAnswerMessage(rds *redis.Client, wsConn *ws.WsConn, pubSubConn *ws.PubSubConn, roomId primitive.ObjectID, msg ws.InternalMessage) {
-	room, _ := entities.GetRoomById(rds, roomId)
+	room, httpErr := entities.GetRoomById(rds, roomId)
+	if httpErr != nil {
+		wsConn.PublishError(httpErr, msg.Id)
+		return
+	}
 
 	if room.FinalRoundState.IsActive {
-		wsConn.PublishError(errors.New("not allowed to answer"))
+		wsConn.PublishError(custErrors.NewHttpError(http.StatusForbidden, custErrors.NotAllowedToAnswer), msg.Id)
 		return
 	}
 
@@ -34,7 +39,7 @@ func HandleRdsAnswerMessage(rds *redis.Client, wsConn *ws.WsConn, pubSubConn *ws
 		}
 
 		if room.AnsweringPlayer != nil || !slices.Contains(room.AllowedToAnswer, msg.From.Id) {
-			return errors.New("not allowed to answer")
+			return custErrors.NewHttpError(http.StatusForbidden, custErrors.NotAllowedToAnswer)
 		}
 
 		room.AnsweringPlayer = &msg.From.Id
@@ -53,13 +58,13 @@ func HandleRdsAnswerMessage(rds *redis.Client, wsConn *ws.WsConn, pubSubConn *ws
 		return err
 	}, UPDATE_ROOM_RETRIES)
 	if err != nil {
-		wsConn.PublishError(err)
+		wsConn.PublishError(err, msg.Id)
 		return
 	}
 
 	roomMessage := RoomInternalMessage()
 	if err := pubSubConn.Publish(roomMessage); err != nil {
-		wsConn.PublishError(err)
+		wsConn.PublishError(err, msg.Id)
 		return
 	}
 }
@@ -0,0 +1,72 @@
+package events
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/api/ws"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Sent by host to end the game, e.g. instead of playing the final round
+const FINISH ws.Event = "finish"
+
+// Finished room is kept for a while, so that players can see the results
+const FINISHED_ROOM_TTL = 10 * time.Minute
+
+type FinishMessage struct {
+	Event ws.Event `json:"event"`
+}
+
+func HandleRdsFinishMessage(mdb *mongo.Database, rds *redis.Client, wsConn *ws.WsConn, pubSubConn *ws.PubSubConn, roomId primitive.ObjectID, msg ws.InternalMessage) {
+	var finishedRoom *entities.Room
+	err := api.TryUpdateRoom(rds, roomId, func(tx *redis.Tx) error {
+		room, httpErr := entities.GetRoomById(rds, roomId)
+		if httpErr != nil {
+			return httpErr
+		}
+
+		if !room.IsUserHost(msg.From.Id) || !room.IsStarted() || room.IsFinished() {
+			return custErrors.NewHttpError(http.StatusForbidden, custErrors.NotAllowedToFinish)
+		}
+		room.Finish()
+		finishedRoom = room
+
+		_, err := tx.Pipelined(context.TODO(), func(p redis.Pipeliner) error {
+			roomKey := entities.GetRoomRedisKey(roomId.Hex())
+			p.JSONSet(context.TODO(), roomKey, "$", room)
+			return nil
+		})
+		return err
+	}, UPDATE_ROOM_RETRIES)
+	if err != nil {
+		wsConn.PublishError(err, msg.Id)
+		return
+	}
+
+	if err := RecordFinishedMatch(mdb, rds, finishedRoom); err != nil {
+		wsConn.PublishError(err, msg.Id)
+		return
+	}
+
+	roomMessage := RoomInternalMessage()
+	if err := pubSubConn.Publish(roomMessage); err != nil {
+		wsConn.PublishError(err, msg.Id)
+		return
+	}
+}
+
+// Saves the match to history and lets the finished room expire
+func RecordFinishedMatch(mdb *mongo.Database, rds *redis.Client, room *entities.Room) error {
+	if err := entities.RecordMatch(mdb, room); err != nil {
+		return err
+	}
+	roomKey := entities.GetRoomRedisKey(room.Id.Hex())
+	return rds.Expire(context.TODO(), roomKey, FINISHED_ROOM_TTL).Err()
+}
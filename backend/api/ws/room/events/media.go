@@ -0,0 +1,231 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/api/ws"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Sent by system when question with audio or video is chosen, clients should start buffering it
+const MEDIA_PRELOAD ws.Event = "mediaPreload"
+
+// Sent by host or player once the announced media is buffered
+const MEDIA_BUFFERED ws.Event = "mediaBuffered"
+
+// Sent by system to every participant, the playback must start at startAt
+const MEDIA_START ws.Event = "mediaStart"
+
+type MediaPreloadMessage struct {
+	Event   ws.Event            `json:"event"`
+	Payload MediaPreloadPayload `json:"payload"`
+}
+
+type MediaPreloadPayload struct {
+	Id             string              `json:"id"`
+	Attachment     entities.Attachment `json:"attachment"`
+	BufferDeadline time.Time           `json:"bufferDeadline"`
+}
+
+type MediaBufferedMessage struct {
+	Event   ws.Event             `json:"event"`
+	Payload MediaBufferedPayload `json:"payload"`
+}
+
+type MediaBufferedPayload struct {
+	Id string `json:"id"`
+	// Duration of the media in seconds as reported by the client's player
+	Duration float64 `json:"duration"`
+}
+
+type MediaStartMessage struct {
+	Event   ws.Event          `json:"event"`
+	Payload MediaStartPayload `json:"payload"`
+}
+
+// ServerTime lets clients correct startAt for their clock skew
+type MediaStartPayload struct {
+	Id         string    `json:"id"`
+	StartAt    time.Time `json:"startAt"`
+	EndsAt     time.Time `json:"endsAt"`
+	ServerTime time.Time `json:"serverTime"`
+}
+
+func NewMediaPreloadInternalMessage(mediaState *entities.MediaState) ws.InternalMessage {
+	payload, _ := json.Marshal(MediaPreloadPayload{
+		Id:             mediaState.Id,
+		Attachment:     mediaState.Attachment,
+		BufferDeadline: mediaState.BufferDeadline,
+	})
+	return ws.InternalMessage{
+		From: entities.SYSTEM,
+		Message: ws.Message{
+			Event:   MEDIA_PRELOAD,
+			Payload: payload,
+		},
+	}
+}
+
+func NewMediaStartInternalMessage(mediaState *entities.MediaState) ws.InternalMessage {
+	payload, _ := json.Marshal(MediaStartPayload{
+		Id:         mediaState.Id,
+		StartAt:    *mediaState.StartAt,
+		EndsAt:     *mediaState.EndsAt,
+		ServerTime: time.Now(),
+	})
+	return ws.InternalMessage{
+		From: entities.SYSTEM,
+		Message: ws.Message{
+			Event:   MEDIA_START,
+			Payload: payload,
+		},
+	}
+}
+
+func HandleRdsMediaBufferedMessage(rds *redis.Client, wsConn *ws.WsConn, roomId primitive.ObjectID, msg ws.InternalMessage) {
+	var mbp MediaBufferedPayload
+	if err := json.Unmarshal(msg.Payload, &mbp); err != nil {
+		wsConn.PublishError(custErrors.NewHttpError(http.StatusBadRequest, custErrors.MalformedMessage, err.Error()), msg.Id)
+		return
+	}
+	if mbp.Duration < 0 {
+		wsConn.PublishError(custErrors.NewHttpError(http.StatusBadRequest, custErrors.NegativeDuration), msg.Id)
+		return
+	}
+
+	var startedMedia *entities.MediaState
+	err := api.TryUpdateRoom(rds, roomId, func(tx *redis.Tx) error {
+		room, httpErr := entities.GetRoomById(rds, roomId)
+		if httpErr != nil {
+			return httpErr
+		}
+
+		if room.MediaState == nil || room.MediaState.Id != mbp.Id || room.MediaState.Phase != entities.MediaBuffering {
+			return custErrors.NewHttpError(http.StatusConflict, custErrors.NoMediaBuffering)
+		}
+		if !room.IsUserHost(msg.From.Id) && !room.IsUserPlayer(msg.From.Id) {
+			return custErrors.NewHttpError(http.StatusForbidden, custErrors.NotAllowedToReportBuffering)
+		}
+		if !slices.Contains(room.MediaState.BufferedBy, msg.From.Id) {
+			room.MediaState.BufferedBy = append(room.MediaState.BufferedBy, msg.From.Id)
+		}
+		room.MediaState.Durations[msg.From.Id.Hex()] = mbp.Duration
+
+		if room.IsMediaBuffered() {
+			room.StartMedia()
+			startedMedia = room.MediaState
+		}
+
+		_, err := tx.Pipelined(context.TODO(), func(p redis.Pipeliner) error {
+			roomKey := entities.GetRoomRedisKey(room.Id.Hex())
+			p.JSONSet(context.TODO(), roomKey, "$.mediaState", room.MediaState)
+			return nil
+		})
+		return err
+	}, UPDATE_ROOM_RETRIES)
+	if err != nil {
+		wsConn.PublishError(err, msg.Id)
+		return
+	}
+
+	if startedMedia != nil {
+		if err := publishMediaStart(rds, roomId, startedMedia); err != nil {
+			wsConn.PublishError(err, msg.Id)
+			return
+		}
+	}
+}
+
+// Starts the playback without waiting for clients that have not buffered media by the deadline
+func StartMediaOnTimeout(rds *redis.Client, roomId primitive.ObjectID, mediaState *entities.MediaState) {
+	time.AfterFunc(time.Until(mediaState.BufferDeadline), func() {
+		var startedMedia *entities.MediaState
+		err := api.TryUpdateRoom(rds, roomId, func(tx *redis.Tx) error {
+			room, httpErr := entities.GetRoomById(rds, roomId)
+			if httpErr != nil {
+				return httpErr
+			}
+
+			// already started by the last client to buffer, or the question is over
+			if room.MediaState == nil || room.MediaState.Id != mediaState.Id || room.MediaState.Phase != entities.MediaBuffering {
+				return nil
+			}
+			room.StartMedia()
+			startedMedia = room.MediaState
+
+			_, err := tx.Pipelined(context.TODO(), func(p redis.Pipeliner) error {
+				roomKey := entities.GetRoomRedisKey(room.Id.Hex())
+				p.JSONSet(context.TODO(), roomKey, "$.mediaState", room.MediaState)
+				return nil
+			})
+			return err
+		}, UPDATE_ROOM_RETRIES)
+		if err != nil {
+			log.Printf("Error while starting media \"%s\" on timeout: %v\n", mediaState.Id, err)
+			return
+		}
+		if startedMedia == nil {
+			return
+		}
+		if err := publishMediaStart(rds, roomId, startedMedia); err != nil {
+			log.Printf("Error while publishing start of media \"%s\": %v\n", mediaState.Id, err)
+		}
+	})
+}
+
+func publishMediaStart(rds *redis.Client, roomId primitive.ObjectID, mediaState *entities.MediaState) error {
+	roomKey := entities.GetRoomRedisKey(roomId.Hex())
+	if err := ws.PublishRdsMessage(rds, roomKey, NewMediaStartInternalMessage(mediaState)); err != nil {
+		return err
+	}
+	if err := ws.PublishRdsMessage(rds, roomKey, RoomInternalMessage()); err != nil {
+		return err
+	}
+	armBuzzingAfterPlayback(rds, roomId, mediaState)
+	return nil
+}
+
+func armBuzzingAfterPlayback(rds *redis.Client, roomId primitive.ObjectID, mediaState *entities.MediaState) {
+	time.AfterFunc(time.Until(*mediaState.EndsAt), func() {
+		armed := false
+		err := api.TryUpdateRoom(rds, roomId, func(tx *redis.Tx) error {
+			room, httpErr := entities.GetRoomById(rds, roomId)
+			if httpErr != nil {
+				return httpErr
+			}
+
+			if room.MediaState == nil || room.MediaState.Id != mediaState.Id || room.MediaState.Phase != entities.MediaPlaying {
+				return nil
+			}
+			room.ArmBuzzing()
+			armed = true
+
+			_, err := tx.Pipelined(context.TODO(), func(p redis.Pipeliner) error {
+				roomKey := entities.GetRoomRedisKey(room.Id.Hex())
+				p.JSONSet(context.TODO(), roomKey, "$.mediaState", room.MediaState)
+				p.JSONSet(context.TODO(), roomKey, "$.allowedToAnswer", room.AllowedToAnswer)
+				return nil
+			})
+			return err
+		}, UPDATE_ROOM_RETRIES)
+		if err != nil {
+			log.Printf("Error while arming buzzing after media \"%s\": %v\n", mediaState.Id, err)
+			return
+		}
+		if !armed {
+			return
+		}
+		if err := ws.PublishRdsMessage(rds, entities.GetRoomRedisKey(roomId.Hex()), RoomInternalMessage()); err != nil {
+			log.Println("Error while publishing room:", err)
+		}
+	})
+}
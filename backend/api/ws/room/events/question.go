@@ -3,11 +3,13 @@ package events
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"net/http"
 	"slices"
 
+	"github.com/google/uuid"
 	"github.com/holdennekt/sgame/api/ws"
+	"github.com/holdennekt/sgame/custErrors"
 	"github.com/holdennekt/sgame/entities"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -26,15 +28,19 @@ type QuestionPayload struct {
 }
 
 func HandleRdsQuestionMessage(rds *redis.Client, wsConn *ws.WsConn, pubSubConn *ws.PubSubConn, pack *entities.Pack, roomId primitive.ObjectID, msg ws.InternalMessage) {
-	room, _ := entities.GetRoomById(rds, roomId)
+	room, httpErr := entities.GetRoomById(rds, roomId)
+	if httpErr != nil {
+		wsConn.PublishError(httpErr, msg.Id)
+		return
+	}
 	if room.CurrentRound == nil || room.AvailableQuestions == nil || room.CurrentPlayer == nil || *room.CurrentPlayer != msg.From.Id {
-		wsConn.PublishError(errors.New("not allowed to choose"))
+		wsConn.PublishError(custErrors.NewHttpError(http.StatusForbidden, custErrors.NotAllowedToChoose), msg.Id)
 		return
 	}
 
 	var qp QuestionPayload
 	if err := json.Unmarshal(msg.Payload, &qp); err != nil {
-		wsConn.PublishError(err)
+		wsConn.PublishError(custErrors.NewHttpError(http.StatusBadRequest, custErrors.MalformedMessage, err.Error()), msg.Id)
 		return
 	}
 
@@ -42,14 +48,15 @@ func HandleRdsQuestionMessage(rds *redis.Client, wsConn *ws.WsConn, pubSubConn *
 		return bq.Index == qp.Index
 	})
 	if room.AvailableQuestions[qp.Category] == nil || boardQuestionIndex == -1 {
-		wsConn.PublishError(errors.New("no such question in current round"))
+		wsConn.PublishError(custErrors.NewHttpError(http.StatusNotFound, custErrors.QuestionNotInRound), msg.Id)
 		return
 	}
-	if !room.AvailableQuestions[qp.Category][boardQuestionIndex].HasBeenPlayed {
-		wsConn.PublishError(errors.New("question has already been played"))
+	if room.AvailableQuestions[qp.Category][boardQuestionIndex].HasBeenPlayed {
+		wsConn.PublishError(custErrors.NewHttpError(http.StatusConflict, custErrors.QuestionAlreadyPlayed), msg.Id)
 		return
 	}
 
+	pack = room.PackInPlay(pack)
 	roundIndex := slices.IndexFunc(pack.Rounds, func(r entities.Round) bool {
 		return *room.CurrentRound == r.Name
 	})
@@ -64,30 +71,51 @@ func HandleRdsQuestionMessage(rds *redis.Client, wsConn *ws.WsConn, pubSubConn *
 	question := category.Questions[questionIndex]
 	room.CurrentQuestion = &question
 
-	allowedToAnswer := make([]primitive.ObjectID, len(room.Players))
-	for i, player := range room.Players {
-		allowedToAnswer[i] = player.Id
+	room.AllowedToAnswer = room.PlayerIds()
+	// buzzing for audio and video is armed once every client has played it
+	if question.HasPlayableMedia() {
+		room.PrepareMedia(uuid.NewString(), *question.Attachment)
 	}
-	room.AllowedToAnswer = allowedToAnswer
 
 	room.AvailableQuestions[qp.Category][boardQuestionIndex].HasBeenPlayed = true
+	playedQuestion := entities.QuestionRef{
+		Round:    *room.CurrentRound,
+		Category: qp.Category,
+		Index:    qp.Index,
+	}
+	// questions of random packs are remembered as the ones of the packs they were taken from
+	if question.Source != nil {
+		playedQuestion = *question.Source
+	}
+	room.PlayedQuestions = append(room.PlayedQuestions, playedQuestion)
 
 	roomKey := entities.GetRoomRedisKey(room.Id.Hex())
 	_, err := rds.TxPipelined(context.TODO(), func(p redis.Pipeliner) error {
 		p.JSONSet(context.TODO(), roomKey, "$.currentQuestion", room.CurrentQuestion)
 		p.JSONSet(context.TODO(), roomKey, "$.allowedToAnswer", room.AllowedToAnswer)
+		p.JSONSet(context.TODO(), roomKey, "$.mediaState", room.MediaState)
+		p.JSONSet(context.TODO(), roomKey, "$.playedQuestions", room.PlayedQuestions)
 		path := fmt.Sprintf("$.availableQuestions.%s", qp.Category)
 		p.JSONSet(context.TODO(), roomKey, path, room.AvailableQuestions[qp.Category])
 		return nil
 	})
 	if err != nil {
-		wsConn.PublishError(err)
+		wsConn.PublishError(err, msg.Id)
 		return
 	}
 
+	if room.MediaState != nil {
+		mediaPreloadMessage := NewMediaPreloadInternalMessage(room.MediaState)
+		if err := pubSubConn.Publish(mediaPreloadMessage); err != nil {
+			wsConn.PublishError(err, msg.Id)
+			return
+		}
+		StartMediaOnTimeout(rds, room.Id, room.MediaState)
+	}
+
 	roomMessage := RoomInternalMessage()
 	if err := pubSubConn.Publish(roomMessage); err != nil {
-		wsConn.PublishError(err)
+		wsConn.PublishError(err, msg.Id)
 		return
 	}
 }
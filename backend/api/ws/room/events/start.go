@@ -2,14 +2,16 @@ package events
 
 import (
 	"context"
-	"errors"
 	"math/rand"
+	"net/http"
 
 	"github.com/holdennekt/sgame/api"
 	"github.com/holdennekt/sgame/api/ws"
+	"github.com/holdennekt/sgame/custErrors"
 	"github.com/holdennekt/sgame/entities"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 const START ws.Event = "start"
@@ -18,18 +20,28 @@ type StartMessage struct {
 	Event ws.Event `json:"event"`
 }
 
-func HandleRdsStartMessage(rds *redis.Client, wsConn *ws.WsConn, pubSubConn *ws.PubSubConn, pack *entities.Pack, roomId primitive.ObjectID, msg ws.InternalMessage) {
+func HandleRdsStartMessage(mdb *mongo.Database, rds *redis.Client, wsConn *ws.WsConn, pubSubConn *ws.PubSubConn, pack *entities.Pack, roomId primitive.ObjectID, msg ws.InternalMessage) {
 	err := api.TryUpdateRoom(rds, roomId, func(tx *redis.Tx) error {
 		room, httpErr := entities.GetRoomById(rds, roomId)
 		if httpErr != nil {
 			return httpErr
 		}
 
-		if !room.IsUserHost(msg.From.Id) || len(room.Players) == 0 {
-			return errors.New("not allowed to start game")
+		if !room.IsUserHost(msg.From.Id) || len(room.Players) == 0 || room.IsStarted() || room.IsFinished() {
+			return custErrors.NewHttpError(http.StatusForbidden, custErrors.NotAllowedToStart)
 		}
 
-		room.StartNextRound(pack)
+		// the pack is assembled again to leave out questions the joined players have seen
+		if room.IsRandom() {
+			generatedPack, httpErr := entities.GenerateRandomPack(mdb, *room.Random, room.PlayerIds())
+			if httpErr != nil {
+				return httpErr
+			}
+			room.GeneratedPack = generatedPack
+			room.PackPreview = entities.NewPackPreview(generatedPack)
+		}
+
+		room.StartNextRound(room.PackInPlay(pack))
 		room.CurrentPlayer = &room.Players[rand.Intn(len(room.Players))].Id
 
 		_, err := tx.Pipelined(context.TODO(), func(p redis.Pipeliner) error {
@@ -40,13 +52,13 @@ func HandleRdsStartMessage(rds *redis.Client, wsConn *ws.WsConn, pubSubConn *ws.
 		return err
 	}, UPDATE_ROOM_RETRIES)
 	if err != nil {
-		wsConn.PublishError(err)
+		wsConn.PublishError(err, msg.Id)
 		return
 	}
 
 	roomMessage := RoomInternalMessage()
 	if err := pubSubConn.Publish(roomMessage); err != nil {
-		wsConn.PublishError(err)
+		wsConn.PublishError(err, msg.Id)
 		return
 	}
 }
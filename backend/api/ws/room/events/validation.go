@@ -3,14 +3,16 @@ package events
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"net/http"
 	"slices"
 
 	"github.com/holdennekt/sgame/api"
 	"github.com/holdennekt/sgame/api/ws"
+	"github.com/holdennekt/sgame/custErrors"
 	"github.com/holdennekt/sgame/entities"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 const VALIDATION ws.Event = "validation"
@@ -24,22 +26,27 @@ type ValidationPayload struct {
 	IsCorrect bool `json:"isCorrect"`
 }
 
-func HandleRdsValidationMessage(rds *redis.Client, wsConn *ws.WsConn, pubSubConn *ws.PubSubConn, pack *entities.Pack, roomId primitive.ObjectID, msg ws.InternalMessage) {
-	room, _ := entities.GetRoomById(rds, roomId)
+func HandleRdsValidationMessage(mdb *mongo.Database, rds *redis.Client, wsConn *ws.WsConn, pubSubConn *ws.PubSubConn, pack *entities.Pack, roomId primitive.ObjectID, msg ws.InternalMessage) {
+	room, httpErr := entities.GetRoomById(rds, roomId)
+	if httpErr != nil {
+		wsConn.PublishError(httpErr, msg.Id)
+		return
+	}
 
 	if room.FinalRoundState.IsActive || !room.IsUserHost(msg.From.Id) || room.AnsweringPlayer == nil {
-		wsConn.PublishError(errors.New("can not validate"))
+		wsConn.PublishError(custErrors.NewHttpError(http.StatusForbidden, custErrors.NotAllowedToValidate), msg.Id)
 		return
 	}
 
 	var vp ValidationPayload
 	if err := json.Unmarshal(msg.Payload, &vp); err != nil {
-		wsConn.PublishError(err)
+		wsConn.PublishError(custErrors.NewHttpError(http.StatusBadRequest, custErrors.MalformedMessage, err.Error()), msg.Id)
 		return
 	}
 
 	currentQuestion := *room.CurrentQuestion
 
+	var finishedRoom *entities.Room
 	err := api.TryUpdateRoom(rds, roomId, func(tx *redis.Tx) error {
 		room, httpErr := entities.GetRoomById(rds, roomId)
 		if httpErr != nil {
@@ -50,7 +57,7 @@ func HandleRdsValidationMessage(rds *redis.Client, wsConn *ws.WsConn, pubSubConn
 			return *room.AnsweringPlayer == p.Id
 		})
 		if playerIndex == -1 {
-			return errors.New("no such player in room")
+			return custErrors.NewHttpError(http.StatusNotFound, custErrors.PlayerNotInRoom)
 		}
 
 		roomKey := entities.GetRoomRedisKey(room.Id.Hex())
@@ -65,7 +72,10 @@ func HandleRdsValidationMessage(rds *redis.Client, wsConn *ws.WsConn, pubSubConn
 
 			isEndOfQuestion := vp.IsCorrect || len(room.AllowedToAnswer) == 0
 			if isEndOfQuestion {
-				room.EndQuestion(pack)
+				room.EndQuestion(room.PackInPlay(pack))
+			}
+			if room.IsFinished() {
+				finishedRoom = room
 			}
 
 			p.JSONSet(context.TODO(), roomKey, "$", room)
@@ -74,19 +84,26 @@ func HandleRdsValidationMessage(rds *redis.Client, wsConn *ws.WsConn, pubSubConn
 		return err
 	}, UPDATE_ROOM_RETRIES)
 	if err != nil {
-		wsConn.PublishError(err)
+		wsConn.PublishError(err, msg.Id)
 		return
 	}
 
+	if finishedRoom != nil {
+		if err := RecordFinishedMatch(mdb, rds, finishedRoom); err != nil {
+			wsConn.PublishError(err, msg.Id)
+			return
+		}
+	}
+
 	correctAnswerMessage := NewCorrectAnswerInternalMessage(currentQuestion.Answers)
 	if err := pubSubConn.Publish(correctAnswerMessage); err != nil {
-		wsConn.PublishError(err)
+		wsConn.PublishError(err, msg.Id)
 		return
 	}
 
 	roomMessage := RoomInternalMessage()
 	if err := pubSubConn.Publish(roomMessage); err != nil {
-		wsConn.PublishError(err)
+		wsConn.PublishError(err, msg.Id)
 		return
 	}
 }
@@ -2,8 +2,10 @@ package wsRoom
 
 import (
 	"encoding/json"
+	"log"
 
 	"github.com/holdennekt/sgame/api/ws"
+	lobbyEvents "github.com/holdennekt/sgame/api/ws/lobby/events"
 	"github.com/holdennekt/sgame/api/ws/room/events"
 	"github.com/holdennekt/sgame/entities"
 	"github.com/redis/go-redis/v9"
@@ -15,11 +17,40 @@ func handleRdsMessage(mdb *mongo.Database, rds *redis.Client, wsConn *ws.WsConn,
 	switch msg.Event {
 	case events.ROOM:
 		handleRdsRoomMessage(rds, wsConn, room.Id, userId)
+	case lobbyEvents.ROOM_DELETED:
+		handleRdsRoomDeletedMessage(wsConn, msg)
+	case ws.DISCONNECT:
+		handleRdsDisconnectMessage(wsConn, msg)
 	}
 }
 
+func handleRdsDisconnectMessage(wsConn *ws.WsConn, msg ws.InternalMessage) {
+	if err := wsConn.Publish(msg.Message); err != nil {
+		log.Println("Error while publishing disconnect:", err)
+	}
+	wsConn.Conn.Close()
+}
+
+func handleRdsRoomDeletedMessage(wsConn *ws.WsConn, msg ws.InternalMessage) {
+	if err := wsConn.Publish(ws.Message{Event: lobbyEvents.ROOM_DELETED, Payload: msg.Payload}); err != nil {
+		log.Println("Error while publishing room deletion:", err)
+	}
+	wsConn.Conn.Close()
+}
+
+// Not caused by a message of the client, so errors do not reference one
 func handleRdsRoomMessage(rds *redis.Client, wsConn *ws.WsConn, roomId primitive.ObjectID, userId primitive.ObjectID) {
-	room, _ := entities.GetRoomById(rds, roomId)
-	payload, _ := json.Marshal(room.GetProjection(userId))
-	wsConn.Publish(ws.Message{Event: events.ROOM, Payload: payload})
+	room, httpErr := entities.GetRoomById(rds, roomId)
+	if httpErr != nil {
+		wsConn.PublishError(httpErr, "")
+		return
+	}
+	payload, err := json.Marshal(room.GetProjection(userId))
+	if err != nil {
+		wsConn.PublishError(err, "")
+		return
+	}
+	if err := wsConn.Publish(ws.Message{Event: events.ROOM, Payload: payload}); err != nil {
+		log.Println("Error while publishing room:", err)
+	}
 }
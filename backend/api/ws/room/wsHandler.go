@@ -2,6 +2,7 @@ package wsRoom
 
 import (
 	"context"
+	"log"
 	"slices"
 	"time"
 
@@ -21,19 +22,29 @@ const UPDATE_ROOM_RETRIES = 3
 func handleWsMessage(mdb *mongo.Database, rds *redis.Client, wsConn *ws.WsConn, pubSubConn *ws.PubSubConn, pack *entities.Pack, roomId primitive.ObjectID, msg ws.InternalMessage) {
 	switch msg.Event {
 	case lobbyEvents.CHAT:
-		lobbyEvents.HandleWsChatMessage(pubSubConn, msg)
+		lobbyEvents.HandleWsChatMessage(wsConn, pubSubConn, msg)
+	case roomEvents.START:
+		roomEvents.HandleRdsStartMessage(mdb, rds, wsConn, pubSubConn, pack, roomId, msg)
 	case roomEvents.QUESTION:
 		roomEvents.HandleRdsQuestionMessage(rds, wsConn, pubSubConn, pack, roomId, msg)
 	case roomEvents.ANSWER:
 		roomEvents.HandleRdsAnswerMessage(rds, wsConn, pubSubConn, roomId, msg)
 	case roomEvents.VALIDATION:
-		roomEvents.HandleRdsValidationMessage(rds, wsConn, pubSubConn, pack, roomId, msg)
+		roomEvents.HandleRdsValidationMessage(mdb, rds, wsConn, pubSubConn, pack, roomId, msg)
+	case roomEvents.FINISH:
+		roomEvents.HandleRdsFinishMessage(mdb, rds, wsConn, pubSubConn, roomId, msg)
+	case roomEvents.MEDIA_BUFFERED:
+		roomEvents.HandleRdsMediaBufferedMessage(rds, wsConn, roomId, msg)
 	}
 }
 
 func handleWsClosure(rds *redis.Client, pubSubConn *ws.PubSubConn, userId primitive.ObjectID, roomId primitive.ObjectID) {
 	roomKey := entities.GetRoomRedisKey(roomId.Hex())
-	room, _ := entities.GetRoomByKey(rds, roomKey)
+	room, httpErr := entities.GetRoomByKey(rds, roomKey)
+	if httpErr != nil {
+		pubSubConn.Conn.Close()
+		return
+	}
 
 	err := api.TryUpdateRoom(rds, room.Id, func(tx *redis.Tx) error {
 		room, httpErr := entities.GetRoomByKey(rds, roomKey)
@@ -42,8 +53,14 @@ func handleWsClosure(rds *redis.Client, pubSubConn *ws.PubSubConn, userId primit
 		}
 
 		isGameStarted := room.CurrentRound == nil && !room.FinalRoundState.IsActive
-		if isGameStarted {
-			if room.IsUserHost(userId) {
+		// the host is removed from the room below, so it is checked beforehand
+		isHost := room.IsUserHost(userId)
+		if room.IsUserSpectator(userId) {
+			room.Spectators = slices.DeleteFunc(room.Spectators, func(s entities.Spectator) bool {
+				return userId == s.Id
+			})
+		} else if isGameStarted {
+			if isHost {
 				room.Host = nil
 			} else {
 				room.Players = slices.DeleteFunc(room.Players, func(p entities.Player) bool {
@@ -51,7 +68,7 @@ func handleWsClosure(rds *redis.Client, pubSubConn *ws.PubSubConn, userId primit
 				})
 			}
 		} else {
-			if room.IsUserHost(userId) {
+			if isHost {
 				room.Host.IsConnected = false
 			} else {
 				i := slices.IndexFunc(room.Players, func(p entities.Player) bool {
@@ -64,14 +81,15 @@ func handleWsClosure(rds *redis.Client, pubSubConn *ws.PubSubConn, userId primit
 		connectedPlayerIndex := slices.IndexFunc(room.Players, func(p entities.Player) bool {
 			return p.IsConnected
 		})
-		isAnyConnectedUser := room.Host.IsConnected || connectedPlayerIndex != -1
+		isAnyConnectedUser := (room.Host != nil && room.Host.IsConnected) || connectedPlayerIndex != -1
 
 		_, err := tx.Pipelined(context.TODO(), func(p redis.Pipeliner) error {
-			if room.IsUserHost(userId) {
+			if isHost {
 				p.JSONSet(context.TODO(), roomKey, "$.host", room.Host)
 			} else {
 				p.JSONSet(context.TODO(), roomKey, "$.players", room.Players)
 			}
+			p.JSONSet(context.TODO(), roomKey, "$.spectators", room.Spectators)
 			if !isAnyConnectedUser {
 				p.Expire(context.TODO(), roomKey, 5*time.Minute)
 			}
@@ -80,14 +98,20 @@ func handleWsClosure(rds *redis.Client, pubSubConn *ws.PubSubConn, userId primit
 		return err
 	}, UPDATE_ROOM_RETRIES)
 	if err != nil {
+		log.Printf("Error while disconnecting user \"%s\" from room \"%s\": %v\n", userId, roomId, err)
+		pubSubConn.Conn.Close()
 		return
 	}
 
 	roomMessage := roomEvents.RoomInternalMessage()
-	pubSubConn.Publish(roomMessage)
+	if err := pubSubConn.Publish(roomMessage); err != nil {
+		log.Println("Error while publishing room:", err)
+	}
 
 	lobbyRoomMessage := lobbyEvents.NewLobbyRoomInternalMessage(room)
-	ws.PublishRdsMessage(rds, wsLobby.LOBBY, lobbyRoomMessage)
+	if err := ws.PublishRdsMessage(rds, wsLobby.LOBBY, lobbyRoomMessage); err != nil {
+		log.Println("Error while publishing lobby room:", err)
+	}
 
 	pubSubConn.Conn.Close()
 }
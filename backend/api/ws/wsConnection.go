@@ -4,16 +4,21 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/holdennekt/sgame/custErrors"
 	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/i18n"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Event string
 
 type Message struct {
+	// Set by clients to match errors with the messages that have caused them
+	Id      string          `json:"id,omitempty"`
 	Event   Event           `json:"event"`
 	Payload json.RawMessage `json:"payload"`
 }
@@ -28,17 +33,24 @@ type InternalMessage struct {
 }
 
 type WsConn struct {
-	userId       primitive.ObjectID
-	Conn         *websocket.Conn
-	Messages     <-chan InternalMessage
-	Publish      func(message Message) error
-	PublishError func(err error) error
+	userId   primitive.ObjectID
+	Conn     *websocket.Conn
+	Messages <-chan InternalMessage
+	Publish  func(message Message) error
+	// Errors are translated to the language of the client that has connected,
+	// messageId is the id of the client message that has caused the error
+	PublishError func(err error, messageId string) error
 }
 
+// Clients authorizing with "token.<token>" subprotocol must also offer this one,
+// because the server has to select some subprotocol for the browser to accept the connection
+const WS_SUBPROTOCOL = "sgame"
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
+	Subprotocols: []string{WS_SUBPROTOCOL},
 }
 
 func ConnectUserToWs(c *gin.Context, user entities.User) (*WsConn, error) {
@@ -47,22 +59,32 @@ func ConnectUserToWs(c *gin.Context, user entities.User) (*WsConn, error) {
 		return nil, err
 	}
 
+	lang := i18n.FromContext(c)
+	// errors about unreadable messages are written by the reading goroutine,
+	// while connections support only one concurrent writer
+	var writeMu sync.Mutex
+	writeJSON := func(v any) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(v)
+	}
+	publishError := func(err error, messageId string) error {
+		return writeJSON(NewErrorMessage(err, lang, messageId))
+	}
 	wc := &WsConn{
 		userId:   user.Id,
 		Conn:     conn,
-		Messages: getMesasgesChannel(conn, user),
+		Messages: getMesasgesChannel(conn, user, publishError),
 		Publish: func(message Message) error {
-			return conn.WriteJSON(message)
-		},
-		PublishError: func(err error) error {
-			return conn.WriteJSON(NewErrorMessage(err))
+			return writeJSON(message)
 		},
+		PublishError: publishError,
 	}
 
 	return wc, nil
 }
 
-func getMesasgesChannel(conn *websocket.Conn, user entities.User) <-chan InternalMessage {
+func getMesasgesChannel(conn *websocket.Conn, user entities.User, publishError func(err error, messageId string) error) <-chan InternalMessage {
 	messages := make(chan InternalMessage)
 	go func() {
 		for {
@@ -74,6 +96,7 @@ func getMesasgesChannel(conn *websocket.Conn, user entities.User) <-chan Interna
 			}
 			if err := json.NewDecoder(r).Decode(&msg); err != nil {
 				log.Println("Error while decoding incoming wsMessage:", err)
+				publishError(custErrors.NewHttpError(http.StatusBadRequest, custErrors.MalformedMessage, err.Error()), "")
 				continue
 			}
 			messages <- InternalMessage{Message: msg, From: user}
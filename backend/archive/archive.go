@@ -0,0 +1,321 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/entities"
+)
+
+const FORMAT_NAME = "sgame-pack"
+const FORMAT_VERSION = 1
+const MANIFEST_FILE = "manifest.json"
+const MEDIA_DIR = "media/"
+const URL_SCHEME = "archive:"
+
+const MAX_MANIFEST_SIZE = 10 << 20
+const MAX_MEDIA_SIZE = 100 << 20
+
+var ErrInvalidArchive = errors.New("invalid pack archive")
+
+type Manifest struct {
+	Format     string           `json:"format"`
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exportedAt"`
+	Pack       entities.PackDTO `json:"pack"`
+	Media      []MediaFile      `json:"media"`
+}
+
+type MediaFile struct {
+	Path        string             `json:"path"`
+	MediaType   entities.MediaType `json:"mediaType"`
+	ContentType string             `json:"contentType"`
+	SourceUrl   *string            `json:"sourceUrl"`
+}
+
+// Collects every problem found in the archive instead of stopping at the first one
+type ValidationError struct {
+	Errors []string
+}
+
+func (ve *ValidationError) Error() string {
+	return strings.Join(ve.Errors, ", ")
+}
+
+func (ve *ValidationError) add(format string, args ...any) {
+	ve.Errors = append(ve.Errors, fmt.Sprintf(format, args...))
+}
+
+// Attachment that could not be fetched while bundling media
+type FetchError struct {
+	Url string
+	Err error
+}
+
+func (fe *FetchError) Error() string {
+	return fmt.Sprintf("can not fetch \"%s\": %s", fe.Url, fe.Err)
+}
+
+func (fe *FetchError) Unwrap() error {
+	return fe.Err
+}
+
+type Archive struct {
+	Manifest Manifest
+	files    map[string]*zip.File
+}
+
+// Opens bundled file referenced by "archive:<path>" url
+func (a *Archive) Open(mediaFile MediaFile) (io.ReadCloser, error) {
+	file, ok := a.files[mediaFile.Path]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing file \"%s\"", ErrInvalidArchive, mediaFile.Path)
+	}
+	return file.Open()
+}
+
+func (a *Archive) GetMediaFile(contentUrl string) (MediaFile, bool) {
+	filePath, found := strings.CutPrefix(contentUrl, URL_SCHEME)
+	if !found {
+		return MediaFile{}, false
+	}
+	i := slices.IndexFunc(a.Manifest.Media, func(mf MediaFile) bool {
+		return mf.Path == filePath
+	})
+	if i == -1 {
+		return MediaFile{}, false
+	}
+	return a.Manifest.Media[i], true
+}
+
+// Replaces every "archive:" url with the one returned by store, which is
+// expected to persist the bundled file somewhere reachable
+func (a *Archive) ResolveMedia(store func(mediaFile MediaFile, r io.Reader) (string, error)) error {
+	for _, attachment := range a.Manifest.Pack.Attachments() {
+		mediaFile, ok := a.GetMediaFile(attachment.ContentUrl)
+		if !ok {
+			continue
+		}
+
+		r, err := a.Open(mediaFile)
+		if err != nil {
+			return err
+		}
+		url, err := store(mediaFile, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		attachment.ContentUrl = url
+	}
+	return nil
+}
+
+func Read(r io.ReaderAt, size int64) (*Archive, error) {
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+
+	files := make(map[string]*zip.File, len(zipReader.File))
+	for _, file := range zipReader.File {
+		files[file.Name] = file
+	}
+
+	manifestFile, ok := files[MANIFEST_FILE]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidArchive, MANIFEST_FILE)
+	}
+	if manifestFile.UncompressedSize64 > MAX_MANIFEST_SIZE {
+		return nil, fmt.Errorf("%w: %s is too large", ErrInvalidArchive, MANIFEST_FILE)
+	}
+	manifestReader, err := manifestFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+	defer manifestReader.Close()
+
+	archive := &Archive{files: files}
+	decoder := json.NewDecoder(io.LimitReader(manifestReader, MAX_MANIFEST_SIZE))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&archive.Manifest); err != nil {
+		return nil, &ValidationError{Errors: []string{fmt.Sprintf("%s: %s", MANIFEST_FILE, err)}}
+	}
+
+	if err := archive.validate(); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+func (a *Archive) validate() error {
+	manifest := a.Manifest
+	ve := &ValidationError{}
+
+	if manifest.Format != FORMAT_NAME {
+		ve.add("format must be \"%s\"", FORMAT_NAME)
+		return ve
+	}
+	if manifest.Version < 1 || manifest.Version > FORMAT_VERSION {
+		ve.add("version %d is not supported, the latest supported is %d", manifest.Version, FORMAT_VERSION)
+		return ve
+	}
+
+	if err := binding.Validator.ValidateStruct(manifest.Pack); err != nil {
+		ve.Errors = append(ve.Errors, custErrors.ParseValidationErrors(err)...)
+	}
+
+	for i, mediaFile := range manifest.Media {
+		if _, ok := a.files[mediaFile.Path]; !ok {
+			ve.add("media[%d].path \"%s\" is not in the archive", i, mediaFile.Path)
+		} else if a.files[mediaFile.Path].UncompressedSize64 > MAX_MEDIA_SIZE {
+			ve.add("media[%d].path \"%s\" is larger than %d bytes", i, mediaFile.Path, MAX_MEDIA_SIZE)
+		}
+		switch mediaFile.MediaType {
+		case entities.Image, entities.Audio, entities.Video:
+		default:
+			ve.add("media[%d].mediaType must be one of image, audio, video", i)
+		}
+	}
+
+	for _, attachment := range manifest.Pack.Attachments() {
+		if !strings.HasPrefix(attachment.ContentUrl, URL_SCHEME) {
+			continue
+		}
+		mediaFile, ok := a.GetMediaFile(attachment.ContentUrl)
+		if !ok {
+			ve.add("attachment \"%s\" is not listed in media", attachment.ContentUrl)
+			continue
+		}
+		if mediaFile.MediaType != attachment.MediaType {
+			ve.add("attachment \"%s\" has mediaType %s, but media lists it as %s", attachment.ContentUrl, attachment.MediaType, mediaFile.MediaType)
+		}
+	}
+
+	if len(ve.Errors) > 0 {
+		return ve
+	}
+	return nil
+}
+
+// Gives content and content type of media at the url
+type Fetcher func(url string) ([]byte, string, error)
+
+// Fetcher requesting urls with the client
+func Downloader(client *http.Client) Fetcher {
+	return func(url string) ([]byte, string, error) {
+		return download(client, url)
+	}
+}
+
+// Writes the pack as an archive. With bundleMedia every attachment is
+// fetched and put into the archive, otherwise only urls are kept
+func Write(w io.Writer, packDTO entities.PackDTO, bundleMedia bool, fetch Fetcher) error {
+	manifest := Manifest{
+		Format:     FORMAT_NAME,
+		Version:    FORMAT_VERSION,
+		ExportedAt: time.Now().UTC(),
+		Pack:       packDTO.Clone(),
+		Media:      make([]MediaFile, 0),
+	}
+	manifest.Pack.ClearBankRefs()
+
+	zipWriter := zip.NewWriter(w)
+
+	if bundleMedia {
+		bundled := make(map[string]string)
+		for _, attachment := range manifest.Pack.Attachments() {
+			if archiveUrl, ok := bundled[attachment.ContentUrl]; ok {
+				attachment.ContentUrl = archiveUrl
+				continue
+			}
+
+			sourceUrl := attachment.ContentUrl
+			content, contentType, err := fetch(sourceUrl)
+			if err != nil {
+				return &FetchError{Url: sourceUrl, Err: err}
+			}
+
+			filePath := MEDIA_DIR + fmt.Sprint(len(manifest.Media)) + extensionFor(contentType, sourceUrl)
+			fileWriter, err := zipWriter.CreateHeader(&zip.FileHeader{Name: filePath, Method: zip.Store})
+			if err != nil {
+				return err
+			}
+			if _, err := fileWriter.Write(content); err != nil {
+				return err
+			}
+
+			manifest.Media = append(manifest.Media, MediaFile{
+				Path:        filePath,
+				MediaType:   attachment.MediaType,
+				ContentType: contentType,
+				SourceUrl:   &sourceUrl,
+			})
+			bundled[sourceUrl] = URL_SCHEME + filePath
+			attachment.ContentUrl = URL_SCHEME + filePath
+		}
+	}
+
+	manifestWriter, err := zipWriter.Create(MANIFEST_FILE)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(manifestWriter)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return err
+	}
+
+	return zipWriter.Close()
+}
+
+func download(client *http.Client, url string) ([]byte, string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("can not download \"%s\": status %d", url, resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, MAX_MEDIA_SIZE+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(content) > MAX_MEDIA_SIZE {
+		return nil, "", fmt.Errorf("can not download \"%s\": larger than %d bytes", url, MAX_MEDIA_SIZE)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(content)
+	}
+	return content, contentType, nil
+}
+
+func extensionFor(contentType string, sourceUrl string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if extensions, err := mime.ExtensionsByType(mediaType); err == nil && len(extensions) > 0 {
+		return extensions[0]
+	}
+	urlPath, _, _ := strings.Cut(sourceUrl, "?")
+	return path.Ext(urlPath)
+}
+
+func ReadBytes(content []byte) (*Archive, error) {
+	return Read(bytes.NewReader(content), int64(len(content)))
+}
@@ -0,0 +1,36 @@
+// Package archive implements the portable pack format used to move packs
+// between instances and to keep them in version control.
+//
+// A pack archive is a zip file with the following layout:
+//
+//	manifest.json
+//	media/0.png
+//	media/1.mp3
+//	...
+//
+// manifest.json is a JSON object:
+//
+//	{
+//	  "format": "sgame-pack",
+//	  "version": 1,
+//	  "exportedAt": "2024-05-01T12:00:00Z",
+//	  "pack": { ...same shape as the body of POST /rest/pack... },
+//	  "media": [
+//	    {
+//	      "path": "media/0.png",
+//	      "mediaType": "image",
+//	      "contentType": "image/png",
+//	      "sourceUrl": "https://example.com/picture.png"
+//	    }
+//	  ]
+//	}
+//
+// Attachments inside "pack" reference their content either by an absolute
+// http(s) url or by "archive:<path>" pointing to a file bundled in the zip,
+// which must also be listed in "media". "sourceUrl" is optional and records
+// where a bundled file was downloaded from on export.
+//
+// Readers must reject archives with unknown "format" or a "version" greater
+// than the one they support. Unknown fields are rejected too, so that typos
+// in hand written manifests are reported instead of silently ignored.
+package archive
@@ -0,0 +1,101 @@
+// Packlint runs the pack lint checks over exported pack archives.
+//
+// Usage:
+//
+//	packlint [flags] pack.zip...
+//
+// Every issue is printed as "file: severity: path: message (check)". The exit
+// status is 1 if any archive has errors, or warnings with -strict, and 2 if
+// an archive can not be read.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/holdennekt/sgame/archive"
+	"github.com/holdennekt/sgame/lint"
+	"github.com/holdennekt/sgame/media"
+)
+
+type fileReport struct {
+	File string `json:"file"`
+	*lint.Report
+}
+
+func main() {
+	offline := flag.Bool("offline", false, "do not request attachment urls")
+	strict := flag.Bool("strict", false, "fail on warnings too")
+	asJson := flag.Bool("json", false, "print reports as JSON")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of every attachment request")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: packlint [flags] pack.zip...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	exitCode := 0
+	reports := make([]fileReport, 0, flag.NArg())
+	for _, file := range flag.Args() {
+		report, err := lintFile(file, client, *offline)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+			exitCode = 2
+			continue
+		}
+		reports = append(reports, fileReport{File: file, Report: report})
+
+		if (report.HasErrors() || *strict && len(report.Issues) > 0) && exitCode == 0 {
+			exitCode = 1
+		}
+	}
+
+	if *asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(reports)
+	} else {
+		for _, fr := range reports {
+			for _, issue := range fr.Issues {
+				fmt.Printf("%s: %s\n", fr.File, issue)
+			}
+		}
+	}
+	os.Exit(exitCode)
+}
+
+func lintFile(file string, client *http.Client, offline bool) (*lint.Report, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	a, err := archive.ReadBytes(content)
+	if err != nil {
+		return nil, err
+	}
+	packDTO := &a.Manifest.Pack
+
+	report := lint.Lint(packDTO)
+	if !offline {
+		// bundled files are checked by archive.Read
+		urls := make([]string, 0)
+		for _, attachment := range packDTO.Attachments() {
+			if !strings.HasPrefix(attachment.ContentUrl, archive.URL_SCHEME) {
+				urls = append(urls, attachment.ContentUrl)
+			}
+		}
+		report.AddUnreachable(packDTO, media.CheckReachable(context.Background(), client, urls), true)
+	}
+	return report, nil
+}
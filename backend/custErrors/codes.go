@@ -0,0 +1,135 @@
+package custErrors
+
+// Stable machine readable reason of an error, clients may rely on it while
+// messages are translated and reworded. Every code has a message in i18n catalogs
+type ErrorCode string
+
+const (
+	Internal         ErrorCode = "internal"
+	InvalidRequest   ErrorCode = "invalidRequest"
+	MalformedRequest ErrorCode = "malformedRequest"
+	MalformedMessage ErrorCode = "malformedMessage"
+
+	FieldRequired     ErrorCode = "fieldRequired"
+	FieldTooShort     ErrorCode = "fieldTooShort"
+	FieldTooLong      ErrorCode = "fieldTooLong"
+	FieldTooSmall     ErrorCode = "fieldTooSmall"
+	FieldTooLarge     ErrorCode = "fieldTooLarge"
+	FieldTooFewItems  ErrorCode = "fieldTooFewItems"
+	FieldTooManyItems ErrorCode = "fieldTooManyItems"
+	FieldNotUrl       ErrorCode = "fieldNotUrl"
+	FieldNotEmail     ErrorCode = "fieldNotEmail"
+	FieldInvalid      ErrorCode = "fieldInvalid"
+
+	InvalidId             ErrorCode = "invalidId"
+	InvalidCursor         ErrorCode = "invalidCursor"
+	NotBoolean            ErrorCode = "notBoolean"
+	NotPositiveInteger    ErrorCode = "notPositiveInteger"
+	NotNonNegativeInteger ErrorCode = "notNonNegativeInteger"
+	LimitOutOfRange       ErrorCode = "limitOutOfRange"
+	NotOneOf              ErrorCode = "notOneOf"
+	RelevanceNeedsSearch  ErrorCode = "relevanceNeedsSearch"
+	FileRequired          ErrorCode = "fileRequired"
+	FileTooLarge          ErrorCode = "fileTooLarge"
+
+	MissingSession         ErrorCode = "missingSession"
+	InvalidSession         ErrorCode = "invalidSession"
+	GuestsNotAllowed       ErrorCode = "guestsNotAllowed"
+	RoleRequired           ErrorCode = "roleRequired"
+	WrongPassword          ErrorCode = "wrongPassword"
+	LoginTaken             ErrorCode = "loginTaken"
+	AlreadyRegistered      ErrorCode = "alreadyRegistered"
+	GuestExpired           ErrorCode = "guestExpired"
+	AccountSuspended       ErrorCode = "accountSuspended"
+	AccountSuspendedUntil  ErrorCode = "accountSuspendedUntil"
+	UserNotFound           ErrorCode = "userNotFound"
+	LoginNotFound          ErrorCode = "loginNotFound"
+	IdentityUserNotFound   ErrorCode = "identityUserNotFound"
+	CanNotManageSelf       ErrorCode = "canNotManageSelf"
+	CanNotManageHigherRole ErrorCode = "canNotManageHigherRole"
+
+	InvalidAccessToken    ErrorCode = "invalidAccessToken"
+	AccessTokenExpired    ErrorCode = "accessTokenExpired"
+	AccessTokenNotFound   ErrorCode = "accessTokenNotFound"
+	ScopeMissing          ErrorCode = "scopeMissing"
+	SessionRequired       ErrorCode = "sessionRequired"
+	ExpirationInPast      ErrorCode = "expirationInPast"
+	UnknownAuthProvider   ErrorCode = "unknownAuthProvider"
+	AuthenticationRefused ErrorCode = "authenticationRefused"
+	AuthenticationFailed  ErrorCode = "authenticationFailed"
+	InvalidOidcState      ErrorCode = "invalidOidcState"
+	MissingOidcNonce      ErrorCode = "missingOidcNonce"
+	IdentityTaken         ErrorCode = "identityTaken"
+	ProviderAlreadyLinked ErrorCode = "providerAlreadyLinked"
+	IdentityNotFound      ErrorCode = "identityNotFound"
+	LastIdentity          ErrorCode = "lastIdentity"
+
+	PackNotFound           ErrorCode = "packNotFound"
+	PackVersionNotFound    ErrorCode = "packVersionNotFound"
+	PackAccessDenied       ErrorCode = "packAccessDenied"
+	PackExportDenied       ErrorCode = "packExportDenied"
+	PackUpdateDenied       ErrorCode = "packUpdateDenied"
+	PackDeleteDenied       ErrorCode = "packDeleteDenied"
+	PackRoleRequired       ErrorCode = "packRoleRequired"
+	PackDuplicate          ErrorCode = "packDuplicate"
+	PackChanged            ErrorCode = "packChanged"
+	PackVersionChanged     ErrorCode = "packVersionChanged"
+	VersionRequired        ErrorCode = "versionRequired"
+	UnequalCategories      ErrorCode = "unequalCategories"
+	CoverNotImage          ErrorCode = "coverNotImage"
+	AlreadyPublished       ErrorCode = "alreadyPublished"
+	UnreachableAttachments ErrorCode = "unreachableAttachments"
+	AttachmentUnreachable  ErrorCode = "attachmentUnreachable"
+	PackNotPublic          ErrorCode = "packNotPublic"
+	PackHidden             ErrorCode = "packHidden"
+	PackIsDraft            ErrorCode = "packIsDraft"
+	NotEnoughQuestions     ErrorCode = "notEnoughQuestions"
+
+	AuthorIsCollaborator ErrorCode = "authorIsCollaborator"
+	GuestCollaborator    ErrorCode = "guestCollaborator"
+	TooManyCollaborators ErrorCode = "tooManyCollaborators"
+	NotCollaborator      ErrorCode = "notCollaborator"
+
+	RateOwnPack      ErrorCode = "rateOwnPack"
+	RateWithoutMatch ErrorCode = "rateWithoutMatch"
+	RatingNotFound   ErrorCode = "ratingNotFound"
+
+	ReportOwnPack         ErrorCode = "reportOwnPack"
+	QuestionNotInPack     ErrorCode = "questionNotInPack"
+	AlreadyReported       ErrorCode = "alreadyReported"
+	ReportAlreadyResolved ErrorCode = "reportAlreadyResolved"
+	ReportNotFound        ErrorCode = "reportNotFound"
+
+	InvalidArchive     ErrorCode = "invalidArchive"
+	InvalidPackage     ErrorCode = "invalidPackage"
+	InvalidSpreadsheet ErrorCode = "invalidSpreadsheet"
+
+	MediaNotFound       ErrorCode = "mediaNotFound"
+	MediaContentMissing ErrorCode = "mediaContentMissing"
+	MediaTooLarge       ErrorCode = "mediaTooLarge"
+	UnsupportedMedia    ErrorCode = "unsupportedMedia"
+
+	BankFull             ErrorCode = "bankFull"
+	BankQuestionChanged  ErrorCode = "bankQuestionChanged"
+	BankQuestionNotFound ErrorCode = "bankQuestionNotFound"
+
+	RoomNotFound           ErrorCode = "roomNotFound"
+	PackOrRandomRequired   ErrorCode = "packOrRandomRequired"
+	WrongRoomPassword      ErrorCode = "wrongRoomPassword"
+	GuestsNotAllowedInRoom ErrorCode = "guestsNotAllowedInRoom"
+	GameAlreadyStarted     ErrorCode = "gameAlreadyStarted"
+	RoomFull               ErrorCode = "roomFull"
+	NotInRoom              ErrorCode = "notInRoom"
+
+	NotAllowedToStart           ErrorCode = "notAllowedToStart"
+	NotAllowedToFinish          ErrorCode = "notAllowedToFinish"
+	NotAllowedToChoose          ErrorCode = "notAllowedToChoose"
+	NotAllowedToAnswer          ErrorCode = "notAllowedToAnswer"
+	NotAllowedToValidate        ErrorCode = "notAllowedToValidate"
+	NotAllowedToReportBuffering ErrorCode = "notAllowedToReportBuffering"
+	QuestionNotInRound          ErrorCode = "questionNotInRound"
+	QuestionAlreadyPlayed       ErrorCode = "questionAlreadyPlayed"
+	PlayerNotInRoom             ErrorCode = "playerNotInRoom"
+	NoMediaBuffering            ErrorCode = "noMediaBuffering"
+	NegativeDuration            ErrorCode = "negativeDuration"
+)
@@ -1,64 +1,179 @@
 package custErrors
 
 import (
-	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/holdennekt/sgame/i18n"
 )
 
 var ErrInternal error = errors.New("internal server error")
 
+// Set by api.CorrelateRequest, the same id is logged with the error and sent to the client
+const CORRELATION_ID_CONTEXT_KEY = "correlationId"
+
 type HttpError interface {
-	Code() int
-	Body() map[string]any
+	Status() int
+	ErrorCode() ErrorCode
+	// Message in the language, the same code always gives the same message
+	Message(lang i18n.Language) string
+	Response(lang i18n.Language, correlationId string) ErrorResponse
 	Error() string
 }
 
+// What clients receive about an error, both in REST responses and in websocket ERROR events
+type ErrorResponse struct {
+	Code    ErrorCode     `json:"code"`
+	Message string        `json:"message"`
+	Details []FieldDetail `json:"details,omitempty"`
+	// Values the client may need to recover, like the current version of a changed pack
+	Data          map[string]any `json:"data,omitempty"`
+	CorrelationId string         `json:"correlationId"`
+	// Id of the websocket message that has caused the error
+	MessageId string `json:"messageId,omitempty"`
+}
+
+// One of several problems of the request, like an invalid field or a bad row of an imported file
+type FieldDetail struct {
+	Field   string    `json:"field,omitempty"`
+	Code    ErrorCode `json:"code,omitempty"`
+	Message string    `json:"message"`
+}
+
+type detail struct {
+	field string
+	code  ErrorCode
+	args  []any
+	// used as is when there is no code, for problems reported by parsers in the default language
+	message string
+}
+
+func (d detail) translate(lang i18n.Language) FieldDetail {
+	if d.code == "" {
+		return FieldDetail{Field: d.field, Message: d.message}
+	}
+	return FieldDetail{Field: d.field, Code: d.code, Message: i18n.Translate(lang, string(d.code), d.args...)}
+}
+
 type httpError struct {
-	code int
-	body map[string]any
+	status    int
+	errorCode ErrorCode
+	args      []any
+	details   []detail
+	data      map[string]any
+	// only logged, clients are not told about the internals
+	cause error
+}
+
+// The message is the translation of errorCode formatted with args
+func NewHttpError(status int, errorCode ErrorCode, args ...any) httpError {
+	return httpError{status: status, errorCode: errorCode, args: args}
+}
+
+// Copy of the error with a value the client may need to recover
+func (he httpError) WithData(key string, value any) httpError {
+	data := make(map[string]any, len(he.data)+1)
+	for k, v := range he.data {
+		data[k] = v
+	}
+	data[key] = value
+	he.data = data
+	return he
+}
+
+// Copy of the error with one more detail, the message is not translated
+func (he httpError) WithDetail(field string, message string) httpError {
+	he.details = append(he.details[:len(he.details):len(he.details)], detail{field: field, message: message})
+	return he
+}
+
+// Copy of the error with one more detail, the message is the translation of code formatted with args
+func (he httpError) WithCodeDetail(field string, code ErrorCode, args ...any) httpError {
+	he.details = append(he.details[:len(he.details):len(he.details)], detail{field: field, code: code, args: args})
+	return he
 }
 
-func NewHttpError(code int, body map[string]any) httpError {
-	return httpError{code, body}
+func (he httpError) Status() int {
+	return he.status
 }
 
-func (he httpError) Code() int {
-	return he.code
+func (he httpError) ErrorCode() ErrorCode {
+	return he.errorCode
 }
 
-func (he httpError) Body() map[string]any {
-	return he.body
+func (he httpError) Message(lang i18n.Language) string {
+	// clients showing only the message still learn what is wrong with every field
+	if he.errorCode == InvalidRequest && len(he.details) > 0 {
+		messages := make([]string, len(he.details))
+		for i, d := range he.details {
+			messages[i] = d.translate(lang).Message
+		}
+		return strings.Join(messages, ", ")
+	}
+	return i18n.Translate(lang, string(he.errorCode), he.args...)
+}
+
+func (he httpError) Response(lang i18n.Language, correlationId string) ErrorResponse {
+	var details []FieldDetail
+	for _, d := range he.details {
+		details = append(details, d.translate(lang))
+	}
+	return ErrorResponse{
+		Code:          he.errorCode,
+		Message:       he.Message(lang),
+		Details:       details,
+		Data:          he.data,
+		CorrelationId: correlationId,
+	}
 }
 
 func (he httpError) Error() string {
-	err, _ := json.Marshal(he.body)
-	return string(err)
+	if he.cause != nil {
+		return he.Message(i18n.DEFAULT_LANGUAGE) + ": " + he.cause.Error()
+	}
+	return he.Message(i18n.DEFAULT_LANGUAGE)
 }
 
+// The cause is logged with the correlation id, the client gets only the generic message
 func NewInternalError(err error) httpError {
-	return NewHttpError(
-		http.StatusInternalServerError,
-		gin.H{"error": errors.Join(ErrInternal, err).Error()},
-	)
+	he := NewHttpError(http.StatusInternalServerError, Internal)
+	he.cause = err
+	return he
+}
+
+// Errors other than HttpError are internal ones
+func AsHttpError(err error) HttpError {
+	var httpErr HttpError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	return NewInternalError(err)
+}
+
+func NewCorrelationId() string {
+	return uuid.NewString()
+}
+
+func LogError(correlationId string, httpErr HttpError) {
+	log.Printf("Error %s (%s): %s\n", correlationId, httpErr.ErrorCode(), httpErr.Error())
 }
 
 func AbortWithError(c *gin.Context, httpErr HttpError) {
-	log.Println(httpErr.Body())
+	correlationId := c.GetString(CORRELATION_ID_CONTEXT_KEY)
+	if correlationId == "" {
+		correlationId = NewCorrelationId()
+	}
+	LogError(correlationId, httpErr)
 	c.AbortWithStatusJSON(
-		httpErr.Code(),
-		httpErr.Body(),
+		httpErr.Status(),
+		httpErr.Response(i18n.FromContext(c), correlationId),
 	)
 }
 
 func AbortWithInternalError(c *gin.Context, err error) {
-	body := gin.H{"error": errors.Join(ErrInternal, err).Error()}
-	log.Println(body)
-	c.AbortWithStatusJSON(
-		http.StatusInternalServerError,
-		body,
-	)
+	AbortWithError(c, NewInternalError(err))
 }
@@ -1,51 +1,73 @@
 package custErrors
 
 import (
-	"fmt"
+	"net/http"
 	"reflect"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/holdennekt/sgame/i18n"
 )
 
-func messageForTag(fe validator.FieldError) string {
+func detailForTag(fe validator.FieldError) detail {
+	d := messageForTag(fe)
+	d.field = fe.StructNamespace()
+	return d
+}
+
+func messageForTag(fe validator.FieldError) detail {
 	switch fe.Tag() {
 	case "required":
-		return fmt.Sprintf("%s is required", fe.Field())
+		return detail{code: FieldRequired, args: []any{fe.Field()}}
 	case "min":
 		switch fe.Type().Kind() {
 		case reflect.String:
-			return fmt.Sprintf("%s must be at least %s characters long", fe.StructNamespace(), fe.Param())
+			return detail{code: FieldTooShort, args: []any{fe.StructNamespace(), fe.Param()}}
 		case reflect.Int:
-			return fmt.Sprintf("%s must be at least %s", fe.StructNamespace(), fe.Param())
+			return detail{code: FieldTooSmall, args: []any{fe.StructNamespace(), fe.Param()}}
 		case reflect.Slice:
-			return fmt.Sprintf("%s must have at least %s items", fe.StructNamespace(), fe.Param())
+			return detail{code: FieldTooFewItems, args: []any{fe.StructNamespace(), fe.Param()}}
 		}
 	case "max":
 		switch fe.Type().Kind() {
 		case reflect.String:
-			return fmt.Sprintf("%s must be at most %s characters long", fe.StructNamespace(), fe.Param())
+			return detail{code: FieldTooLong, args: []any{fe.StructNamespace(), fe.Param()}}
 		case reflect.Int:
-			return fmt.Sprintf("%s must be at most %s", fe.StructNamespace(), fe.Param())
+			return detail{code: FieldTooLarge, args: []any{fe.StructNamespace(), fe.Param()}}
 		case reflect.Slice:
-			return fmt.Sprintf("%s must have at most %s items", fe.StructNamespace(), fe.Param())
+			return detail{code: FieldTooManyItems, args: []any{fe.StructNamespace(), fe.Param()}}
 		}
 	case "url":
-		return fmt.Sprintf("%s must be a URL", fe.StructNamespace())
+		return detail{code: FieldNotUrl, args: []any{fe.StructNamespace()}}
 	case "email":
-		return fmt.Sprintf("%s must be a valid email", fe.StructNamespace())
+		return detail{code: FieldNotEmail, args: []any{fe.StructNamespace()}}
 	}
-	return fe.Error()
+	return detail{code: FieldInvalid, args: []any{fe.StructNamespace(), fe.Tag()}}
 }
 
-func ParseValidationErrors(err error) []string {
+// Bad request listing every invalid field, or telling why the body could not be read at all
+func NewValidationError(err error) httpError {
 	switch err := err.(type) {
 	case validator.ValidationErrors:
-		errs := make([]string, len(err))
-		for i, fieldErr := range err {
-			errs[i] = messageForTag(fieldErr)
+		validationErr := NewHttpError(http.StatusBadRequest, InvalidRequest)
+		for _, fieldErr := range err {
+			validationErr.details = append(validationErr.details, detailForTag(fieldErr))
 		}
-		return errs
+		return validationErr
 	default:
+		return NewHttpError(http.StatusBadRequest, MalformedRequest, err.Error())
+	}
+}
+
+// Messages of every invalid field in the default language, for reports
+// that list them among other problems instead of responding with the error
+func ParseValidationErrors(err error) []string {
+	validationErr := NewValidationError(err)
+	if len(validationErr.details) == 0 {
 		return []string{err.Error()}
 	}
+	messages := make([]string, len(validationErr.details))
+	for i, d := range validationErr.details {
+		messages[i] = d.translate(i18n.DEFAULT_LANGUAGE).Message
+	}
+	return messages
 }
@@ -0,0 +1,60 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/holdennekt/sgame/custErrors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const ACCESS_TOKENS_COLLECTION = "accessTokens"
+
+type Scope string
+
+const (
+	PacksRead   Scope = "packs:read"
+	PacksWrite  Scope = "packs:write"
+	RoomsRead   Scope = "rooms:read"
+	RoomsCreate Scope = "rooms:create"
+	RoomsJoin   Scope = "rooms:join"
+	UserRead    Scope = "user:read"
+)
+
+type AccessToken struct {
+	Id             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserId         primitive.ObjectID `json:"-" bson:"userId"`
+	Hash           []byte             `json:"-" bson:"hash"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	LastUsedAt     *time.Time         `json:"lastUsedAt" bson:"lastUsedAt"`
+	AccessTokenDTO `bson:"inline"`
+}
+
+type AccessTokenDTO struct {
+	Name      string     `json:"name" binding:"min=1,max=50"`
+	Scopes    []Scope    `json:"scopes" binding:"min=1,unique,dive,oneof=packs:read packs:write rooms:read rooms:create rooms:join user:read"`
+	ExpiresAt *time.Time `json:"expiresAt" bson:"expiresAt,omitempty" binding:"omitnil"`
+}
+
+func GetAccessTokenByHash(mdb *mongo.Database, hash []byte) (*AccessToken, custErrors.HttpError) {
+	var accessToken AccessToken
+	err := mdb.Collection(ACCESS_TOKENS_COLLECTION).FindOne(
+		context.TODO(),
+		bson.D{{Key: "hash", Value: hash}},
+	).Decode(&accessToken)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, custErrors.NewHttpError(
+				http.StatusUnauthorized,
+				custErrors.InvalidAccessToken,
+			)
+		}
+		return nil, custErrors.NewInternalError(err)
+	}
+
+	return &accessToken, nil
+}
@@ -0,0 +1,105 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/holdennekt/sgame/custErrors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const BANK_QUESTIONS_COLLECTION = "bankQuestions"
+
+// Question of the personal bank, it can be put into any number of packs.
+// Value and index are not stored, they depend on where the question is put
+type BankQuestion struct {
+	Id              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	OwnerId         primitive.ObjectID `json:"ownerId" bson:"ownerId"`
+	Version         int                `json:"version" bson:"version"`
+	CreatedAt       time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt       time.Time          `json:"updatedAt" bson:"updatedAt"`
+	BankQuestionDTO `bson:",inline"`
+}
+
+type BankQuestionDTO struct {
+	Text       string      `json:"text" binding:"required,max=200"`
+	Attachment *Attachment `json:"attachment" binding:"omitnil"`
+	Answers    []string    `json:"answers" binding:"min=1,max=10,dive,min=1,max=50"`
+	Comment    *string     `json:"comment" binding:"omitnil,max=200"`
+	Tags       []string    `json:"tags" binding:"max=20,unique,dive,min=1,max=25"`
+}
+
+func (bq *BankQuestionDTO) Normalize() {
+	bq.Tags = normalizeTags(bq.Tags)
+}
+
+// Reference from a pack question to the bank question it was taken from
+type BankRef struct {
+	QuestionId primitive.ObjectID `json:"questionId" bson:"questionId"`
+	// Version of the bank question the content was copied from, 0 until the reference is resolved
+	Version int `json:"version" bson:"version"`
+	// Pinned questions keep their content when the bank question changes
+	Pinned bool `json:"pinned" bson:"pinned"`
+}
+
+// Copies the content of the bank question into the pack question, keeping its value and index
+func (bq *BankQuestion) ApplyTo(question *Question) {
+	question.Text = bq.Text
+	question.Attachment = nil
+	if bq.Attachment != nil {
+		attachment := *bq.Attachment
+		question.Attachment = &attachment
+	}
+	question.Answers = append(make([]string, 0, len(bq.Answers)), bq.Answers...)
+	question.Comment = nil
+	if bq.Comment != nil {
+		comment := *bq.Comment
+		question.Comment = &comment
+	}
+	question.BankRef.Version = bq.Version
+}
+
+func GetBankQuestion(mdb *mongo.Database, id primitive.ObjectID) (*BankQuestion, custErrors.HttpError) {
+	var bankQuestion BankQuestion
+	err := mdb.Collection(BANK_QUESTIONS_COLLECTION).FindOne(
+		context.TODO(),
+		bson.D{{Key: "_id", Value: id}},
+	).Decode(&bankQuestion)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, custErrors.NewHttpError(
+				http.StatusNotFound,
+				custErrors.BankQuestionNotFound, id.Hex(),
+			)
+		}
+		return nil, custErrors.NewInternalError(err)
+	}
+	return &bankQuestion, nil
+}
+
+// Every question of the pack taken from the bank, including pinned ones
+func (p *PackDTO) BankQuestions() []*Question {
+	questions := make([]*Question, 0)
+	for i := range p.Rounds {
+		for j := range p.Rounds[i].Categories {
+			for k := range p.Rounds[i].Categories[j].Questions {
+				if question := &p.Rounds[i].Categories[j].Questions[k]; question.BankRef != nil {
+					questions = append(questions, question)
+				}
+			}
+		}
+	}
+	return questions
+}
+
+func (p *PackDTO) BankQuestionIds() map[primitive.ObjectID]bool {
+	ids := make(map[primitive.ObjectID]bool)
+	for _, question := range p.BankQuestions() {
+		ids[question.BankRef.QuestionId] = true
+	}
+	return ids
+}
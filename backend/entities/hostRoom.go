@@ -12,6 +12,7 @@ type HostRoom struct {
 	PackPreview        PackPreview          `json:"packPreview"`
 	Host               *Host                `json:"host"`
 	Players            []Player             `json:"players"`
+	Spectators         []Spectator          `json:"spectators"`
 	CurrentRound       *string              `json:"currentRound"`
 	AvailableQuestions AvailableQuestions   `json:"availableQuestions"`
 	CurrentPlayer      *primitive.ObjectID  `json:"currentPlayer"`
@@ -21,6 +22,8 @@ type HostRoom struct {
 	FinalRoundState    FinalRoundState      `json:"finalRoundState"`
 	DeadlineAt         time.Time            `json:"deadlineAt"`
 	PausedState        PausedState          `json:"pausedState"`
+	MediaState         *MediaState          `json:"mediaState"`
+	FinishedAt         *time.Time           `json:"finishedAt"`
 }
 
 func NewHostRoom(room *Room) HostRoom {
@@ -30,6 +33,7 @@ func NewHostRoom(room *Room) HostRoom {
 		PackPreview:        room.PackPreview,
 		Host:               room.Host,
 		Players:            room.Players,
+		Spectators:         room.Spectators,
 		CurrentRound:       room.CurrentRound,
 		AvailableQuestions: room.AvailableQuestions,
 		CurrentPlayer:      room.CurrentPlayer,
@@ -39,5 +43,7 @@ func NewHostRoom(room *Room) HostRoom {
 		FinalRoundState:    room.FinalRoundState,
 		DeadlineAt:         room.DeadlineAt,
 		PausedState:        room.PausedState,
+		MediaState:         room.MediaState,
+		FinishedAt:         room.FinishedAt,
 	}
 }
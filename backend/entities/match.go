@@ -0,0 +1,79 @@
+package entities
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const MATCHES_COLLECTION = "matches"
+
+// History of a finished game, a room is recorded once
+type Match struct {
+	Id              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	RoomId          primitive.ObjectID `json:"roomId" bson:"roomId"`
+	PackId          primitive.ObjectID `json:"packId" bson:"packId"`
+	PackVersion     int                `json:"packVersion" bson:"packVersion"`
+	Host            *User              `json:"host" bson:"host"`
+	Players         []MatchPlayer      `json:"players" bson:"players"`
+	PlayedQuestions []QuestionRef      `json:"playedQuestions" bson:"playedQuestions"`
+	FinishedAt      time.Time          `json:"finishedAt" bson:"finishedAt"`
+}
+
+type MatchPlayer struct {
+	User  User `json:"user" bson:"user"`
+	Score int  `json:"score" bson:"score"`
+}
+
+func NewMatch(room *Room) *Match {
+	players := make([]MatchPlayer, len(room.Players))
+	for i, player := range room.Players {
+		players[i] = MatchPlayer{User: player.User, Score: player.Score}
+	}
+	var host *User
+	if room.Host != nil {
+		host = &room.Host.User
+	}
+	return &Match{
+		RoomId:          room.Id,
+		PackId:          room.PackId,
+		PackVersion:     room.PackPreview.Version,
+		Host:            host,
+		Players:         players,
+		PlayedQuestions: room.PlayedQuestions,
+		FinishedAt:      *room.FinishedAt,
+	}
+}
+
+// Saves the finished match and counts it as a play of the pack
+func RecordMatch(mdb *mongo.Database, room *Room) error {
+	_, err := mdb.Collection(MATCHES_COLLECTION).InsertOne(context.TODO(), NewMatch(room))
+	if err != nil {
+		// recorded already by another connection of the room
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return err
+	}
+
+	_, err = mdb.Collection(PACKS_COLLECTION).UpdateByID(
+		context.TODO(),
+		room.PackId,
+		bson.M{"$inc": bson.M{"playCount": 1}},
+	)
+	return err
+}
+
+func HasPlayedPack(mdb *mongo.Database, userId primitive.ObjectID, packId primitive.ObjectID) (bool, error) {
+	count, err := mdb.Collection(MATCHES_COLLECTION).CountDocuments(
+		context.TODO(),
+		bson.M{"packId": packId, "players.user._id": userId},
+	)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,49 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/holdennekt/sgame/custErrors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const MEDIA_COLLECTION = "media"
+
+// Uploaded attachment content. RefCount is the number of packs using it,
+// unreferenced media is deleted after a grace period
+type Media struct {
+	Id             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	OwnerId        primitive.ObjectID `json:"ownerId" bson:"ownerId"`
+	MediaType      MediaType          `json:"mediaType" bson:"mediaType"`
+	ContentType    string             `json:"contentType" bson:"contentType"`
+	Size           int64              `json:"size" bson:"size"`
+	Url            string             `json:"url" bson:"-"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	RefCount       int                `json:"refCount" bson:"refCount"`
+	UnreferencedAt *time.Time         `json:"-" bson:"unreferencedAt,omitempty"`
+}
+
+func GetMedia(mdb *mongo.Database, id primitive.ObjectID) (*Media, custErrors.HttpError) {
+	var media Media
+
+	err := mdb.Collection(MEDIA_COLLECTION).FindOne(
+		context.TODO(),
+		bson.D{{Key: "_id", Value: id}},
+	).Decode(&media)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, custErrors.NewHttpError(
+				http.StatusNotFound,
+				custErrors.MediaNotFound, id.Hex(),
+			)
+		}
+		return nil, custErrors.NewInternalError(err)
+	}
+
+	return &media, nil
+}
@@ -2,11 +2,12 @@ package entities
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 
-	"github.com/gin-gonic/gin"
 	"github.com/holdennekt/sgame/custErrors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -15,24 +16,94 @@ import (
 
 const PACKS_COLLECTION = "packs"
 
+type PackStatus string
+
+// Drafts may be incomplete, they are not listed publicly and can not be played
+const (
+	Draft     PackStatus = "draft"
+	Published PackStatus = "published"
+)
+
 type Pack struct {
 	Id             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	Author         User               `json:"author"`
 	RoundsCheckSum []byte             `json:"-" bson:"roundsCheckSum"`
 	Content        string             `json:"-" bson:"content"`
-	PackDTO        `bson:"inline"`
+	IsHidden       bool               `json:"isHidden" bson:"isHidden"`
+	Collaborators  []Collaborator     `json:"collaborators" bson:"collaborators"`
+	Status         PackStatus         `json:"status" bson:"status"`
+	// Question texts, searched only when asked to
+	QuestionsContent string `json:"-" bson:"questionsContent"`
+	// Number of the latest saved version, 0 for packs saved before versioning
+	Version int `json:"version" bson:"version"`
+	// Number of finished matches and average rating, listings are sorted by them
+	PlayCount    int     `json:"playCount" bson:"playCount"`
+	Rating       float64 `json:"rating" bson:"rating"`
+	RatingsCount int     `json:"ratingsCount" bson:"ratingsCount"`
+	// Set for packs copied from another one
+	ForkedFrom *ForkInfo `json:"forkedFrom" bson:"forkedFrom,omitempty"`
+	ForksCount int       `json:"forksCount" bson:"forksCount"`
+	PackDTO    `bson:"inline"`
 }
 
+type Difficulty string
+
+const (
+	Easy   Difficulty = "easy"
+	Medium Difficulty = "medium"
+	Hard   Difficulty = "hard"
+)
+
 type PackDTO struct {
-	Name       string      `json:"name" binding:"max=50"`
-	Type       PrivacyType `json:"type" binding:"oneof=public private"`
-	Rounds     []Round     `json:"rounds" binding:"max=10,unique=Name"`
-	FinalRound FinalRound  `json:"finalRound"`
+	Name        string      `json:"name" binding:"max=50"`
+	Type        PrivacyType `json:"type" binding:"oneof=public private"`
+	Description string      `json:"description" binding:"max=500"`
+	Tags        []string    `json:"tags" binding:"max=10,unique,dive,min=1,max=25"`
+	Language    string      `json:"language" binding:"omitempty,max=35,bcp47_language_tag"`
+	Difficulty  Difficulty  `json:"difficulty" binding:"omitempty,oneof=easy medium hard"`
+	Cover       *Attachment `json:"cover" binding:"omitnil"`
+	Rounds      []Round     `json:"rounds" binding:"max=10,unique=Name"`
+	FinalRound  FinalRound  `json:"finalRound"`
+}
+
+// Version is the one the changes were made to, they are not saved over a newer one
+type PackUpdateDTO struct {
+	PackDTO
+	Version *int `json:"version" binding:"required,min=0"`
+}
+
+type PackVisibilityDTO struct {
+	IsHidden bool `json:"isHidden"`
 }
 
 type PackPreview struct {
-	Id   primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name string             `json:"name"`
+	Id           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name         string             `json:"name"`
+	Description  string             `json:"description" bson:"description"`
+	Tags         []string           `json:"tags" bson:"tags"`
+	Language     string             `json:"language" bson:"language"`
+	Difficulty   Difficulty         `json:"difficulty" bson:"difficulty"`
+	Cover        *Attachment        `json:"cover" bson:"cover"`
+	Version      int                `json:"version" bson:"version"`
+	PlayCount    int                `json:"playCount" bson:"playCount"`
+	Rating       float64            `json:"rating" bson:"rating"`
+	RatingsCount int                `json:"ratingsCount" bson:"ratingsCount"`
+}
+
+func NewPackPreview(pack *Pack) PackPreview {
+	return PackPreview{
+		Id:           pack.Id,
+		Name:         pack.Name,
+		Description:  pack.Description,
+		Tags:         pack.Tags,
+		Language:     pack.Language,
+		Difficulty:   pack.Difficulty,
+		Cover:        pack.Cover,
+		Version:      pack.Version,
+		PlayCount:    pack.PlayCount,
+		Rating:       pack.Rating,
+		RatingsCount: pack.RatingsCount,
+	}
 }
 
 type Round struct {
@@ -62,6 +133,9 @@ type Question struct {
 	HiddenQuestion
 	Answers []string `json:"answers" binding:"min=1,max=10,dive,min=1,max=50"`
 	Comment *string  `json:"comment" binding:"omitnil,max=200"`
+	BankRef *BankRef `json:"bankRef,omitempty" bson:"bankRef,omitempty"`
+	// Where the question of a random pack was taken from
+	Source *QuestionRef `json:"source,omitempty" bson:"source,omitempty"`
 }
 
 type FinalRound struct {
@@ -80,11 +154,17 @@ type FinalQuestion struct {
 }
 
 type HiddenPack struct {
-	Id         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Author     User               `json:"author"`
-	Name       string             `json:"name" binding:"max=50"`
-	Rounds     []hiddenRound      `json:"rounds" binding:"max=10,unique=Name"`
-	FinalRound hiddenFinalRound   `json:"finalRound"`
+	Id          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Author      User               `json:"author"`
+	Name        string             `json:"name" binding:"max=50"`
+	Description string             `json:"description"`
+	Tags        []string           `json:"tags"`
+	Language    string             `json:"language"`
+	Difficulty  Difficulty         `json:"difficulty"`
+	Cover       *Attachment        `json:"cover"`
+	ForkedFrom  *ForkInfo          `json:"forkedFrom"`
+	Rounds      []hiddenRound      `json:"rounds" binding:"max=10,unique=Name"`
+	FinalRound  hiddenFinalRound   `json:"finalRound"`
 }
 
 type hiddenRound struct {
@@ -116,6 +196,67 @@ type HiddenFinalQuestion struct {
 	Attachment *Attachment `json:"attachment" binding:"omitnil"`
 }
 
+// Packs saved before drafts were introduced have no status and are published
+func (p *Pack) IsDraft() bool {
+	return p.Status == Draft
+}
+
+// Deep copy, so that attachments of the clone can be changed without touching the original
+func (p PackDTO) Clone() PackDTO {
+	var clone PackDTO
+	marshaled, _ := json.Marshal(p)
+	json.Unmarshal(marshaled, &clone)
+	return clone
+}
+
+// Trims the metadata and brings tags and language to lower case, so that they can be filtered by
+func (p *PackDTO) Normalize() {
+	p.Description = strings.TrimSpace(p.Description)
+	p.Language = strings.ToLower(strings.TrimSpace(p.Language))
+	p.Tags = normalizeTags(p.Tags)
+}
+
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// References to the bank do not mean anything outside of this instance
+func (p *PackDTO) ClearBankRefs() {
+	for _, question := range p.BankQuestions() {
+		question.BankRef = nil
+	}
+}
+
+// Every attachment of the pack, including the cover
+func (p *PackDTO) Attachments() []*Attachment {
+	attachments := make([]*Attachment, 0)
+	if p.Cover != nil {
+		attachments = append(attachments, p.Cover)
+	}
+	for i := range p.Rounds {
+		for j := range p.Rounds[i].Categories {
+			for k := range p.Rounds[i].Categories[j].Questions {
+				if attachment := p.Rounds[i].Categories[j].Questions[k].Attachment; attachment != nil {
+					attachments = append(attachments, attachment)
+				}
+			}
+		}
+	}
+	for i := range p.FinalRound.Categories {
+		if attachment := p.FinalRound.Categories[i].Question.Attachment; attachment != nil {
+			attachments = append(attachments, attachment)
+		}
+	}
+	return attachments
+}
+
 func NewHiddenPack(pack Pack) HiddenPack {
 	hiddenRounds := make([]hiddenRound, len(pack.Rounds))
 	for i, round := range pack.Rounds {
@@ -130,10 +271,16 @@ func NewHiddenPack(pack Pack) HiddenPack {
 		hiddenFinalCategories[i] = HiddenFinalCategory{Name: finalCategory.Name}
 	}
 	return HiddenPack{
-		Id:     pack.Id,
-		Author: pack.Author,
-		Name:   pack.Name,
-		Rounds: hiddenRounds,
+		Id:          pack.Id,
+		Author:      pack.Author,
+		Name:        pack.Name,
+		Description: pack.Description,
+		Tags:        pack.Tags,
+		Language:    pack.Language,
+		Difficulty:  pack.Difficulty,
+		Cover:       pack.Cover,
+		ForkedFrom:  pack.ForkedFrom,
+		Rounds:      hiddenRounds,
 		FinalRound: hiddenFinalRound{
 			Categories: hiddenFinalCategories,
 		},
@@ -151,11 +298,56 @@ func GetPack(mdb *mongo.Database, id primitive.ObjectID) (*Pack, custErrors.Http
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, custErrors.NewHttpError(
 				http.StatusNotFound,
-				gin.H{"error": fmt.Sprintf("there is no pack with id \"%s\"", id)},
+				custErrors.PackNotFound, id,
 			)
 		}
 		return nil, custErrors.NewInternalError(err)
 	}
+	if pack.Status == "" {
+		pack.Status = Published
+	}
 
 	return &pack, nil
 }
+
+// Deletes the pack with all its versions. Returns contents that referenced
+// media, so that the references can be released
+func DeletePack(mdb *mongo.Database, id primitive.ObjectID) ([]PackDTO, error) {
+	var pack Pack
+	err := mdb.Collection(PACKS_COLLECTION).FindOneAndDelete(
+		context.TODO(),
+		bson.D{{Key: "_id", Value: id}},
+	).Decode(&pack)
+	if err != nil {
+		return nil, err
+	}
+
+	packVersions, httpErr := GetPackVersions(mdb, id)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	_, err = mdb.Collection(PACK_VERSIONS_COLLECTION).DeleteMany(
+		context.TODO(),
+		bson.D{{Key: "packId", Value: id}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = mdb.Collection(PACK_RATINGS_COLLECTION).DeleteMany(
+		context.TODO(),
+		bson.D{{Key: "packId", Value: id}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// media of packs saved before versioning is referenced by the pack itself
+	if len(packVersions) == 0 {
+		return []PackDTO{pack.PackDTO}, nil
+	}
+	contents := make([]PackDTO, len(packVersions))
+	for i, packVersion := range packVersions {
+		contents[i] = packVersion.PackDTO
+	}
+	return contents, nil
+}
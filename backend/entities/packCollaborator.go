@@ -0,0 +1,51 @@
+package entities
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type CollaboratorRole string
+
+// Roles are ordered, every role is allowed to do what the lower ones can
+const (
+	NoRole     CollaboratorRole = ""
+	ViewerRole CollaboratorRole = "viewer"
+	EditorRole CollaboratorRole = "editor"
+	OwnerRole  CollaboratorRole = "owner"
+)
+
+var collaboratorRoleRanks = map[CollaboratorRole]int{
+	NoRole:     0,
+	ViewerRole: 1,
+	EditorRole: 2,
+	OwnerRole:  3,
+}
+
+func (cr CollaboratorRole) IsAtLeast(role CollaboratorRole) bool {
+	return collaboratorRoleRanks[cr] >= collaboratorRoleRanks[role]
+}
+
+type Collaborator struct {
+	User User             `json:"user"`
+	Role CollaboratorRole `json:"role"`
+}
+
+type CollaboratorDTO struct {
+	UserId primitive.ObjectID `json:"userId" binding:"required"`
+	Role   CollaboratorRole   `json:"role" binding:"oneof=viewer editor"`
+}
+
+// Role of the user in the pack, the author owns it
+func (p *Pack) RoleOf(userId primitive.ObjectID) CollaboratorRole {
+	if p.Author.Id == userId {
+		return OwnerRole
+	}
+	for _, collaborator := range p.Collaborators {
+		if collaborator.User.Id == userId {
+			return collaborator.Role
+		}
+	}
+	return NoRole
+}
+
+func (p *Pack) HasRole(userId primitive.ObjectID, role CollaboratorRole) bool {
+	return p.RoleOf(userId).IsAtLeast(role)
+}
@@ -0,0 +1,235 @@
+package entities
+
+import (
+	"reflect"
+	"slices"
+)
+
+type ChangeType string
+
+const (
+	Added   ChangeType = "added"
+	Removed ChangeType = "removed"
+	Changed ChangeType = "changed"
+)
+
+type ChangeTarget string
+
+const (
+	PackTarget          ChangeTarget = "pack"
+	RoundTarget         ChangeTarget = "round"
+	CategoryTarget      ChangeTarget = "category"
+	QuestionTarget      ChangeTarget = "question"
+	FinalCategoryTarget ChangeTarget = "finalCategory"
+)
+
+// Single structural difference between two versions of a pack.
+// Round and Category locate the changed item, Index is set for questions
+type Change struct {
+	Type     ChangeType   `json:"type"`
+	Target   ChangeTarget `json:"target"`
+	Round    string       `json:"round,omitempty"`
+	Category string       `json:"category,omitempty"`
+	Index    *int         `json:"index,omitempty"`
+	Fields   []string     `json:"fields,omitempty"`
+	From     any          `json:"from,omitempty"`
+	To       any          `json:"to,omitempty"`
+}
+
+// Rounds and categories are matched by name, questions by index
+func DiffPacks(from *PackDTO, to *PackDTO) []Change {
+	changes := make([]Change, 0)
+
+	diffPackField := func(field string, isChanged bool, fromValue any, toValue any) {
+		if isChanged {
+			changes = append(changes, Change{Type: Changed, Target: PackTarget, Fields: []string{field}, From: fromValue, To: toValue})
+		}
+	}
+	diffPackField("name", from.Name != to.Name, from.Name, to.Name)
+	diffPackField("type", from.Type != to.Type, from.Type, to.Type)
+	diffPackField("description", from.Description != to.Description, from.Description, to.Description)
+	diffPackField("tags", !slices.Equal(from.Tags, to.Tags), from.Tags, to.Tags)
+	diffPackField("language", from.Language != to.Language, from.Language, to.Language)
+	diffPackField("difficulty", from.Difficulty != to.Difficulty, from.Difficulty, to.Difficulty)
+	diffPackField("cover", !reflect.DeepEqual(from.Cover, to.Cover), from.Cover, to.Cover)
+
+	for _, fromRound := range from.Rounds {
+		toRound := findRound(to.Rounds, fromRound.Name)
+		if toRound == nil {
+			changes = append(changes, Change{Type: Removed, Target: RoundTarget, Round: fromRound.Name, From: fromRound})
+			continue
+		}
+		changes = append(changes, diffRounds(&fromRound, toRound)...)
+	}
+	for _, toRound := range to.Rounds {
+		if findRound(from.Rounds, toRound.Name) == nil {
+			changes = append(changes, Change{Type: Added, Target: RoundTarget, Round: toRound.Name, To: toRound})
+		}
+	}
+
+	for _, fromCategory := range from.FinalRound.Categories {
+		toCategory := findFinalCategory(to.FinalRound.Categories, fromCategory.Name)
+		if toCategory == nil {
+			changes = append(changes, Change{Type: Removed, Target: FinalCategoryTarget, Category: fromCategory.Name, From: fromCategory})
+			continue
+		}
+		fields := diffFinalQuestions(&fromCategory.Question, &toCategory.Question)
+		if len(fields) > 0 {
+			changes = append(changes, Change{
+				Type:     Changed,
+				Target:   FinalCategoryTarget,
+				Category: fromCategory.Name,
+				Fields:   fields,
+				From:     fromCategory,
+				To:       *toCategory,
+			})
+		}
+	}
+	for _, toCategory := range to.FinalRound.Categories {
+		if findFinalCategory(from.FinalRound.Categories, toCategory.Name) == nil {
+			changes = append(changes, Change{Type: Added, Target: FinalCategoryTarget, Category: toCategory.Name, To: toCategory})
+		}
+	}
+
+	return changes
+}
+
+func diffRounds(from *Round, to *Round) []Change {
+	changes := make([]Change, 0)
+	for _, fromCategory := range from.Categories {
+		toCategory := findCategory(to.Categories, fromCategory.Name)
+		if toCategory == nil {
+			changes = append(changes, Change{Type: Removed, Target: CategoryTarget, Round: from.Name, Category: fromCategory.Name, From: fromCategory})
+			continue
+		}
+		changes = append(changes, diffCategories(from.Name, &fromCategory, toCategory)...)
+	}
+	for _, toCategory := range to.Categories {
+		if findCategory(from.Categories, toCategory.Name) == nil {
+			changes = append(changes, Change{Type: Added, Target: CategoryTarget, Round: from.Name, Category: toCategory.Name, To: toCategory})
+		}
+	}
+	return changes
+}
+
+func diffCategories(roundName string, from *Category, to *Category) []Change {
+	changes := make([]Change, 0)
+	for _, fromQuestion := range from.Questions {
+		index := fromQuestion.Index
+		toQuestion := findQuestion(to.Questions, index)
+		if toQuestion == nil {
+			changes = append(changes, Change{
+				Type:     Removed,
+				Target:   QuestionTarget,
+				Round:    roundName,
+				Category: from.Name,
+				Index:    &index,
+				From:     fromQuestion,
+			})
+			continue
+		}
+		fields := diffQuestions(&fromQuestion, toQuestion)
+		if len(fields) > 0 {
+			changes = append(changes, Change{
+				Type:     Changed,
+				Target:   QuestionTarget,
+				Round:    roundName,
+				Category: from.Name,
+				Index:    &index,
+				Fields:   fields,
+				From:     fromQuestion,
+				To:       *toQuestion,
+			})
+		}
+	}
+	for _, toQuestion := range to.Questions {
+		if findQuestion(from.Questions, toQuestion.Index) == nil {
+			index := toQuestion.Index
+			changes = append(changes, Change{
+				Type:     Added,
+				Target:   QuestionTarget,
+				Round:    roundName,
+				Category: from.Name,
+				Index:    &index,
+				To:       toQuestion,
+			})
+		}
+	}
+	return changes
+}
+
+func diffQuestions(from *Question, to *Question) []string {
+	fields := make([]string, 0)
+	if from.Value != to.Value {
+		fields = append(fields, "value")
+	}
+	if from.Text != to.Text {
+		fields = append(fields, "text")
+	}
+	if !reflect.DeepEqual(from.Attachment, to.Attachment) {
+		fields = append(fields, "attachment")
+	}
+	if !reflect.DeepEqual(from.Answers, to.Answers) {
+		fields = append(fields, "answers")
+	}
+	if !reflect.DeepEqual(from.Comment, to.Comment) {
+		fields = append(fields, "comment")
+	}
+	if !reflect.DeepEqual(from.BankRef, to.BankRef) {
+		fields = append(fields, "bankRef")
+	}
+	return fields
+}
+
+func diffFinalQuestions(from *FinalQuestion, to *FinalQuestion) []string {
+	fields := make([]string, 0)
+	if from.Text != to.Text {
+		fields = append(fields, "text")
+	}
+	if !reflect.DeepEqual(from.Attachment, to.Attachment) {
+		fields = append(fields, "attachment")
+	}
+	if !reflect.DeepEqual(from.Answers, to.Answers) {
+		fields = append(fields, "answers")
+	}
+	if !reflect.DeepEqual(from.Comment, to.Comment) {
+		fields = append(fields, "comment")
+	}
+	return fields
+}
+
+func findRound(rounds []Round, name string) *Round {
+	for i := range rounds {
+		if rounds[i].Name == name {
+			return &rounds[i]
+		}
+	}
+	return nil
+}
+
+func findCategory(categories []Category, name string) *Category {
+	for i := range categories {
+		if categories[i].Name == name {
+			return &categories[i]
+		}
+	}
+	return nil
+}
+
+func findQuestion(questions []Question, index int) *Question {
+	for i := range questions {
+		if questions[i].Index == index {
+			return &questions[i]
+		}
+	}
+	return nil
+}
+
+func findFinalCategory(categories []FinalCategory, name string) *FinalCategory {
+	for i := range categories {
+		if categories[i].Name == name {
+			return &categories[i]
+		}
+	}
+	return nil
+}
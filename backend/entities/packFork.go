@@ -0,0 +1,56 @@
+package entities
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Ancestors deeper than this are not shown in the lineage
+const MAX_LINEAGE_DEPTH = 20
+
+// The pack a fork was copied from. Name and author are kept as they were
+// at the time of forking, so that attribution survives deletion of the parent
+type ForkInfo struct {
+	PackId  primitive.ObjectID `json:"packId" bson:"packId"`
+	Version int                `json:"version" bson:"version"`
+	Name    string             `json:"name" bson:"name"`
+	Author  User               `json:"author" bson:"author"`
+}
+
+func NewForkInfo(parent *Pack) *ForkInfo {
+	return &ForkInfo{
+		PackId:  parent.Id,
+		Version: parent.Version,
+		Name:    parent.Name,
+		Author:  parent.Author,
+	}
+}
+
+// Packs the pack descends from, the parent first. The chain ends at a deleted pack
+func GetPackAncestors(mdb *mongo.Database, pack *Pack) ([]ForkInfo, error) {
+	ancestors := make([]ForkInfo, 0)
+	forkedFrom := pack.ForkedFrom
+	for forkedFrom != nil && len(ancestors) < MAX_LINEAGE_DEPTH {
+		ancestors = append(ancestors, *forkedFrom)
+
+		var parent Pack
+		err := mdb.Collection(PACKS_COLLECTION).FindOne(
+			context.TODO(),
+			bson.M{"_id": forkedFrom.PackId},
+			options.FindOne().SetProjection(bson.M{"forkedFrom": 1}),
+		).Decode(&parent)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		forkedFrom = parent.ForkedFrom
+	}
+	return ancestors, nil
+}
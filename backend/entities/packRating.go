@@ -0,0 +1,57 @@
+package entities
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const PACK_RATINGS_COLLECTION = "packRatings"
+
+// Rating of the pack by a player who has finished a match on it, one per user
+type PackRating struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	PackId    primitive.ObjectID `json:"packId" bson:"packId"`
+	User      User               `json:"user" bson:"user"`
+	Rating    int                `json:"rating" bson:"rating"`
+	Review    *string            `json:"review" bson:"review"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+type PackRatingDTO struct {
+	Rating int     `json:"rating" binding:"min=1,max=5"`
+	Review *string `json:"review" binding:"omitnil,max=500"`
+}
+
+// Recalculates the average rating stored on the pack
+func UpdatePackRatings(mdb *mongo.Database, packId primitive.ObjectID) error {
+	cursor, err := mdb.Collection(PACK_RATINGS_COLLECTION).Aggregate(context.TODO(), bson.A{
+		bson.M{"$match": bson.M{"packId": packId}},
+		bson.M{"$group": bson.M{
+			"_id":          nil,
+			"rating":       bson.M{"$avg": "$rating"},
+			"ratingsCount": bson.M{"$sum": 1},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	aggregates := make([]struct {
+		Rating       float64 `bson:"rating"`
+		RatingsCount int     `bson:"ratingsCount"`
+	}, 0)
+	if err := cursor.All(context.TODO(), &aggregates); err != nil {
+		return err
+	}
+
+	update := bson.M{"rating": 0, "ratingsCount": 0}
+	if len(aggregates) > 0 {
+		update = bson.M{"rating": aggregates[0].Rating, "ratingsCount": aggregates[0].RatingsCount}
+	}
+	_, err = mdb.Collection(PACKS_COLLECTION).UpdateByID(context.TODO(), packId, bson.M{"$set": update})
+	return err
+}
@@ -0,0 +1,129 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/holdennekt/sgame/custErrors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const PACK_VERSIONS_COLLECTION = "packVersions"
+
+// Immutable snapshot of the pack content, created on every save
+type PackVersion struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	PackId    primitive.ObjectID `json:"packId" bson:"packId"`
+	Version   int                `json:"version" bson:"version"`
+	SavedBy   User               `json:"savedBy" bson:"savedBy"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	// Set when the version was created by restoring another one
+	RestoredFrom *int `json:"restoredFrom" bson:"restoredFrom,omitempty"`
+	PackDTO      `bson:"inline"`
+}
+
+type PackVersionPreview struct {
+	Version      int       `json:"version" bson:"version"`
+	SavedBy      User      `json:"savedBy" bson:"savedBy"`
+	CreatedAt    time.Time `json:"createdAt" bson:"createdAt"`
+	RestoredFrom *int      `json:"restoredFrom" bson:"restoredFrom,omitempty"`
+}
+
+func NewPackVersion(pack *Pack, savedBy User) *PackVersion {
+	return &PackVersion{
+		PackId:    pack.Id,
+		Version:   pack.Version,
+		SavedBy:   savedBy,
+		CreatedAt: time.Now(),
+		PackDTO:   pack.PackDTO,
+	}
+}
+
+// The pack as it was at this version
+func (pv *PackVersion) ToPack(pack *Pack) *Pack {
+	return &Pack{
+		Id:       pack.Id,
+		Author:   pack.Author,
+		IsHidden: pack.IsHidden,
+		Version:  pv.Version,
+		PackDTO:  pv.PackDTO,
+	}
+}
+
+func GetPackVersion(mdb *mongo.Database, packId primitive.ObjectID, version int) (*PackVersion, custErrors.HttpError) {
+	var packVersion PackVersion
+
+	err := mdb.Collection(PACK_VERSIONS_COLLECTION).FindOne(
+		context.TODO(),
+		bson.D{{Key: "packId", Value: packId}, {Key: "version", Value: version}},
+	).Decode(&packVersion)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, custErrors.NewHttpError(
+				http.StatusNotFound,
+				custErrors.PackVersionNotFound, packId.Hex(), version,
+			)
+		}
+		return nil, custErrors.NewInternalError(err)
+	}
+
+	return &packVersion, nil
+}
+
+func GetPackVersions(mdb *mongo.Database, packId primitive.ObjectID) ([]PackVersion, custErrors.HttpError) {
+	packVersions := make([]PackVersion, 0)
+	res, err := mdb.Collection(PACK_VERSIONS_COLLECTION).Find(
+		context.TODO(),
+		bson.D{{Key: "packId", Value: packId}},
+		options.Find().SetSort(bson.D{{Key: "version", Value: -1}}),
+	)
+	if err != nil {
+		return nil, custErrors.NewInternalError(err)
+	}
+	if err := res.All(context.TODO(), &packVersions); err != nil {
+		return nil, custErrors.NewInternalError(err)
+	}
+	return packVersions, nil
+}
+
+// Versions without their contents, for listing them
+func GetPackVersionPreviews(mdb *mongo.Database, packId primitive.ObjectID) ([]PackVersionPreview, custErrors.HttpError) {
+	previews := make([]PackVersionPreview, 0)
+	res, err := mdb.Collection(PACK_VERSIONS_COLLECTION).Find(
+		context.TODO(),
+		bson.D{{Key: "packId", Value: packId}},
+		options.Find().
+			SetSort(bson.D{{Key: "version", Value: -1}}).
+			SetProjection(bson.M{"version": 1, "savedBy": 1, "createdAt": 1, "restoredFrom": 1}),
+	)
+	if err != nil {
+		return nil, custErrors.NewInternalError(err)
+	}
+	if err := res.All(context.TODO(), &previews); err != nil {
+		return nil, custErrors.NewInternalError(err)
+	}
+	return previews, nil
+}
+
+// Pack as a room pinned to its version sees it. Rooms created
+// before packs were versioned have no version and get the current pack
+func GetPackAtVersion(mdb *mongo.Database, packId primitive.ObjectID, version int) (*Pack, custErrors.HttpError) {
+	pack, httpErr := GetPack(mdb, packId)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	if version == 0 || version == pack.Version {
+		return pack, nil
+	}
+
+	packVersion, httpErr := GetPackVersion(mdb, packId, version)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return packVersion.ToPack(pack), nil
+}
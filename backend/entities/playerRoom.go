@@ -12,6 +12,7 @@ type PlayerRoom struct {
 	PackPreview        PackPreview           `json:"packPreview"`
 	Host               *Host                 `json:"host"`
 	Players            []Player              `json:"players"`
+	Spectators         []Spectator           `json:"spectators"`
 	CurrentRound       *string               `json:"currentRound"`
 	AvailableQuestions AvailableQuestions    `json:"availableQuestions"`
 	CurrentPlayer      *primitive.ObjectID   `json:"currentPlayer"`
@@ -21,6 +22,8 @@ type PlayerRoom struct {
 	FinalRoundState    hiddenFinalRoundState `json:"finalRoundState"`
 	DeadlineAt         time.Time             `json:"deadlineAt"`
 	PausedState        PausedState           `json:"pausedState"`
+	MediaState         *MediaState           `json:"mediaState"`
+	FinishedAt         *time.Time            `json:"finishedAt"`
 }
 
 func NewPlayerRoom(room *Room) PlayerRoom {
@@ -48,6 +51,7 @@ func NewPlayerRoom(room *Room) PlayerRoom {
 		Id:                 room.Id,
 		Name:               room.Name,
 		Players:            room.Players,
+		Spectators:         room.Spectators,
 		Host:               room.Host,
 		CurrentRound:       room.CurrentRound,
 		AvailableQuestions: room.AvailableQuestions,
@@ -62,5 +66,7 @@ func NewPlayerRoom(room *Room) PlayerRoom {
 		},
 		DeadlineAt:  room.DeadlineAt,
 		PausedState: room.PausedState,
+		MediaState:  room.MediaState,
+		FinishedAt:  room.FinishedAt,
 	}
 }
@@ -0,0 +1,225 @@
+package entities
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/i18n"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// How many public packs are sampled to assemble one random pack
+const RANDOM_PACK_SOURCES = 100
+
+// Only the latest matches of every player are looked at to tell seen questions
+const SEEN_MATCHES_LIMIT = 500
+
+// Values of questions taken from different packs are not comparable, they are set anew
+const RANDOM_PACK_VALUE_STEP = 100
+
+type RandomPackOptions struct {
+	Language        string     `json:"language" binding:"omitempty,max=35,bcp47_language_tag"`
+	Tags            []string   `json:"tags" binding:"max=10,dive,min=1,max=25"`
+	Difficulty      Difficulty `json:"difficulty" binding:"omitempty,oneof=easy medium hard"`
+	Rounds          int        `json:"rounds" binding:"min=1,max=5"`
+	Categories      int        `json:"categories" binding:"min=1,max=10"`
+	Questions       int        `json:"questions" binding:"min=1,max=10"`
+	FinalCategories int        `json:"finalCategories" binding:"min=0,max=10"`
+}
+
+// Question of a pack as it is remembered in match history
+type seenQuestion struct {
+	packId   primitive.ObjectID
+	round    string
+	category string
+	index    int
+}
+
+type seenHistory struct {
+	questions map[seenQuestion]bool
+	// packs any of the players has finished, their final rounds are known
+	packs map[primitive.ObjectID]bool
+}
+
+// Questions the users have played according to their match history
+func getSeenHistory(mdb *mongo.Database, userIds []primitive.ObjectID) (*seenHistory, error) {
+	history := &seenHistory{
+		questions: make(map[seenQuestion]bool),
+		packs:     make(map[primitive.ObjectID]bool),
+	}
+	if len(userIds) == 0 {
+		return history, nil
+	}
+
+	cursor, err := mdb.Collection(MATCHES_COLLECTION).Find(
+		context.TODO(),
+		bson.M{"players.user._id": bson.M{"$in": userIds}},
+		options.Find().
+			SetSort(bson.D{{Key: "finishedAt", Value: -1}}).
+			SetLimit(int64(SEEN_MATCHES_LIMIT*len(userIds))).
+			SetProjection(bson.M{"packId": 1, "playedQuestions": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]Match, 0)
+	if err := cursor.All(context.TODO(), &matches); err != nil {
+		return nil, err
+	}
+
+	for _, match := range matches {
+		history.packs[match.PackId] = true
+		for _, ref := range match.PlayedQuestions {
+			packId := match.PackId
+			// questions of random packs remember the pack they were taken from
+			if ref.PackId != nil {
+				packId = *ref.PackId
+			}
+			history.questions[seenQuestion{packId, ref.Round, ref.Category, ref.Index}] = true
+		}
+	}
+	return history, nil
+}
+
+// Assembles a pack of categories sampled from public packs matching the options,
+// leaving out questions any of the users has already played
+func GenerateRandomPack(mdb *mongo.Database, opts RandomPackOptions, userIds []primitive.ObjectID) (*Pack, custErrors.HttpError) {
+	opts.Tags = normalizeTags(opts.Tags)
+	opts.Language = strings.ToLower(opts.Language)
+	// names are in the language of the questions, when it is one of the supported ones
+	lang := i18n.Match(opts.Language)
+	history, err := getSeenHistory(mdb, userIds)
+	if err != nil {
+		return nil, custErrors.NewInternalError(err)
+	}
+
+	match := bson.M{
+		"type":     Public,
+		"isHidden": bson.M{"$ne": true},
+		"status":   bson.M{"$ne": Draft},
+	}
+	if opts.Language != "" {
+		match["language"] = opts.Language
+	}
+	if len(opts.Tags) > 0 {
+		match["tags"] = bson.M{"$all": opts.Tags}
+	}
+	if opts.Difficulty != "" {
+		match["difficulty"] = opts.Difficulty
+	}
+	cursor, err := mdb.Collection(PACKS_COLLECTION).Aggregate(context.TODO(), bson.A{
+		bson.M{"$match": match},
+		bson.M{"$sample": bson.M{"size": RANDOM_PACK_SOURCES}},
+		bson.M{"$project": bson.M{"rounds": 1, "finalRound": 1}},
+	})
+	if err != nil {
+		return nil, custErrors.NewInternalError(err)
+	}
+	sources := make([]Pack, 0)
+	if err := cursor.All(context.TODO(), &sources); err != nil {
+		return nil, custErrors.NewInternalError(err)
+	}
+
+	categories := make([]Category, 0)
+	finalCategories := make([]FinalCategory, 0)
+	for _, source := range sources {
+		for _, round := range source.Rounds {
+			for _, category := range round.Categories {
+				if sampled, ok := sampleCategory(source.Id, round.Name, category, opts.Questions, history); ok {
+					categories = append(categories, sampled)
+				}
+			}
+		}
+		if !history.packs[source.Id] {
+			finalCategories = append(finalCategories, source.FinalRound.Categories...)
+		}
+	}
+	rand.Shuffle(len(categories), func(i, j int) {
+		categories[i], categories[j] = categories[j], categories[i]
+	})
+	rand.Shuffle(len(finalCategories), func(i, j int) {
+		finalCategories[i], finalCategories[j] = finalCategories[j], finalCategories[i]
+	})
+
+	// names must differ within a round, they are kept apart in the whole pack not to confuse players
+	usedNames := make(map[string]bool)
+	rounds := make([]Round, opts.Rounds)
+	for i := range rounds {
+		rounds[i] = Round{Name: i18n.Translate(lang, "randomPackRound", i+1), Categories: make([]Category, 0, opts.Categories)}
+		for len(rounds[i].Categories) < opts.Categories && len(categories) > 0 {
+			category := categories[0]
+			categories = categories[1:]
+			if usedNames[category.Name] {
+				continue
+			}
+			usedNames[category.Name] = true
+			for j := range category.Questions {
+				category.Questions[j].Value = (j + 1) * RANDOM_PACK_VALUE_STEP * (i + 1)
+			}
+			rounds[i].Categories = append(rounds[i].Categories, category)
+		}
+		if len(rounds[i].Categories) < opts.Categories {
+			return nil, custErrors.NewHttpError(
+				http.StatusUnprocessableEntity,
+				custErrors.NotEnoughQuestions,
+			)
+		}
+	}
+
+	finalRound := FinalRound{Categories: make([]FinalCategory, 0, opts.FinalCategories)}
+	for _, category := range finalCategories {
+		if len(finalRound.Categories) == opts.FinalCategories {
+			break
+		}
+		if slices.ContainsFunc(finalRound.Categories, func(fc FinalCategory) bool { return fc.Name == category.Name }) {
+			continue
+		}
+		finalRound.Categories = append(finalRound.Categories, category)
+	}
+
+	return &Pack{
+		Status:  Published,
+		Version: 1,
+		PackDTO: PackDTO{
+			Name:       i18n.Translate(lang, "randomPackName"),
+			Type:       Private,
+			Tags:       opts.Tags,
+			Language:   opts.Language,
+			Difficulty: opts.Difficulty,
+			Rounds:     rounds,
+			FinalRound: finalRound,
+		},
+	}, nil
+}
+
+// Takes the first count unplayed questions of the category in the order of the board,
+// false if there are not enough of them
+func sampleCategory(packId primitive.ObjectID, roundName string, category Category, count int, history *seenHistory) (Category, bool) {
+	questions := slices.Clone(category.Questions)
+	slices.SortFunc(questions, func(a, b Question) int {
+		return a.Index - b.Index
+	})
+
+	sampled := Category{Name: category.Name, Questions: make([]Question, 0, count)}
+	for _, question := range questions {
+		if len(sampled.Questions) == count {
+			break
+		}
+		if history.questions[seenQuestion{packId, roundName, category.Name, question.Index}] {
+			continue
+		}
+		source := &QuestionRef{PackId: &packId, Round: roundName, Category: category.Name, Index: question.Index}
+		question.Index = len(sampled.Questions)
+		question.BankRef = nil
+		question.Source = source
+		sampled.Questions = append(sampled.Questions, question)
+	}
+	return sampled, len(sampled.Questions) == count
+}
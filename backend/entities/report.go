@@ -0,0 +1,110 @@
+package entities
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/holdennekt/sgame/custErrors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const REPORTS_COLLECTION = "reports"
+
+type ReportStatus string
+
+const (
+	ReportOpen     ReportStatus = "open"
+	ReportResolved ReportStatus = "resolved"
+)
+
+type ModerationAction string
+
+const (
+	ActionHide    ModerationAction = "hide"
+	ActionDelete  ModerationAction = "delete"
+	ActionDismiss ModerationAction = "dismiss"
+)
+
+type Report struct {
+	Id         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	PackId     primitive.ObjectID `json:"packId" bson:"packId"`
+	PackName   string             `json:"packName" bson:"packName"`
+	ReportedBy User               `json:"reportedBy" bson:"reportedBy"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	Status     ReportStatus       `json:"status" bson:"status"`
+	Resolution *Resolution        `json:"resolution" bson:"resolution,omitempty"`
+	ReportDTO  `bson:"inline"`
+}
+
+type ReportDTO struct {
+	Question *QuestionRef `json:"question" bson:"question,omitempty" binding:"omitnil"`
+	Reason   string       `json:"reason" bson:"reason" binding:"oneof=offensive incorrect broken spam other"`
+	Comment  string       `json:"comment" bson:"comment" binding:"max=500"`
+}
+
+// Points to a question of a regular round, or of the final round when Round is empty
+type QuestionRef struct {
+	// Set only for questions of random packs, which are taken from other packs
+	PackId   *primitive.ObjectID `json:"packId,omitempty" bson:"packId,omitempty"`
+	Round    string              `json:"round" bson:"round" binding:"max=50"`
+	Category string              `json:"category" bson:"category" binding:"min=1,max=25"`
+	Index    int                 `json:"index" bson:"index" binding:"min=0,max=9"`
+}
+
+type Resolution struct {
+	ResolutionDTO `bson:"inline"`
+	ResolvedBy    User      `json:"resolvedBy" bson:"resolvedBy"`
+	ResolvedAt    time.Time `json:"resolvedAt" bson:"resolvedAt"`
+}
+
+type ResolutionDTO struct {
+	Action  ModerationAction `json:"action" bson:"action" binding:"oneof=hide delete dismiss"`
+	Comment string           `json:"comment" bson:"comment" binding:"max=500"`
+}
+
+func (qr QuestionRef) ExistsIn(pack *Pack) bool {
+	if qr.Round == "" {
+		return slices.ContainsFunc(pack.FinalRound.Categories, func(fc FinalCategory) bool {
+			return fc.Name == qr.Category
+		})
+	}
+	for _, round := range pack.Rounds {
+		if round.Name != qr.Round {
+			continue
+		}
+		for _, category := range round.Categories {
+			if category.Name != qr.Category {
+				continue
+			}
+			return slices.ContainsFunc(category.Questions, func(q Question) bool {
+				return q.Index == qr.Index
+			})
+		}
+	}
+	return false
+}
+
+func GetReport(mdb *mongo.Database, id primitive.ObjectID) (*Report, custErrors.HttpError) {
+	var report Report
+
+	err := mdb.Collection(REPORTS_COLLECTION).FindOne(
+		context.TODO(),
+		bson.D{{Key: "_id", Value: id}},
+	).Decode(&report)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, custErrors.NewHttpError(
+				http.StatusNotFound,
+				custErrors.ReportNotFound, id,
+			)
+		}
+		return nil, custErrors.NewInternalError(err)
+	}
+
+	return &report, nil
+}
@@ -7,15 +7,22 @@ import (
 	"slices"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/holdennekt/sgame/custErrors"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 const ROOM_PREFIX = "room:"
+const USER_ROOMS_PREFIX = "userRooms:"
 const ANSWERING_TIME = 5 * time.Second
 
+// Clients that have not buffered media by then are not waited for
+const MEDIA_BUFFER_TIMEOUT = 10 * time.Second
+
+// Gives the start message time to reach every client before the playback starts
+const MEDIA_START_DELAY = 1 * time.Second
+const MAX_MEDIA_DURATION = 10 * time.Minute
+
 type Room struct {
 	Id primitive.ObjectID `json:"id"`
 	RoomDTO
@@ -23,6 +30,7 @@ type Room struct {
 	CreatedBy          primitive.ObjectID   `json:"createdBy"`
 	Host               *Host                `json:"host"`
 	Players            []Player             `json:"players"`
+	Spectators         []Spectator          `json:"spectators"`
 	BanList            []User               `json:"banList"`
 	CurrentRound       *string              `json:"currentRound"`
 	AvailableQuestions AvailableQuestions   `json:"availableQuestions"`
@@ -33,11 +41,18 @@ type Room struct {
 	FinalRoundState    FinalRoundState      `json:"finalRoundState"`
 	DeadlineAt         time.Time            `json:"deadlineAt"`
 	PausedState        PausedState          `json:"pausedState"`
+	MediaState         *MediaState          `json:"mediaState"`
+	PlayedQuestions    []QuestionRef        `json:"playedQuestions"`
+	FinishedAt         *time.Time           `json:"finishedAt"`
+	// Random rooms carry their pack, it is not stored anywhere else
+	GeneratedPack *Pack `json:"generatedPack,omitempty"`
 }
 
 type RoomDTO struct {
-	Name    string             `json:"name" binding:"min=1,max=50"`
-	PackId  primitive.ObjectID `json:"packId" binding:"required"`
+	Name   string             `json:"name" binding:"min=1,max=50"`
+	PackId primitive.ObjectID `json:"packId" binding:"required_without=Random"`
+	// The pack is assembled from public packs instead of being chosen
+	Random  *RandomPackOptions `json:"random" binding:"omitnil"`
 	Options roomOptions        `json:"options"`
 }
 
@@ -48,6 +63,8 @@ type roomOptions struct {
 	ThinkingTime        int         `json:"thinkingTime" binding:"min=1,max=30"`
 	ThinkingTimeFinal   int         `json:"thinkingTimeFinal" binding:"min=1,max=120"`
 	IsFalseStartAllowed bool        `json:"isFalseStartAllowed"`
+	// guests may join public rooms unless the host disallows them
+	AreGuestsDisallowed bool `json:"areGuestsDisallowed"`
 }
 
 type PrivacyType string
@@ -85,6 +102,27 @@ type BoardQuestion struct {
 	HasBeenPlayed bool `json:"hasBeenPlayed"`
 }
 
+type MediaPhase string
+
+const (
+	MediaBuffering MediaPhase = "buffering"
+	MediaPlaying   MediaPhase = "playing"
+	MediaEnded     MediaPhase = "ended"
+)
+
+// Synchronized playback of audio or video of the current question.
+// Buzzing is armed only once the playback ends
+type MediaState struct {
+	Id             string               `json:"id"`
+	Phase          MediaPhase           `json:"phase"`
+	Attachment     Attachment           `json:"attachment"`
+	BufferedBy     []primitive.ObjectID `json:"bufferedBy"`
+	Durations      map[string]float64   `json:"durations"`
+	BufferDeadline time.Time            `json:"bufferDeadline"`
+	StartAt        *time.Time           `json:"startAt"`
+	EndsAt         *time.Time           `json:"endsAt"`
+}
+
 type PausedState struct {
 	IsPaused bool      `json:"isPaused"`
 	PausedAt time.Time `json:"pausedAt"`
@@ -103,8 +141,14 @@ func (r *Room) IsUserPlayer(userId primitive.ObjectID) bool {
 	})
 }
 
+func (r *Room) IsUserSpectator(userId primitive.ObjectID) bool {
+	return slices.ContainsFunc(r.Spectators, func(spectator Spectator) bool {
+		return userId == spectator.Id
+	})
+}
+
 func (r *Room) IsUserIn(userId primitive.ObjectID) bool {
-	return r.IsUserHost(userId) || r.IsUserPlayer(userId)
+	return r.IsUserHost(userId) || r.IsUserPlayer(userId) || r.IsUserSpectator(userId)
 }
 
 func (r *Room) AnyAvailableQuestions() bool {
@@ -126,14 +170,113 @@ func (r *Room) GetProjection(userId primitive.ObjectID) any {
 	return NewPlayerRoom(r)
 }
 
+func (r *Room) IsRandom() bool {
+	return r.GeneratedPack != nil
+}
+
+// The pack the game goes on with. Random rooms generate their pack again
+// when the game starts, so the one loaded on connection may be outdated
+func (r *Room) PackInPlay(loaded *Pack) *Pack {
+	if r.GeneratedPack != nil {
+		return r.GeneratedPack
+	}
+	return loaded
+}
+
 func (r *Room) EndQuestion(pack *Pack) {
 	r.CurrentQuestion = nil
+	r.MediaState = nil
 	r.AllowedToAnswer = make([]primitive.ObjectID, 0)
 	if !r.AnyAvailableQuestions() {
 		r.StartNextRound(pack)
 	}
 }
 
+func (q *Question) HasPlayableMedia() bool {
+	return q.Attachment != nil && (q.Attachment.MediaType == Audio || q.Attachment.MediaType == Video)
+}
+
+func (r *Room) PlayerIds() []primitive.ObjectID {
+	playerIds := make([]primitive.ObjectID, len(r.Players))
+	for i, player := range r.Players {
+		playerIds[i] = player.Id
+	}
+	return playerIds
+}
+
+func (r *Room) PrepareMedia(id string, attachment Attachment) {
+	r.AllowedToAnswer = make([]primitive.ObjectID, 0)
+	r.MediaState = &MediaState{
+		Id:             id,
+		Phase:          MediaBuffering,
+		Attachment:     attachment,
+		BufferedBy:     make([]primitive.ObjectID, 0),
+		Durations:      make(map[string]float64),
+		BufferDeadline: time.Now().Add(MEDIA_BUFFER_TIMEOUT),
+	}
+}
+
+// Connected host and players, spectators are not waited for
+func (r *Room) MediaAudience() []primitive.ObjectID {
+	audience := make([]primitive.ObjectID, 0, len(r.Players)+1)
+	if r.Host != nil && r.Host.IsConnected {
+		audience = append(audience, r.Host.Id)
+	}
+	for _, player := range r.Players {
+		if player.IsConnected {
+			audience = append(audience, player.Id)
+		}
+	}
+	return audience
+}
+
+func (r *Room) IsMediaBuffered() bool {
+	for _, userId := range r.MediaAudience() {
+		if !slices.Contains(r.MediaState.BufferedBy, userId) {
+			return false
+		}
+	}
+	return true
+}
+
+// Duration reported by the host is trusted, otherwise the median of the
+// reported ones is taken, so that a single client can not delay buzzing much
+func (ms *MediaState) duration(hostId string) time.Duration {
+	var seconds float64
+	if hostDuration, ok := ms.Durations[hostId]; ok {
+		seconds = hostDuration
+	} else if len(ms.Durations) > 0 {
+		durations := make([]float64, 0, len(ms.Durations))
+		for _, duration := range ms.Durations {
+			durations = append(durations, duration)
+		}
+		slices.Sort(durations)
+		seconds = durations[len(durations)/2]
+	}
+	duration := time.Duration(seconds * float64(time.Second))
+	if duration > MAX_MEDIA_DURATION {
+		return MAX_MEDIA_DURATION
+	}
+	return duration
+}
+
+func (r *Room) StartMedia() {
+	hostId := ""
+	if r.Host != nil {
+		hostId = r.Host.Id.Hex()
+	}
+	startAt := time.Now().Add(MEDIA_START_DELAY)
+	endsAt := startAt.Add(r.MediaState.duration(hostId))
+	r.MediaState.Phase = MediaPlaying
+	r.MediaState.StartAt = &startAt
+	r.MediaState.EndsAt = &endsAt
+}
+
+func (r *Room) ArmBuzzing() {
+	r.MediaState.Phase = MediaEnded
+	r.AllowedToAnswer = r.PlayerIds()
+}
+
 func (r *Room) StartNextRound(pack *Pack) {
 	currentRoundIndex := slices.IndexFunc(pack.Rounds, func(round Round) bool {
 		return *r.CurrentRound == round.Name
@@ -162,6 +305,29 @@ func (r *Room) startFinalRound(pack *Pack) {
 			})
 		}
 	}
+	// nobody may play the final round
+	if len(r.FinalRoundState.Players) == 0 || len(pack.FinalRound.Categories) == 0 {
+		r.Finish()
+	}
+}
+
+func (r *Room) IsStarted() bool {
+	return r.CurrentRound != nil || r.FinalRoundState.IsActive
+}
+
+func (r *Room) IsFinished() bool {
+	return r.FinishedAt != nil
+}
+
+func (r *Room) Finish() {
+	now := time.Now()
+	r.FinishedAt = &now
+	r.CurrentRound = nil
+	r.CurrentQuestion = nil
+	r.AnsweringPlayer = nil
+	r.MediaState = nil
+	r.AllowedToAnswer = make([]primitive.ObjectID, 0)
+	r.FinalRoundState.IsActive = false
 }
 
 func (r *Room) InitAvailableQuestions(round Round) {
@@ -199,7 +365,7 @@ func GetRoomByKey(rds *redis.Client, key string) (*Room, custErrors.HttpError) {
 	if res == "" {
 		return nil, custErrors.NewHttpError(
 			http.StatusNotFound,
-			gin.H{"error": "no room with such id"},
+			custErrors.RoomNotFound,
 		)
 	}
 
@@ -217,3 +383,25 @@ func GetRoomById(rds *redis.Client, id primitive.ObjectID) (*Room, custErrors.Ht
 func GetRoomRedisKey(id string) string {
 	return ROOM_PREFIX + id
 }
+
+func GetRoomKeys(rds *redis.Client) ([]string, error) {
+	keys := make([]string, 0)
+	iter := rds.Scan(context.TODO(), 0, ROOM_PREFIX+"*", 0).Iterator()
+	for iter.Next(context.TODO()) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Ids of the rooms the user has entered. Rooms that are gone or that the user has left
+// are not removed when it happens, readers are expected to skip them
+func GetUserRoomsRedisKey(userId string) string {
+	return USER_ROOMS_PREFIX + userId
+}
+
+func GetUserRoomIds(rds *redis.Client, userId primitive.ObjectID) ([]string, error) {
+	return rds.SMembers(context.TODO(), GetUserRoomsRedisKey(userId.Hex())).Result()
+}
@@ -3,11 +3,11 @@ package entities
 import (
 	"context"
 	"errors"
-	"fmt"
 	"net/http"
+	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/holdennekt/sgame/custErrors"
+	"github.com/holdennekt/sgame/i18n"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -16,21 +16,116 @@ import (
 const USERS_COLLECTION = "users"
 
 var SYSTEM User = User{Id: primitive.NilObjectID}
+var DELETED_USER User = User{Id: primitive.NilObjectID, Name: "deleted user"}
 
 type User struct {
-	Id     primitive.ObjectID `json:"id" bson:"_id,omitempty" binding:"required"`
-	Name   string             `json:"name" bson:"name" binding:"min=1,max=20"`
-	Avatar *string            `json:"avatar" bson:"avatar" binding:"omitnil,url"`
+	Id      primitive.ObjectID `json:"id" bson:"_id,omitempty" binding:"required"`
+	Name    string             `json:"name" bson:"name" binding:"min=1,max=20"`
+	Avatar  *string            `json:"avatar" bson:"avatar" binding:"omitnil,url"`
+	IsGuest bool               `json:"isGuest" bson:"isGuest"`
+}
+
+type ProfileDTO struct {
+	Name   string  `json:"name" binding:"min=1,max=20"`
+	Avatar *string `json:"avatar" binding:"omitnil,url,max=2000"`
+}
+
+// Users logging in only with third party identities have no current password and set their first one
+type PasswordDTO struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword" binding:"min=8,max=40"`
+}
+
+// Empty language removes the preference, then the one of the client is used
+type LanguageDTO struct {
+	Language i18n.Language `json:"language" binding:"omitempty,oneof=en uk"`
+}
+
+type DeleteUserDTO struct {
+	Password string `json:"password"`
+}
+
+type GuestDTO struct {
+	Name string `json:"name" form:"name" binding:"min=1,max=20"`
 }
 
 type DbUserDTO struct {
-	Login    string `json:"login" form:"login" bson:"login" binding:"min=4,max=20"`
-	Password string `json:"password" form:"password" bson:"password" binding:"min=8,max=40"`
+	Login    string `json:"login" form:"login" bson:"login,omitempty" binding:"min=4,max=20"`
+	Password string `json:"password" form:"password" bson:"password,omitempty" binding:"min=8,max=40"`
+}
+
+type Identity struct {
+	Provider string `json:"provider" bson:"provider"`
+	Subject  string `json:"subject" bson:"subject"`
+}
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleUser:      0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+type RoleDTO struct {
+	Role Role `json:"role" binding:"oneof=user moderator admin"`
+}
+
+type Suspension struct {
+	SuspensionDTO `bson:"inline"`
+	SuspendedBy   primitive.ObjectID `json:"suspendedBy" bson:"suspendedBy"`
+	SuspendedAt   time.Time          `json:"suspendedAt" bson:"suspendedAt"`
+}
+
+type SuspensionDTO struct {
+	Reason string     `json:"reason" binding:"min=1,max=200"`
+	Until  *time.Time `json:"until" binding:"omitnil"`
 }
 
 type DbUser struct {
-	User      `bson:"inline"`
-	DbUserDTO `bson:"inline"`
+	User       `bson:"inline"`
+	DbUserDTO  `bson:"inline"`
+	Role       Role        `bson:"role,omitempty"`
+	Suspension *Suspension `bson:"suspension,omitempty"`
+	Identities []Identity  `bson:"identities,omitempty"`
+	ExpiresAt  *time.Time  `bson:"expiresAt,omitempty"`
+	// Language of server generated text chosen by the user
+	Language i18n.Language `bson:"language,omitempty"`
+}
+
+// Users without explicitly set role are regular users
+func (r Role) Includes(role Role) bool {
+	return roleRanks[r] >= roleRanks[role]
+}
+
+func (u *DbUser) GetRole() Role {
+	if u.Role == "" {
+		return RoleUser
+	}
+	return u.Role
+}
+
+func (u *DbUser) IsSuspended() bool {
+	return u.Suspension != nil && (u.Suspension.Until == nil || u.Suspension.Until.After(time.Now()))
+}
+
+func (u *DbUser) SuspensionError() custErrors.HttpError {
+	if u.Suspension.Until != nil {
+		return custErrors.NewHttpError(
+			http.StatusForbidden,
+			custErrors.AccountSuspendedUntil, u.Suspension.Reason, u.Suspension.Until.Format(time.RFC3339),
+		)
+	}
+	return custErrors.NewHttpError(
+		http.StatusForbidden,
+		custErrors.AccountSuspended, u.Suspension.Reason,
+	)
 }
 
 type Host struct {
@@ -38,6 +133,11 @@ type Host struct {
 	IsConnected bool `json:"isConnected"`
 }
 
+type Spectator struct {
+	User
+	IsConnected bool `json:"isConnected"`
+}
+
 type Player struct {
 	User
 	Score       int  `json:"score"`
@@ -54,7 +154,7 @@ func GetDbUser(mdb *mongo.Database, userId primitive.ObjectID) (*DbUser, custErr
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, custErrors.NewHttpError(
 				http.StatusNotFound,
-				gin.H{"error": fmt.Sprintf("there is no user with id \"%s\"", userId)},
+				custErrors.UserNotFound, userId,
 			)
 		}
 		return nil, custErrors.NewInternalError(err)
@@ -73,7 +173,7 @@ func GetDbUserByLogin(mdb *mongo.Database, login string) (*DbUser, custErrors.Ht
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, custErrors.NewHttpError(
 				http.StatusNotFound,
-				gin.H{"error": fmt.Sprintf("there is no user with login \"%s\"", login)},
+				custErrors.LoginNotFound, login,
 			)
 		}
 		return nil, custErrors.NewInternalError(err)
@@ -92,7 +192,7 @@ func GetUser(mdb *mongo.Database, userId primitive.ObjectID) (*User, custErrors.
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, custErrors.NewHttpError(
 				http.StatusNotFound,
-				gin.H{"error": fmt.Sprintf("there is no user with id \"%s\"", userId)},
+				custErrors.UserNotFound, userId,
 			)
 		}
 		return nil, custErrors.NewInternalError(err)
@@ -100,3 +200,25 @@ func GetUser(mdb *mongo.Database, userId primitive.ObjectID) (*User, custErrors.
 
 	return &user, nil
 }
+
+func GetDbUserByIdentity(mdb *mongo.Database, identity Identity) (*DbUser, custErrors.HttpError) {
+	var dbUser DbUser
+	err := mdb.Collection(USERS_COLLECTION).FindOne(
+		context.TODO(),
+		bson.D{{Key: "identities", Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+			{Key: "provider", Value: identity.Provider},
+			{Key: "subject", Value: identity.Subject},
+		}}}}},
+	).Decode(&dbUser)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, custErrors.NewHttpError(
+				http.StatusNotFound,
+				custErrors.IdentityUserNotFound, identity.Provider, identity.Subject,
+			)
+		}
+		return nil, custErrors.NewInternalError(err)
+	}
+
+	return &dbUser, nil
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"log"
+	"net/http"
 	"os"
 	"strconv"
+	"time"
 )
 
 func getEnvVar(name string) string {
@@ -22,3 +24,64 @@ func getEnvVarInt(name string) int {
 	}
 	return num
 }
+
+func getEnvVarOrDefault(name string, defaultValue string) string {
+	variable, present := os.LookupEnv(name)
+	if !present {
+		return defaultValue
+	}
+	return variable
+}
+
+func getEnvVarInt64(name string, defaultValue int64) int64 {
+	variable, present := os.LookupEnv(name)
+	if !present {
+		return defaultValue
+	}
+	num, err := strconv.ParseInt(variable, 10, 64)
+	if err != nil {
+		log.Fatalf("%s env variable is not int", name)
+	}
+	return num
+}
+
+func getEnvVarBool(name string, defaultValue bool) bool {
+	variable, present := os.LookupEnv(name)
+	if !present {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(variable)
+	if err != nil {
+		log.Fatalf("%s env variable is not bool", name)
+	}
+	return b
+}
+
+func getEnvVarDuration(name string, defaultValue time.Duration) time.Duration {
+	variable, present := os.LookupEnv(name)
+	if !present {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(variable)
+	if err != nil {
+		log.Fatalf("%s env variable is not duration", name)
+	}
+	return d
+}
+
+func getEnvVarSameSite(name string, defaultValue http.SameSite) http.SameSite {
+	variable, present := os.LookupEnv(name)
+	if !present {
+		return defaultValue
+	}
+	switch variable {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	log.Fatalf("%s env variable must be one of lax, strict, none", name)
+	return defaultValue
+}
@@ -0,0 +1,129 @@
+// Package i18n translates server generated text. Messages are looked up by
+// stable keys (error codes, lint checks) in catalogs embedded from catalogs/*.json,
+// every catalog maps a key to a fmt format taking the same arguments.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Language string
+
+const (
+	English   Language = "en"
+	Ukrainian Language = "uk"
+)
+
+// Used when neither the user nor the client has asked for a supported language
+const DEFAULT_LANGUAGE = English
+
+// Set by api.AuthorizeConnection when the user has chosen a language
+const LANGUAGE_CONTEXT_KEY = "language"
+
+//go:embed catalogs/*.json
+var catalogFiles embed.FS
+
+var catalogs = make(map[Language]map[string]string)
+
+func init() {
+	files, err := catalogFiles.ReadDir("catalogs")
+	if err != nil {
+		panic(err)
+	}
+	for _, file := range files {
+		content, err := catalogFiles.ReadFile(path.Join("catalogs", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		catalog := make(map[string]string)
+		if err := json.Unmarshal(content, &catalog); err != nil {
+			panic(fmt.Errorf("catalog %s: %w", file.Name(), err))
+		}
+		catalogs[Language(strings.TrimSuffix(file.Name(), path.Ext(file.Name())))] = catalog
+	}
+	if _, ok := catalogs[DEFAULT_LANGUAGE]; !ok {
+		panic("i18n: catalog of the default language is missing")
+	}
+}
+
+func IsSupported(lang string) bool {
+	_, ok := catalogs[Language(lang)]
+	return ok
+}
+
+// Formats the message of the key in the language. Keys missing in the catalog
+// fall back to the default language and then to the key itself
+func Translate(lang Language, key string, args ...any) string {
+	format, ok := catalogs[lang][key]
+	if !ok {
+		format, ok = catalogs[DEFAULT_LANGUAGE][key]
+	}
+	if !ok {
+		format = key
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Supported language of a tag like "uk-UA", DEFAULT_LANGUAGE if there is none
+func Match(tag string) Language {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	if IsSupported(base) {
+		return Language(base)
+	}
+	return DEFAULT_LANGUAGE
+}
+
+type weightedLanguage struct {
+	lang   Language
+	weight float64
+}
+
+// Picks the supported language the client prefers the most according to
+// Accept-Language header, like "uk-UA,uk;q=0.9,en;q=0.8"
+func Negotiate(acceptLanguage string) Language {
+	candidates := make([]weightedLanguage, 0)
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if !IsSupported(base) {
+			continue
+		}
+		weight := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if weight > 0 {
+			candidates = append(candidates, weightedLanguage{Language(base), weight})
+		}
+	}
+	if len(candidates) == 0 {
+		return DEFAULT_LANGUAGE
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].weight > candidates[j].weight
+	})
+	return candidates[0].lang
+}
+
+// The language chosen by the user, otherwise the one negotiated with the client
+func FromContext(c *gin.Context) Language {
+	if lang, ok := c.Get(LANGUAGE_CONTEXT_KEY); ok {
+		return lang.(Language)
+	}
+	return Negotiate(c.GetHeader("Accept-Language"))
+}
@@ -6,11 +6,14 @@ import (
 
 	"github.com/holdennekt/sgame/entities"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const CODE_NAMESPACE_EXISTS = 48
+const CODE_INDEX_OPTIONS_CONFLICT = 85
+const CODE_INDEX_KEY_SPECS_CONFLICT = 86
 
 func handleError(err error) {
 	if err != nil {
@@ -24,6 +27,14 @@ func handleError(err error) {
 	}
 }
 
+func isIndexConflict(err error) bool {
+	mongoErr, ok := err.(mongo.CommandError)
+	if !ok {
+		return false
+	}
+	return mongoErr.Code == CODE_INDEX_OPTIONS_CONFLICT || mongoErr.Code == CODE_INDEX_KEY_SPECS_CONFLICT
+}
+
 func InitDB(parent context.Context, mdb *mongo.Database) {
 	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
 	defer cancel()
@@ -31,11 +42,48 @@ func InitDB(parent context.Context, mdb *mongo.Database) {
 	if err != nil {
 		handleError(err)
 	}
+	loginIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "login", Value: 1}},
+		Options: options.Index().
+			SetName("login_unique").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.D{{Key: "login", Value: bson.D{{Key: "$exists", Value: true}}}}),
+	}
+	_, err = mdb.Collection(entities.USERS_COLLECTION).Indexes().CreateOne(context.TODO(), loginIndex)
+	if err != nil && isIndexConflict(err) {
+		// guests have no login, so the old non-partial index has to be replaced
+		_, err = mdb.Collection(entities.USERS_COLLECTION).Indexes().DropOne(context.TODO(), "login_unique")
+		if err != nil {
+			handleError(err)
+		}
+		_, err = mdb.Collection(entities.USERS_COLLECTION).Indexes().CreateOne(context.TODO(), loginIndex)
+	}
+	if err != nil {
+		handleError(err)
+	}
+
 	_, err = mdb.Collection(entities.USERS_COLLECTION).Indexes().CreateOne(
 		context.TODO(),
 		mongo.IndexModel{
-			Keys:    bson.D{{Key: "login", Value: 1}},
-			Options: options.Index().SetName("login_unique").SetUnique(true),
+			Keys: bson.D{
+				{Key: "identities.provider", Value: 1},
+				{Key: "identities.subject", Value: 1},
+			},
+			Options: options.Index().
+				SetName("identities_unique").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.D{{Key: "identities", Value: bson.D{{Key: "$exists", Value: true}}}}),
+		},
+	)
+	if err != nil {
+		handleError(err)
+	}
+
+	_, err = mdb.Collection(entities.USERS_COLLECTION).Indexes().CreateOne(
+		context.TODO(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetName("expiresAt_ttl").SetExpireAfterSeconds(0),
 		},
 	)
 	if err != nil {
@@ -47,14 +95,284 @@ func InitDB(parent context.Context, mdb *mongo.Database) {
 		handleError(err)
 	}
 
+	// names outweigh question texts. Packs in languages text search does not know,
+	// like ukrainian, are indexed without stemming
+	contentIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "content", Value: "text"}, {Key: "questionsContent", Value: "text"}},
+		Options: options.Index().
+			SetName("content_text").
+			SetWeights(bson.D{{Key: "content", Value: 10}, {Key: "questionsContent", Value: 1}}).
+			SetDefaultLanguage("none").
+			SetLanguageOverride("textSearchLanguage"),
+	}
+	_, err = mdb.Collection(entities.PACKS_COLLECTION).Indexes().CreateOne(context.TODO(), contentIndex)
+	if err != nil && isIndexConflict(err) {
+		// only one text index is allowed, the old one covering names only has to be replaced
+		_, err = mdb.Collection(entities.PACKS_COLLECTION).Indexes().DropOne(context.TODO(), "content_text")
+		if err != nil {
+			handleError(err)
+		}
+		_, err = mdb.Collection(entities.PACKS_COLLECTION).Indexes().CreateOne(context.TODO(), contentIndex)
+	}
+	if err != nil {
+		handleError(err)
+	}
+
+	// packs created before listings were sorted by them
+	for _, field := range []string{"playCount", "rating", "ratingsCount"} {
+		_, err = mdb.Collection(entities.PACKS_COLLECTION).UpdateMany(
+			context.TODO(),
+			bson.D{{Key: field, Value: bson.D{{Key: "$exists", Value: false}}}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: field, Value: 0}}}},
+		)
+		if err != nil {
+			handleError(err)
+		}
+	}
+
+	_, err = mdb.Collection(entities.PACKS_COLLECTION).Indexes().CreateMany(
+		context.TODO(),
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "playCount", Value: -1}, {Key: "_id", Value: -1}},
+				Options: options.Index().SetName("playCount_id"),
+			},
+			{
+				Keys:    bson.D{{Key: "rating", Value: -1}, {Key: "_id", Value: -1}},
+				Options: options.Index().SetName("rating_id"),
+			},
+		},
+	)
+	if err != nil {
+		handleError(err)
+	}
+
+	_, err = mdb.Collection(entities.PACKS_COLLECTION).Indexes().CreateOne(
+		context.TODO(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "author._id", Value: 1}},
+			Options: options.Index().SetName("author_id"),
+		},
+	)
+	if err != nil {
+		handleError(err)
+	}
+
+	_, err = mdb.Collection(entities.PACKS_COLLECTION).Indexes().CreateOne(
+		context.TODO(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "collaborators.user._id", Value: 1}},
+			Options: options.Index().SetName("collaborators_user_id"),
+		},
+	)
+	if err != nil {
+		handleError(err)
+	}
+
+	_, err = mdb.Collection(entities.PACKS_COLLECTION).Indexes().CreateOne(
+		context.TODO(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "tags", Value: 1}},
+			Options: options.Index().SetName("tags"),
+		},
+	)
+	if err != nil {
+		handleError(err)
+	}
+
 	_, err = mdb.Collection(entities.PACKS_COLLECTION).Indexes().CreateOne(
 		context.TODO(),
 		mongo.IndexModel{
-			Keys:    bson.D{{Key: "content", Value: "text"}},
-			Options: options.Index().SetName("content_text"),
+			Keys:    bson.D{{Key: "language", Value: 1}, {Key: "difficulty", Value: 1}},
+			Options: options.Index().SetName("language_difficulty"),
 		},
 	)
 	if err != nil {
 		handleError(err)
 	}
+
+	_, err = mdb.Collection(entities.PACKS_COLLECTION).Indexes().CreateOne(
+		context.TODO(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "rounds.categories.questions.bankRef.questionId", Value: 1}},
+			Options: options.Index().SetName("bankRef_questionId").SetSparse(true),
+		},
+	)
+	if err != nil {
+		handleError(err)
+	}
+
+	_, err = mdb.Collection(entities.PACKS_COLLECTION).Indexes().CreateOne(
+		context.TODO(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "forkedFrom.packId", Value: 1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetName("forkedFrom_packId_id").SetSparse(true),
+		},
+	)
+	if err != nil {
+		handleError(err)
+	}
+
+	err = mdb.CreateCollection(ctx, entities.PACK_VERSIONS_COLLECTION)
+	if err != nil {
+		handleError(err)
+	}
+
+	_, err = mdb.Collection(entities.PACK_VERSIONS_COLLECTION).Indexes().CreateOne(
+		context.TODO(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "packId", Value: 1}, {Key: "version", Value: 1}},
+			Options: options.Index().SetName("packId_version_unique").SetUnique(true),
+		},
+	)
+	if err != nil {
+		handleError(err)
+	}
+
+	err = mdb.CreateCollection(ctx, entities.MATCHES_COLLECTION)
+	if err != nil {
+		handleError(err)
+	}
+
+	_, err = mdb.Collection(entities.MATCHES_COLLECTION).Indexes().CreateMany(
+		context.TODO(),
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "roomId", Value: 1}},
+				Options: options.Index().SetName("roomId_unique").SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "players.user._id", Value: 1}, {Key: "packId", Value: 1}},
+				Options: options.Index().SetName("players_user_id_packId"),
+			},
+		},
+	)
+	if err != nil {
+		handleError(err)
+	}
+
+	err = mdb.CreateCollection(ctx, entities.PACK_RATINGS_COLLECTION)
+	if err != nil {
+		handleError(err)
+	}
+
+	_, err = mdb.Collection(entities.PACK_RATINGS_COLLECTION).Indexes().CreateMany(
+		context.TODO(),
+		[]mongo.IndexModel{
+			{
+				Keys: bson.D{{Key: "packId", Value: 1}, {Key: "user._id", Value: 1}},
+				// ratings of deleted users all belong to the same nil id
+				Options: options.Index().
+					SetName("packId_user_id_unique").
+					SetUnique(true).
+					SetPartialFilterExpression(bson.D{{Key: "user._id", Value: bson.D{{Key: "$gt", Value: primitive.NilObjectID}}}}),
+			},
+			{
+				Keys:    bson.D{{Key: "user._id", Value: 1}},
+				Options: options.Index().SetName("user_id"),
+			},
+		},
+	)
+	if err != nil {
+		handleError(err)
+	}
+
+	err = mdb.CreateCollection(ctx, entities.ACCESS_TOKENS_COLLECTION)
+	if err != nil {
+		handleError(err)
+	}
+
+	_, err = mdb.Collection(entities.ACCESS_TOKENS_COLLECTION).Indexes().CreateMany(
+		context.TODO(),
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "hash", Value: 1}},
+				Options: options.Index().SetName("hash_unique").SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "userId", Value: 1}},
+				Options: options.Index().SetName("userId"),
+			},
+			{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetName("expiresAt_ttl").SetExpireAfterSeconds(0),
+			},
+		},
+	)
+	if err != nil {
+		handleError(err)
+	}
+
+	err = mdb.CreateCollection(ctx, entities.REPORTS_COLLECTION)
+	if err != nil {
+		handleError(err)
+	}
+
+	_, err = mdb.Collection(entities.REPORTS_COLLECTION).Indexes().CreateMany(
+		context.TODO(),
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "status", Value: 1}, {Key: "_id", Value: 1}},
+				Options: options.Index().SetName("status_id"),
+			},
+			{
+				Keys:    bson.D{{Key: "packId", Value: 1}},
+				Options: options.Index().SetName("packId"),
+			},
+		},
+	)
+	if err != nil {
+		handleError(err)
+	}
+
+	err = mdb.CreateCollection(ctx, entities.MEDIA_COLLECTION)
+	if err != nil {
+		handleError(err)
+	}
+
+	_, err = mdb.Collection(entities.MEDIA_COLLECTION).Indexes().CreateOne(
+		context.TODO(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "refCount", Value: 1}, {Key: "unreferencedAt", Value: 1}},
+			Options: options.Index().SetName("refCount_unreferencedAt"),
+		},
+	)
+	if err != nil {
+		handleError(err)
+	}
+
+	err = mdb.CreateCollection(ctx, entities.BANK_QUESTIONS_COLLECTION)
+	if err != nil {
+		handleError(err)
+	}
+
+	_, err = mdb.Collection(entities.BANK_QUESTIONS_COLLECTION).Indexes().CreateMany(
+		context.TODO(),
+		[]mongo.IndexModel{
+			{
+				Keys:    bson.D{{Key: "ownerId", Value: 1}, {Key: "_id", Value: -1}},
+				Options: options.Index().SetName("ownerId_id"),
+			},
+			{
+				Keys:    bson.D{{Key: "ownerId", Value: 1}, {Key: "tags", Value: 1}},
+				Options: options.Index().SetName("ownerId_tags"),
+			},
+		},
+	)
+	if err != nil {
+		handleError(err)
+	}
+}
+
+func PromoteAdmin(parent context.Context, mdb *mongo.Database, login string) {
+	ctx, cancel := context.WithTimeout(parent, 5*time.Second)
+	defer cancel()
+	_, err := mdb.Collection(entities.USERS_COLLECTION).UpdateOne(
+		ctx,
+		bson.D{{Key: "login", Value: login}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "role", Value: entities.RoleAdmin}}}},
+	)
+	if err != nil {
+		panic(err)
+	}
 }
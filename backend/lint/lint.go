@@ -0,0 +1,251 @@
+// Package lint reports quality problems of a pack that do not make it invalid,
+// but are likely to spoil the game: duplicated questions, values that do not
+// grow within a category, answers giving themselves away and so on.
+//
+// Checks are run by the lint endpoint before a game and by cmd/packlint over
+// exported pack archives.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/i18n"
+)
+
+// Texts longer than these are hard to read aloud, the hard limits are enforced by validation
+const LONG_QUESTION_TEXT = 150
+const LONG_ANSWER = 30
+const LONG_COMMENT = 150
+const LONG_FINAL_COMMENT = 80
+
+type Severity string
+
+const (
+	// The game can not be played as intended
+	Error Severity = "error"
+	// The game can be played, but likely not the way the author wanted
+	Warning Severity = "warning"
+)
+
+type Check string
+
+const (
+	DuplicateQuestion  Check = "duplicateQuestion"
+	NonIncreasingValue Check = "nonIncreasingValue"
+	DuplicateIndex     Check = "duplicateIndex"
+	AnswerIsQuestion   Check = "answerIsQuestion"
+	UnreachableMedia   Check = "unreachableMedia"
+	EmptyFinalComment  Check = "emptyFinalComment"
+	LongText           Check = "longText"
+)
+
+type Issue struct {
+	Check    Check    `json:"check"`
+	Severity Severity `json:"severity"`
+	// Location of the problem in the pack, like rounds[0].categories[1].questions[2].text
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	// Error the check has run into, left out where it would tell too much, like the network of the server
+	Cause string `json:"cause,omitempty"`
+	// the message is the translation of the check formatted with them
+	args []any
+}
+
+func (i Issue) String() string {
+	if i.Cause != "" {
+		return fmt.Sprintf("%s: %s: %s: %s (%s)", i.Severity, i.Path, i.Message, i.Cause, i.Check)
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", i.Severity, i.Path, i.Message, i.Check)
+}
+
+type Report struct {
+	Issues []Issue `json:"issues"`
+}
+
+func (r *Report) add(check Check, severity Severity, path string, args ...any) {
+	r.Issues = append(r.Issues, Issue{
+		Check:    check,
+		Severity: severity,
+		Path:     path,
+		Message:  i18n.Translate(i18n.DEFAULT_LANGUAGE, string(check), args...),
+		args:     args,
+	})
+}
+
+// Messages are in the default language until the report is translated
+func (r *Report) Translate(lang i18n.Language) {
+	for i := range r.Issues {
+		r.Issues[i].Message = i18n.Translate(lang, string(r.Issues[i].Check), r.Issues[i].args...)
+	}
+}
+
+func (r *Report) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs every check that does not need network. Reachability of attachments
+// is checked separately and added by AddUnreachable
+func Lint(packDTO *entities.PackDTO) *Report {
+	report := &Report{Issues: make([]Issue, 0)}
+
+	// the first location of every question, keyed by its text and attachment
+	seen := make(map[string]string)
+	checkDuplicate := func(path string, text string, attachment *entities.Attachment) {
+		key := normalize(text)
+		if key == "" {
+			return
+		}
+		if attachment != nil {
+			key += "\x00" + attachment.ContentUrl
+		}
+		if firstPath, ok := seen[key]; ok {
+			report.add(DuplicateQuestion, Warning, path, firstPath)
+			return
+		}
+		seen[key] = path
+	}
+
+	for i, round := range packDTO.Rounds {
+		for j, category := range round.Categories {
+			categoryPath := fmt.Sprintf("rounds[%d].categories[%d]", i, j)
+			lintCategory(report, categoryPath, category)
+
+			for k, question := range category.Questions {
+				questionPath := fmt.Sprintf("%s.questions[%d]", categoryPath, k)
+				checkDuplicate(questionPath, question.Text, question.Attachment)
+				lintAnswers(report, questionPath, question.Text, question.Answers)
+				lintLength(report, questionPath+".text", question.Text, LONG_QUESTION_TEXT)
+				if question.Comment != nil {
+					lintLength(report, questionPath+".comment", *question.Comment, LONG_COMMENT)
+				}
+			}
+		}
+	}
+
+	for i, category := range packDTO.FinalRound.Categories {
+		questionPath := fmt.Sprintf("finalRound.categories[%d].question", i)
+		question := category.Question
+		checkDuplicate(questionPath, question.Text, question.Attachment)
+		lintAnswers(report, questionPath, question.Text, question.Answers)
+		lintLength(report, questionPath+".text", question.Text, LONG_QUESTION_TEXT)
+		if question.Comment == nil || strings.TrimSpace(*question.Comment) == "" {
+			report.add(EmptyFinalComment, Warning, questionPath+".comment")
+		} else {
+			lintLength(report, questionPath+".comment", *question.Comment, LONG_FINAL_COMMENT)
+		}
+	}
+
+	return report
+}
+
+func lintCategory(report *Report, categoryPath string, category entities.Category) {
+	byIndex := make(map[int]int)
+	for k, question := range category.Questions {
+		if first, ok := byIndex[question.Index]; ok {
+			report.add(
+				DuplicateIndex,
+				Error,
+				fmt.Sprintf("%s.questions[%d].index", categoryPath, k),
+				question.Index,
+				first,
+			)
+			continue
+		}
+		byIndex[question.Index] = k
+	}
+
+	// values are compared in the order questions are shown on the board
+	ordered := make([]int, len(category.Questions))
+	for k := range ordered {
+		ordered[k] = k
+	}
+	sort.SliceStable(ordered, func(a, b int) bool {
+		return category.Questions[ordered[a]].Index < category.Questions[ordered[b]].Index
+	})
+	for n := 1; n < len(ordered); n++ {
+		previous := category.Questions[ordered[n-1]]
+		current := category.Questions[ordered[n]]
+		if current.Value <= previous.Value {
+			report.add(
+				NonIncreasingValue,
+				Warning,
+				fmt.Sprintf("%s.questions[%d].value", categoryPath, ordered[n]),
+				current.Value,
+				previous.Value,
+			)
+		}
+	}
+}
+
+func lintAnswers(report *Report, questionPath string, text string, answers []string) {
+	for i, answer := range answers {
+		answerPath := fmt.Sprintf("%s.answers[%d]", questionPath, i)
+		if normalize(answer) != "" && normalize(answer) == normalize(text) {
+			report.add(AnswerIsQuestion, Warning, answerPath)
+		}
+		lintLength(report, answerPath, answer, LONG_ANSWER)
+	}
+}
+
+func lintLength(report *Report, path string, text string, limit int) {
+	if length := utf8.RuneCountInString(text); length > limit {
+		report.add(LongText, Warning, path, length, limit)
+	}
+}
+
+// Adds results of the reachability check, see media.CheckReachable.
+// Errors are kept as causes only withCauses, reports of the server leave them out
+func (r *Report) AddUnreachable(packDTO *entities.PackDTO, unreachable map[string]error, withCauses bool) {
+	for _, located := range locateAttachments(packDTO) {
+		if err, ok := unreachable[located.attachment.ContentUrl]; ok {
+			r.add(UnreachableMedia, Error, located.path, located.attachment.ContentUrl)
+			if withCauses {
+				r.Issues[len(r.Issues)-1].Cause = err.Error()
+			}
+		}
+	}
+}
+
+type locatedAttachment struct {
+	path       string
+	attachment *entities.Attachment
+}
+
+// Same order as PackDTO.Attachments, with the location of each one
+func locateAttachments(packDTO *entities.PackDTO) []locatedAttachment {
+	paths := make([]locatedAttachment, 0)
+	if packDTO.Cover != nil {
+		paths = append(paths, locatedAttachment{"cover", packDTO.Cover})
+	}
+	for i, round := range packDTO.Rounds {
+		for j, category := range round.Categories {
+			for k, question := range category.Questions {
+				if question.Attachment != nil {
+					path := fmt.Sprintf("rounds[%d].categories[%d].questions[%d].attachment", i, j, k)
+					paths = append(paths, locatedAttachment{path, question.Attachment})
+				}
+			}
+		}
+	}
+	for i, category := range packDTO.FinalRound.Categories {
+		if category.Question.Attachment != nil {
+			path := fmt.Sprintf("finalRound.categories[%d].question.attachment", i)
+			paths = append(paths, locatedAttachment{path, category.Question.Attachment})
+		}
+	}
+	return paths
+}
+
+// Case and spacing do not make questions different
+func normalize(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
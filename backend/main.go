@@ -4,13 +4,17 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/holdennekt/sgame/api"
+	"github.com/holdennekt/sgame/api/admin"
 	"github.com/holdennekt/sgame/api/rest"
 	wsLobby "github.com/holdennekt/sgame/api/ws/lobby"
 	wsRoom "github.com/holdennekt/sgame/api/ws/room"
+	"github.com/holdennekt/sgame/entities"
+	"github.com/holdennekt/sgame/media"
 	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -37,35 +41,169 @@ func main() {
 
 	mdb := conn.Database(getEnvVar("MONGO_DB_NAME"))
 	InitDB(context.TODO(), mdb)
+	if adminLogin := getEnvVarOrDefault("ADMIN_LOGIN", ""); adminLogin != "" {
+		PromoteAdmin(context.TODO(), mdb, adminLogin)
+	}
+
+	sessionTTL := getEnvVarDuration("SESSION_TTL", 30*24*time.Hour)
+	sessionOptions := api.SessionOptions{
+		TTL:      sessionTTL,
+		GuestTTL: getEnvVarDuration("GUEST_TTL", 24*time.Hour),
+		Cookie: api.CookieOptions{
+			MaxAge:   int(getEnvVarDuration("COOKIE_MAX_AGE", sessionTTL).Seconds()),
+			Domain:   getEnvVarOrDefault("COOKIE_DOMAIN", ""),
+			Secure:   getEnvVarBool("COOKIE_SECURE", false),
+			SameSite: getEnvVarSameSite("COOKIE_SAME_SITE", http.SameSiteLaxMode),
+		},
+	}
+
+	var blobStore media.BlobStore
+	switch getEnvVarOrDefault("MEDIA_STORE", "local") {
+	case "local":
+		blobStore, err = media.NewLocalStore(getEnvVarOrDefault("MEDIA_DIR", "./data/media"))
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "s3":
+		blobStore = media.NewS3Store(media.S3Config{
+			Endpoint:  getEnvVar("S3_ENDPOINT"),
+			Region:    getEnvVar("S3_REGION"),
+			Bucket:    getEnvVar("S3_BUCKET"),
+			AccessKey: getEnvVar("S3_ACCESS_KEY"),
+			SecretKey: getEnvVar("S3_SECRET_KEY"),
+		})
+	default:
+		log.Fatal("MEDIA_STORE env variable must be one of local, s3")
+	}
+	mediaService := media.NewService(mdb, blobStore, media.Options{
+		PublicUrl: getEnvVar("MEDIA_PUBLIC_URL"),
+		MaxSizes: map[entities.MediaType]int64{
+			entities.Image: getEnvVarInt64("MEDIA_MAX_IMAGE_SIZE", 10<<20),
+			entities.Audio: getEnvVarInt64("MEDIA_MAX_AUDIO_SIZE", 30<<20),
+			entities.Video: getEnvVarInt64("MEDIA_MAX_VIDEO_SIZE", 200<<20),
+		},
+		GracePeriod: getEnvVarDuration("MEDIA_GRACE_PERIOD", 24*time.Hour),
+	})
+	go mediaService.RunGarbageCollector(context.Background(), getEnvVarDuration("MEDIA_GC_INTERVAL", time.Hour))
 
 	engine := gin.Default()
 
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowOrigins = []string{getEnvVar("MY_CLIENT_ORIGIN")}
 	corsConfig.AllowCredentials = true
+	corsConfig.AllowHeaders = append(corsConfig.AllowHeaders, api.REQUEST_ID_HEADER)
+	corsConfig.ExposeHeaders = append(corsConfig.ExposeHeaders, api.REQUEST_ID_HEADER)
 	engine.Use(cors.New(corsConfig))
+	engine.Use(api.CorrelateRequest())
+
+	authorize := api.AuthorizeConnection(mdb, rds, sessionOptions)
+	registered := api.RequireRegistered()
+	session := api.RequireSession()
+
+	redirectProviders := make([]api.RedirectAuthProvider, 0)
+	if issuer := getEnvVarOrDefault("OIDC_ISSUER", ""); issuer != "" {
+		oidcProvider, err := api.NewOIDCProvider(context.TODO(), api.OIDCConfig{
+			Name:         getEnvVarOrDefault("OIDC_NAME", "oidc"),
+			Issuer:       issuer,
+			ClientId:     getEnvVar("OIDC_CLIENT_ID"),
+			ClientSecret: getEnvVar("OIDC_CLIENT_SECRET"),
+			RedirectUrl:  getEnvVar("OIDC_REDIRECT_URL"),
+			Cookie:       sessionOptions.Cookie,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		redirectProviders = append(redirectProviders, oidcProvider)
+	}
+	authProviders := api.NewAuthProviders(redirectProviders...)
+	isPasswordEnabled := getEnvVarBool("PASSWORD_AUTH_ENABLED", true)
+
+	engine.Handle(http.MethodGet, "/auth/providers", api.GetAuthProvidersHandler(authProviders, isPasswordEnabled))
+	engine.Handle(http.MethodGet, "/auth/:provider", api.BeginAuthHandler(authProviders))
+	engine.Handle(http.MethodGet, "/auth/:provider/callback", api.AuthCallbackHandler(mdb, rds, sessionOptions, authProviders, getEnvVar("MY_CLIENT_ORIGIN")))
+
+	if isPasswordEnabled {
+		engine.Handle(http.MethodPost, "/login", api.LoginHandler(rds, sessionOptions, api.NewPasswordProvider(mdb)))
+		engine.Handle(http.MethodPost, "/register", api.RegisterHandler(mdb, rds, sessionOptions))
+	}
+	engine.Handle(http.MethodPost, "/guest", api.GuestLoginHandler(mdb, rds, sessionOptions))
+	engine.Handle(http.MethodPost, "/logout", authorize, session, api.LogoutHandler(rds, sessionOptions))
+	engine.Handle(http.MethodPost, "/logout-all", authorize, session, api.LogoutAllHandler(rds, sessionOptions))
+	engine.Handle(http.MethodGet, "/sessions", authorize, session, api.GetSessionsHandler(rds))
+	engine.Handle(http.MethodGet, "/user", authorize, api.RequireScope(entities.UserRead), api.GetUser(mdb))
+	engine.Handle(http.MethodGet, "/media/:id", rest.ServeMediaHandler(mdb, mediaService))
+
+	restGroup := engine.Group("/rest", authorize)
+
+	userGroup := restGroup.Group("/user", session)
+	userGroup.Handle(http.MethodPost, "/upgrade", rest.UpgradeGuestHandler(mdb, rds, sessionOptions))
+	userGroup.Handle(http.MethodPut, "", registered, rest.UpdateProfileHandler(mdb, rds))
+	userGroup.Handle(http.MethodGet, "/language", api.GetLanguageHandler(mdb, rds))
+	userGroup.Handle(http.MethodPut, "/language", api.SetLanguageHandler(mdb, rds))
+	userGroup.Handle(http.MethodPut, "/password", registered, rest.ChangePasswordHandler(mdb, rds))
+	userGroup.Handle(http.MethodGet, "/identities", registered, api.GetIdentitiesHandler(mdb))
+	userGroup.Handle(http.MethodDelete, "/identities/:provider", registered, api.UnlinkIdentityHandler(mdb))
+	userGroup.Handle(http.MethodDelete, "", registered, rest.DeleteUserHandler(mdb, rds, mediaService, sessionOptions))
 
-	engine.Handle(http.MethodPost, "/login", api.LoginHandler(mdb, rds))
-	engine.Handle(http.MethodPost, "/register", api.RegisterHandler(mdb, rds))
-	engine.Handle(http.MethodGet, "/user", api.AuthorizeConnection(rds), api.GetUser(mdb))
+	tokensGroup := restGroup.Group("/tokens", session, registered)
+	tokensGroup.Handle(http.MethodPost, "", api.CreateAccessTokenHandler(mdb))
+	tokensGroup.Handle(http.MethodGet, "", api.GetAccessTokensHandler(mdb))
+	tokensGroup.Handle(http.MethodDelete, "/:id", api.RevokeAccessTokenHandler(mdb))
 
-	restGroup := engine.Group("/rest", api.AuthorizeConnection(rds))
+	restGroup.Handle(http.MethodPost, "/room", registered, api.RequireScope(entities.RoomsCreate), rest.CreateRoomHandler(mdb, rds))
+	restGroup.Handle(http.MethodGet, "/rooms", api.RequireScope(entities.RoomsRead), rest.GetRoomsHandler(rds))
+	restGroup.Handle(http.MethodGet, "/room/:id", api.RequireScope(entities.RoomsRead), rest.GetRoomHandler(rds))
+	restGroup.Handle(http.MethodPatch, "/room/:id", api.RequireScope(entities.RoomsJoin), rest.EnterRoomHandler(mdb, rds))
 
-	restGroup.Handle(http.MethodPost, "/room", rest.CreateRoomHandler(mdb, rds))
-	restGroup.Handle(http.MethodGet, "/rooms", rest.GetRoomsHandler(rds))
-	restGroup.Handle(http.MethodGet, "/room/:id", rest.GetRoomHandler(rds))
-	restGroup.Handle(http.MethodPatch, "/room/:id", rest.EnterRoomHandler(mdb, rds))
+	packsRead := api.RequireScope(entities.PacksRead)
+	packsWrite := api.RequireScope(entities.PacksWrite)
+	packGroup := restGroup.Group("", registered)
+	packGroup.Handle(http.MethodPost, "/pack", packsWrite, rest.CreatePackHandler(mdb, mediaService))
+	packGroup.Handle(http.MethodPost, "/pack/import", packsWrite, rest.ImportPackHandler(mdb, mediaService))
+	packGroup.Handle(http.MethodPost, "/pack/import/siq", packsWrite, rest.ImportSiqHandler(mdb, mediaService))
+	packGroup.Handle(http.MethodPost, "/pack/import/spreadsheet", packsWrite, rest.ImportSpreadsheetHandler(mdb, mediaService))
+	packGroup.Handle(http.MethodGet, "/packsPreview", packsRead, rest.GetPacksPreviewHandler(mdb))
+	packGroup.Handle(http.MethodGet, "/packs", packsRead, rest.GetHiddenPacksHandler(mdb))
+	packGroup.Handle(http.MethodGet, "/pack/:id", packsRead, rest.GetPackHandler(mdb))
+	packGroup.Handle(http.MethodGet, "/pack/:id/export", packsRead, rest.ExportPackHandler(mdb, mediaService))
+	packGroup.Handle(http.MethodGet, "/pack/:id/lint", packsRead, rest.LintPackHandler(mdb, mediaService))
+	packGroup.Handle(http.MethodGet, "/pack/:id/versions", packsRead, rest.GetPackVersionsHandler(mdb))
+	packGroup.Handle(http.MethodGet, "/pack/:id/versions/:version", packsRead, rest.GetPackVersionHandler(mdb))
+	packGroup.Handle(http.MethodPost, "/pack/:id/versions/:version/restore", packsWrite, rest.RestorePackVersionHandler(mdb, mediaService))
+	packGroup.Handle(http.MethodGet, "/pack/:id/diff", packsRead, rest.DiffPackVersionsHandler(mdb))
+	packGroup.Handle(http.MethodPut, "/pack/:id", packsWrite, rest.UpdatePackHandler(mdb, mediaService))
+	packGroup.Handle(http.MethodDelete, "/pack/:id", packsWrite, rest.DeletePackHandler(mdb, mediaService))
+	packGroup.Handle(http.MethodPost, "/pack/:id/publish", packsWrite, rest.PublishPackHandler(mdb, mediaService))
+	packGroup.Handle(http.MethodPost, "/pack/:id/fork", packsWrite, rest.ForkPackHandler(mdb, mediaService))
+	packGroup.Handle(http.MethodGet, "/pack/:id/lineage", packsRead, rest.GetPackLineageHandler(mdb))
+	packGroup.Handle(http.MethodPost, "/pack/:id/collaborators", packsWrite, rest.InviteCollaboratorHandler(mdb))
+	packGroup.Handle(http.MethodDelete, "/pack/:id/collaborators/:userId", packsWrite, rest.RemoveCollaboratorHandler(mdb))
+	packGroup.Handle(http.MethodPut, "/pack/:id/rating", packsWrite, rest.RatePackHandler(mdb))
+	packGroup.Handle(http.MethodDelete, "/pack/:id/rating", packsWrite, rest.DeletePackRatingHandler(mdb))
+	packGroup.Handle(http.MethodGet, "/pack/:id/reviews", packsRead, rest.GetPackReviewsHandler(mdb))
+	packGroup.Handle(http.MethodPost, "/pack/:id/report", packsWrite, rest.ReportPackHandler(mdb))
+	packGroup.Handle(http.MethodPost, "/media", packsWrite, rest.UploadMediaHandler(mediaService))
+	packGroup.Handle(http.MethodGet, "/bank/questions", packsRead, rest.GetBankQuestionsHandler(mdb))
+	packGroup.Handle(http.MethodPost, "/bank/questions", packsWrite, rest.CreateBankQuestionHandler(mdb, mediaService))
+	packGroup.Handle(http.MethodGet, "/bank/questions/:id", packsRead, rest.GetBankQuestionHandler(mdb))
+	packGroup.Handle(http.MethodPut, "/bank/questions/:id", packsWrite, rest.UpdateBankQuestionHandler(mdb, mediaService))
+	packGroup.Handle(http.MethodDelete, "/bank/questions/:id", packsWrite, rest.DeleteBankQuestionHandler(mdb, mediaService))
 
-	restGroup.Handle(http.MethodPost, "/pack", rest.CreatePackHandler(mdb))
-	restGroup.Handle(http.MethodGet, "/packsPreview", rest.GetPacksPreviewHandler(mdb))
-	restGroup.Handle(http.MethodGet, "/packs", rest.GetHiddenPacksHandler(mdb))
-	restGroup.Handle(http.MethodGet, "/pack/:id", rest.GetPackHandler(mdb))
-	restGroup.Handle(http.MethodPut, "/pack/:id", rest.UpdatePackHandler(mdb))
-	restGroup.Handle(http.MethodDelete, "/pack/:id", rest.DeletePackHandler(mdb))
+	adminGroup := engine.Group("/admin", authorize, session, api.RequireRole(mdb, entities.RoleModerator))
+	adminGroup.Handle(http.MethodGet, "/rooms", admin.GetRoomsHandler(rds))
+	adminGroup.Handle(http.MethodDelete, "/rooms/:id", admin.CloseRoomHandler(rds))
+	adminGroup.Handle(http.MethodGet, "/packs/:id", admin.GetPackHandler(mdb))
+	adminGroup.Handle(http.MethodPut, "/packs/:id/visibility", admin.SetPackVisibilityHandler(mdb))
+	adminGroup.Handle(http.MethodGet, "/reports", admin.GetReportsHandler(mdb))
+	adminGroup.Handle(http.MethodPost, "/reports/:id/resolve", admin.ResolveReportHandler(mdb, mediaService))
+	adminGroup.Handle(http.MethodGet, "/users", admin.GetUsersHandler(mdb))
+	adminGroup.Handle(http.MethodPut, "/users/:id/suspension", admin.SuspendUserHandler(mdb, rds))
+	adminGroup.Handle(http.MethodDelete, "/users/:id/suspension", admin.UnsuspendUserHandler(mdb))
+	adminGroup.Handle(http.MethodPut, "/users/:id/role", api.RequireRole(mdb, entities.RoleAdmin), admin.SetRoleHandler(mdb))
 
-	wsGroup := engine.Group("/ws", api.AuthorizeConnection(rds))
-	wsGroup.Handle(http.MethodGet, "/lobby", wsLobby.ConnectHandler(mdb, rds))
-	wsGroup.Handle(http.MethodGet, "/room/:id", wsRoom.ConnectHandler(mdb, rds))
+	wsGroup := engine.Group("/ws", authorize)
+	wsGroup.Handle(http.MethodGet, "/lobby", api.RequireScope(entities.RoomsRead), wsLobby.ConnectHandler(mdb, rds))
+	wsGroup.Handle(http.MethodGet, "/room/:id", api.RequireScope(entities.RoomsJoin), wsRoom.ConnectHandler(mdb, rds))
 
 	servAddres := getEnvVar("HOST") + ":" + getEnvVar("PORT")
 	log.Fatal(engine.Run(servAddres))
@@ -0,0 +1,79 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// Storage of media content. Blobs are opened as seekers, so that they can be served with range requests
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.ReadSeeker, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(root string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{root: root}, nil
+}
+
+// Blobs are spread over subdirectories by the first characters of the key
+func (ls *LocalStore) path(key string) string {
+	if len(key) < 2 {
+		return filepath.Join(ls.root, key)
+	}
+	return filepath.Join(ls.root, key[:2], key)
+}
+
+func (ls *LocalStore) Put(ctx context.Context, key string, r io.ReadSeeker, size int64, contentType string) error {
+	blobPath := ls.path(key)
+	if err := os.MkdirAll(filepath.Dir(blobPath), 0o755); err != nil {
+		return err
+	}
+
+	// written under temporary name, so that a half written blob is never served
+	file, err := os.CreateTemp(filepath.Dir(blobPath), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), blobPath)
+}
+
+func (ls *LocalStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	file, err := os.Open(ls.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrBlobNotFound
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+func (ls *LocalStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(ls.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
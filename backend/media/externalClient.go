@@ -0,0 +1,65 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var ErrForbiddenAddress = errors.New("address is not public")
+var ErrForbiddenScheme = errors.New("only http and https urls are allowed")
+
+// Shared by carrier grade NATs, not covered by netip.Addr.IsPrivate
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+type externalTransport struct {
+	base http.RoundTripper
+}
+
+// Every redirect passes through here too
+func (et externalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, ErrForbiddenScheme
+	}
+	return et.base.RoundTrip(req)
+}
+
+// Client for urls given by users. It connects only to public addresses,
+// so that users can not make the server request its own network or cloud metadata
+func NewExternalClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		// the address is checked after resolving, so a name can not be rebound to an internal one
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: externalTransport{base: &http.Transport{
+			// no proxy, it would connect on behalf of the client and bypass the check
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+		}},
+	}
+}
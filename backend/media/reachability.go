@@ -0,0 +1,106 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/holdennekt/sgame/entities"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const REACHABILITY_WORKERS = 8
+
+var ErrUnreachable = errors.New("media is unreachable")
+
+// Requests every url concurrently, returns errors of the ones that did not respond with success
+func CheckReachable(ctx context.Context, client *http.Client, urls []string) map[string]error {
+	unreachable := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	queue := make(chan string)
+	for i := 0; i < REACHABILITY_WORKERS; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for url := range queue {
+				if err := checkUrl(ctx, client, url); err != nil {
+					mu.Lock()
+					unreachable[url] = err
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	seen := make(map[string]bool)
+	for _, url := range urls {
+		if !seen[url] {
+			seen[url] = true
+			queue <- url
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	return unreachable
+}
+
+func checkUrl(ctx context.Context, client *http.Client, url string) error {
+	status, err := request(ctx, client, http.MethodHead, url)
+	// some servers do not support HEAD, the first byte is enough to tell
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = request(ctx, client, http.MethodGet, url)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+	if status < 200 || status > 299 {
+		return fmt.Errorf("%w: responded with %d", ErrUnreachable, status)
+	}
+	return nil
+}
+
+func request(ctx context.Context, client *http.Client, method string, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	return res.StatusCode, nil
+}
+
+// Checks attachments of the pack. Hosted media is looked up in the database,
+// external urls are requested
+func (s *Service) CheckAttachments(ctx context.Context, client *http.Client, packDTO *entities.PackDTO) (map[string]error, error) {
+	unreachable := make(map[string]error)
+	external := make([]string, 0)
+	for _, attachment := range packDTO.Attachments() {
+		id, ok := s.IdFromUrl(attachment.ContentUrl)
+		if !ok {
+			external = append(external, attachment.ContentUrl)
+			continue
+		}
+		count, err := s.mdb.Collection(entities.MEDIA_COLLECTION).CountDocuments(ctx, bson.M{"_id": id})
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			unreachable[attachment.ContentUrl] = fmt.Errorf("%w: hosted media does not exist", ErrUnreachable)
+		}
+	}
+
+	for url, err := range CheckReachable(ctx, client, external) {
+		unreachable[url] = err
+	}
+	return unreachable, nil
+}
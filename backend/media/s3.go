@@ -0,0 +1,233 @@
+package media
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const S3_SERVICE = "s3"
+const S3_ALGORITHM = "AWS4-HMAC-SHA256"
+const S3_SIGNED_HEADERS = "host;x-amz-content-sha256;x-amz-date"
+
+type S3Config struct {
+	// e.g. "https://s3.eu-central-1.amazonaws.com" or "http://minio:9000"
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// Store for any S3-compatible service. Requests use path-style addressing
+// and are signed with AWS Signature Version 4
+type S3Store struct {
+	config     S3Config
+	httpClient *http.Client
+}
+
+func NewS3Store(config S3Config) *S3Store {
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	return &S3Store{config: config, httpClient: &http.Client{Timeout: 5 * time.Minute}}
+}
+
+func (s3 *S3Store) objectUrl(key string) string {
+	return s3.config.Endpoint + "/" + s3.config.Bucket + "/" + key
+}
+
+func (s3 *S3Store) Put(ctx context.Context, key string, r io.ReadSeeker, size int64, contentType string) error {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s3.objectUrl(key), io.NopCloser(r))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	s3.sign(req, hex.EncodeToString(hasher.Sum(nil)))
+
+	resp, err := s3.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s3 *S3Store) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s3.objectUrl(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	s3.sign(req, emptyPayloadHash)
+
+	resp, err := s3.do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return &s3Blob{ctx: ctx, store: s3, key: key, size: resp.ContentLength}, nil
+}
+
+func (s3 *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s3.objectUrl(key), nil)
+	if err != nil {
+		return err
+	}
+	s3.sign(req, emptyPayloadHash)
+
+	resp, err := s3.do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s3 *S3Store) do(req *http.Request) (*http.Response, error) {
+	resp, err := s3.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrBlobNotFound
+	}
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s responded with status %d: %s", req.Method, req.URL.Path, resp.StatusCode, body)
+	}
+	return resp, nil
+}
+
+var emptyPayloadHash = hex.EncodeToString(sha256.New().Sum(nil))
+
+func (s3 *S3Store) sign(req *http.Request, payloadHash string) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		S3_SIGNED_HEADERS,
+		payloadHash,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := strings.Join([]string{date, s3.config.Region, S3_SERVICE, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		S3_ALGORITHM,
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s3.config.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, s3.config.Region)
+	signingKey = hmacSHA256(signingKey, S3_SERVICE)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		S3_ALGORITHM, s3.config.AccessKey, scope, S3_SIGNED_HEADERS, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Reads the object lazily with range requests starting from the current offset
+type s3Blob struct {
+	ctx    context.Context
+	store  *S3Store
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (b *s3Blob) Read(p []byte) (int, error) {
+	if b.offset >= b.size {
+		return 0, io.EOF
+	}
+	if b.body == nil {
+		req, err := http.NewRequestWithContext(b.ctx, http.MethodGet, b.store.objectUrl(b.key), nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", b.offset))
+		b.store.sign(req, emptyPayloadHash)
+
+		resp, err := b.store.do(req)
+		if err != nil {
+			return 0, err
+		}
+		b.body = resp.Body
+	}
+
+	n, err := b.body.Read(p)
+	b.offset += int64(n)
+	return n, err
+}
+
+func (b *s3Blob) Seek(offset int64, whence int) (int64, error) {
+	var newOffset int64
+	switch whence {
+	case io.SeekStart:
+		newOffset = offset
+	case io.SeekCurrent:
+		newOffset = b.offset + offset
+	case io.SeekEnd:
+		newOffset = b.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if newOffset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if newOffset != b.offset && b.body != nil {
+		b.body.Close()
+		b.body = nil
+	}
+	b.offset = newOffset
+	return newOffset, nil
+}
+
+func (b *s3Blob) Close() error {
+	if b.body == nil {
+		return nil
+	}
+	return b.body.Close()
+}
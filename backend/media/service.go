@@ -0,0 +1,313 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/holdennekt/sgame/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const SNIFF_LENGTH = 512
+
+var ErrUnsupportedMedia = errors.New("unsupported media")
+var ErrMediaTooLarge = errors.New("media is too large")
+
+// Containers that may hold either audio or video, for them
+// the media type declared by the uploader is trusted
+var ambiguousContentTypes = map[string]bool{
+	"application/ogg": true,
+	"video/webm":      true,
+	"video/mp4":       true,
+}
+
+type Options struct {
+	// Hosted media is served at PublicUrl + id
+	PublicUrl   string
+	MaxSizes    map[entities.MediaType]int64
+	GracePeriod time.Duration
+}
+
+func (o Options) maxSize() int64 {
+	var maxSize int64
+	for _, size := range o.MaxSizes {
+		if size > maxSize {
+			maxSize = size
+		}
+	}
+	return maxSize
+}
+
+type Service struct {
+	mdb     *mongo.Database
+	blobs   BlobStore
+	options Options
+}
+
+func NewService(mdb *mongo.Database, blobs BlobStore, options Options) *Service {
+	if !strings.HasSuffix(options.PublicUrl, "/") {
+		options.PublicUrl += "/"
+	}
+	return &Service{mdb: mdb, blobs: blobs, options: options}
+}
+
+// Size of the largest media of any type
+func (s *Service) MaxSize() int64 {
+	return s.options.maxSize()
+}
+
+func (s *Service) Url(id primitive.ObjectID) string {
+	return s.options.PublicUrl + id.Hex()
+}
+
+// Id of hosted media the url points to, false for external urls
+func (s *Service) IdFromUrl(contentUrl string) (primitive.ObjectID, bool) {
+	hex, found := strings.CutPrefix(contentUrl, s.options.PublicUrl)
+	if !found {
+		return primitive.NilObjectID, false
+	}
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+// Stores uploaded content. Media type is detected by sniffing the content,
+// declaredType is only used to tell audio from video in containers that may hold both
+func (s *Service) Upload(ctx context.Context, ownerId primitive.ObjectID, r io.Reader, declaredType entities.MediaType) (*entities.Media, error) {
+	// buffered on disk, because the size is known only after reading it all
+	file, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	maxSize := s.options.maxSize()
+	size, err := io.Copy(file, io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > maxSize {
+		return nil, fmt.Errorf("%w: must be at most %d bytes", ErrMediaTooLarge, maxSize)
+	}
+
+	head := make([]byte, SNIFF_LENGTH)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	contentType, mediaType, err := sniff(head[:n], declaredType)
+	if err != nil {
+		return nil, err
+	}
+	if size > s.options.MaxSizes[mediaType] {
+		return nil, fmt.Errorf("%w: %s must be at most %d bytes", ErrMediaTooLarge, mediaType, s.options.MaxSizes[mediaType])
+	}
+
+	now := time.Now()
+	media := &entities.Media{
+		Id:             primitive.NewObjectID(),
+		OwnerId:        ownerId,
+		MediaType:      mediaType,
+		ContentType:    contentType,
+		Size:           size,
+		CreatedAt:      now,
+		UnreferencedAt: &now,
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := s.blobs.Put(ctx, media.Id.Hex(), file, size, contentType); err != nil {
+		return nil, err
+	}
+	if _, err := s.mdb.Collection(entities.MEDIA_COLLECTION).InsertOne(ctx, media); err != nil {
+		s.blobs.Delete(ctx, media.Id.Hex())
+		return nil, err
+	}
+
+	media.Url = s.Url(media.Id)
+	return media, nil
+}
+
+func sniff(head []byte, declaredType entities.MediaType) (string, entities.MediaType, error) {
+	contentType := http.DetectContentType(head)
+	mediaType := entities.MediaType(strings.SplitN(contentType, "/", 2)[0])
+
+	if ambiguousContentTypes[contentType] && (declaredType == entities.Audio || declaredType == entities.Video) {
+		return contentType, declaredType, nil
+	}
+	if contentType == "application/ogg" {
+		return contentType, entities.Audio, nil
+	}
+
+	switch mediaType {
+	case entities.Image, entities.Audio, entities.Video:
+	default:
+		return "", "", fmt.Errorf("%w: %s", ErrUnsupportedMedia, contentType)
+	}
+	if declaredType != "" && declaredType != mediaType {
+		return "", "", fmt.Errorf("%w: content is %s, not %s", ErrUnsupportedMedia, contentType, declaredType)
+	}
+	return contentType, mediaType, nil
+}
+
+func (s *Service) Open(ctx context.Context, id primitive.ObjectID) (io.ReadSeekCloser, error) {
+	return s.blobs.Open(ctx, id.Hex())
+}
+
+// Content and content type of hosted media, read from the store and not requested by its url
+func (s *Service) Read(ctx context.Context, id primitive.ObjectID) ([]byte, string, error) {
+	m, httpErr := entities.GetMedia(s.mdb, id)
+	if httpErr != nil {
+		return nil, "", httpErr
+	}
+	blob, err := s.Open(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	defer blob.Close()
+
+	content, err := io.ReadAll(blob)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, m.ContentType, nil
+}
+
+func (s *Service) refs(attachments []*entities.Attachment) map[primitive.ObjectID]bool {
+	refs := make(map[primitive.ObjectID]bool)
+	for _, attachment := range attachments {
+		if attachment == nil {
+			continue
+		}
+		if id, ok := s.IdFromUrl(attachment.ContentUrl); ok {
+			refs[id] = true
+		}
+	}
+	return refs
+}
+
+func packAttachments(packDTO *entities.PackDTO) []*entities.Attachment {
+	if packDTO == nil {
+		return nil
+	}
+	return packDTO.Attachments()
+}
+
+// Moves references from media used by the old pack to media used by the new one.
+// A pack references media once no matter how many attachments use it.
+// Either of packs may be nil when the pack is created or deleted
+func (s *Service) UpdateRefs(ctx context.Context, oldPackDTO *entities.PackDTO, newPackDTO *entities.PackDTO) error {
+	return s.UpdateAttachmentRefs(ctx, packAttachments(oldPackDTO), packAttachments(newPackDTO))
+}
+
+// Same as UpdateRefs for anything else holding attachments, like bank questions
+func (s *Service) UpdateAttachmentRefs(ctx context.Context, oldAttachments []*entities.Attachment, newAttachments []*entities.Attachment) error {
+	oldRefs := s.refs(oldAttachments)
+	newRefs := s.refs(newAttachments)
+
+	added := make([]primitive.ObjectID, 0)
+	for id := range newRefs {
+		if !oldRefs[id] {
+			added = append(added, id)
+		}
+	}
+	removed := make([]primitive.ObjectID, 0)
+	for id := range oldRefs {
+		if !newRefs[id] {
+			removed = append(removed, id)
+		}
+	}
+
+	collection := s.mdb.Collection(entities.MEDIA_COLLECTION)
+	if len(added) > 0 {
+		_, err := collection.UpdateMany(
+			ctx,
+			bson.M{"_id": bson.M{"$in": added}},
+			bson.M{"$inc": bson.M{"refCount": 1}, "$unset": bson.M{"unreferencedAt": ""}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if len(removed) > 0 {
+		_, err := collection.UpdateMany(
+			ctx,
+			bson.M{"_id": bson.M{"$in": removed}},
+			bson.M{"$inc": bson.M{"refCount": -1}},
+		)
+		if err != nil {
+			return err
+		}
+		_, err = collection.UpdateMany(
+			ctx,
+			bson.M{"_id": bson.M{"$in": removed}, "refCount": bson.M{"$lte": 0}},
+			bson.M{"$set": bson.M{"unreferencedAt": time.Now()}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deletes media no pack has used for longer than the grace period.
+// The grace period lets freshly uploaded media be attached to a pack
+func (s *Service) CollectGarbage(ctx context.Context) error {
+	collection := s.mdb.Collection(entities.MEDIA_COLLECTION)
+	filter := bson.M{
+		"refCount":       bson.M{"$lte": 0},
+		"unreferencedAt": bson.M{"$lt": time.Now().Add(-s.options.GracePeriod)},
+	}
+
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	unreferenced := make([]entities.Media, 0)
+	if err := cursor.All(ctx, &unreferenced); err != nil {
+		return err
+	}
+
+	for _, media := range unreferenced {
+		// the filter is repeated in case the media got referenced meanwhile
+		res, err := collection.DeleteOne(ctx, bson.M{"_id": media.Id, "refCount": bson.M{"$lte": 0}})
+		if err != nil {
+			return err
+		}
+		if res.DeletedCount == 0 {
+			continue
+		}
+		if err := s.blobs.Delete(ctx, media.Id.Hex()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) RunGarbageCollector(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.CollectGarbage(ctx); err != nil {
+				log.Println("media garbage collection failed:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package siq
+
+import "encoding/xml"
+
+// content.xml of SIGame package. Versions 4 and 5 are supported: version 4
+// keeps question content in scenario atoms, version 5 in params items
+
+type xmlPackage struct {
+	XMLName xml.Name   `xml:"package"`
+	Name    string     `xml:"name,attr"`
+	Version string     `xml:"version,attr"`
+	Rounds  []xmlRound `xml:"rounds>round"`
+}
+
+type xmlRound struct {
+	Name   string     `xml:"name,attr"`
+	Type   string     `xml:"type,attr"`
+	Themes []xmlTheme `xml:"themes>theme"`
+}
+
+type xmlTheme struct {
+	Name      string        `xml:"name,attr"`
+	Questions []xmlQuestion `xml:"questions>question"`
+}
+
+type xmlQuestion struct {
+	Price int `xml:"price,attr"`
+	// version 5 question type
+	Type string `xml:"type,attr"`
+	// version 4 question type
+	OldType  *xmlType   `xml:"type"`
+	Scenario []xmlAtom  `xml:"scenario>atom"`
+	Params   []xmlParam `xml:"params>param"`
+	Right    []string   `xml:"right>answer"`
+	Info     *xmlInfo   `xml:"info"`
+}
+
+type xmlType struct {
+	Name string `xml:"name,attr"`
+}
+
+type xmlAtom struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlParam struct {
+	Name  string    `xml:"name,attr"`
+	Type  string    `xml:"type,attr"`
+	Items []xmlItem `xml:"item"`
+}
+
+type xmlItem struct {
+	Type  string `xml:"type,attr"`
+	IsRef string `xml:"isRef,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlInfo struct {
+	Comments string `xml:"comments"`
+}
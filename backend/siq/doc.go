@@ -0,0 +1,15 @@
+// Package siq imports packages of SIGame (.siq files) as packs.
+//
+// A .siq file is a zip with content.xml describing the package and media
+// bundled in Images/, Audio/ and Video/ folders. Rounds, themes, questions,
+// right answers and final round themes map directly. Everything this game
+// has no counterpart for is adapted and reported as a warning:
+//
+//   - special question types (cat in bag, auction, sponsored, stake and so on)
+//     are imported as regular questions
+//   - only the first media of a question becomes its attachment
+//   - content shown after the answer becomes the question comment
+//   - texts longer than the pack allows are truncated
+//   - themes of a round are cut to the same number of questions
+//   - only the first question of every final round theme is imported
+package siq
@@ -0,0 +1,500 @@
+package siq
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/holdennekt/sgame/entities"
+)
+
+const CONTENT_FILE = "content.xml"
+const FINAL_ROUND_TYPE = "final"
+const MARKER_ATOM = "marker"
+
+const MAX_CONTENT_SIZE = 20 << 20
+const MAX_MEDIA_SIZE = 100 << 20
+
+var ErrInvalidPackage = errors.New("invalid SIGame package")
+
+// MediaStore wraps its error with ErrMediaRejected when the media itself
+// is not acceptable, such media is dropped with a warning instead of failing the import
+var ErrMediaRejected = errors.New("media rejected")
+
+var mediaTypes = map[string]entities.MediaType{
+	"image": entities.Image,
+	"voice": entities.Audio,
+	"audio": entities.Audio,
+	"video": entities.Video,
+}
+
+// Bundled media is kept in a folder per media type
+var mediaDirs = map[entities.MediaType]string{
+	entities.Image: "Images/",
+	entities.Audio: "Audio/",
+	entities.Video: "Video/",
+}
+
+// Persists bundled media file and returns the url it is reachable by
+type MediaStore func(name string, mediaType entities.MediaType, r io.Reader) (string, error)
+
+type Result struct {
+	Pack entities.PackDTO
+	// Everything that was changed or dropped because it has no counterpart in this game
+	Warnings []string
+}
+
+type mediaRef struct {
+	mediaType entities.MediaType
+	value     string
+	isRef     bool
+}
+
+type questionContent struct {
+	text    []string
+	comment []string
+	media   []mediaRef
+}
+
+type importer struct {
+	files    map[string]*zip.File
+	store    MediaStore
+	stored   map[string]string
+	warnings []string
+}
+
+func (im *importer) warn(format string, args ...any) {
+	im.warnings = append(im.warnings, fmt.Sprintf(format, args...))
+}
+
+// Imports .siq file. Without store bundled media is dropped with a warning
+func Import(r io.ReaderAt, size int64, store MediaStore) (*Result, error) {
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+	}
+
+	im := &importer{
+		files:  make(map[string]*zip.File, len(zipReader.File)),
+		store:  store,
+		stored: make(map[string]string),
+	}
+	// older packages keep media file names url escaped
+	for _, file := range zipReader.File {
+		im.files[file.Name] = file
+		if unescaped, err := url.PathUnescape(file.Name); err == nil {
+			if _, ok := im.files[unescaped]; !ok {
+				im.files[unescaped] = file
+			}
+		}
+	}
+
+	contentFile, ok := im.files[CONTENT_FILE]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidPackage, CONTENT_FILE)
+	}
+	if contentFile.UncompressedSize64 > MAX_CONTENT_SIZE {
+		return nil, fmt.Errorf("%w: %s is too large", ErrInvalidPackage, CONTENT_FILE)
+	}
+	contentReader, err := contentFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+	}
+	defer contentReader.Close()
+
+	var pkg xmlPackage
+	if err := xml.NewDecoder(io.LimitReader(contentReader, MAX_CONTENT_SIZE)).Decode(&pkg); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidPackage, CONTENT_FILE, err)
+	}
+
+	packDTO, err := im.importPackage(pkg)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Pack: packDTO, Warnings: im.warnings}, nil
+}
+
+func (im *importer) importPackage(pkg xmlPackage) (entities.PackDTO, error) {
+	packDTO := entities.PackDTO{
+		Name:   im.truncate(strings.TrimSpace(pkg.Name), 50, "package name"),
+		Type:   entities.Private,
+		Rounds: make([]entities.Round, 0),
+		FinalRound: entities.FinalRound{
+			Categories: make([]entities.FinalCategory, 0),
+		},
+	}
+
+	roundNames := make(map[string]bool)
+	for i, xmlRound := range pkg.Rounds {
+		if xmlRound.Type == FINAL_ROUND_TYPE {
+			if err := im.importFinalRound(&packDTO.FinalRound, xmlRound); err != nil {
+				return packDTO, err
+			}
+			continue
+		}
+
+		if len(packDTO.Rounds) == 10 {
+			im.warn("round \"%s\" skipped, pack can have at most 10 rounds", xmlRound.Name)
+			continue
+		}
+		round, ok, err := im.importRound(xmlRound, i)
+		if err != nil {
+			return packDTO, err
+		}
+		if !ok {
+			continue
+		}
+		round.Name = im.unique(round.Name, roundNames, 50, "round")
+		packDTO.Rounds = append(packDTO.Rounds, round)
+	}
+
+	if len(packDTO.FinalRound.Categories) == 0 {
+		im.warn("package has no final round, it must be added before the pack can be saved")
+	}
+	return packDTO, nil
+}
+
+func (im *importer) importRound(xmlRound xmlRound, i int) (entities.Round, bool, error) {
+	round := entities.Round{
+		Name:       strings.TrimSpace(xmlRound.Name),
+		Categories: make([]entities.Category, 0),
+	}
+	if round.Name == "" {
+		round.Name = "Round " + strconv.Itoa(i+1)
+	}
+
+	categoryNames := make(map[string]bool)
+	for _, theme := range xmlRound.Themes {
+		where := fmt.Sprintf("round \"%s\", theme \"%s\"", round.Name, theme.Name)
+		if len(round.Categories) == 10 {
+			im.warn("%s skipped, round can have at most 10 themes", where)
+			continue
+		}
+
+		category := entities.Category{Questions: make([]entities.Question, 0)}
+		for j, xmlQuestion := range theme.Questions {
+			questionWhere := fmt.Sprintf("%s, question %d", where, j+1)
+			if len(category.Questions) == 10 {
+				im.warn("%s skipped, theme can have at most 10 questions", questionWhere)
+				continue
+			}
+			question, ok, err := im.importQuestion(xmlQuestion, questionWhere)
+			if err != nil {
+				return round, false, err
+			}
+			if !ok {
+				continue
+			}
+			question.Index = len(category.Questions)
+			category.Questions = append(category.Questions, question)
+		}
+		if len(category.Questions) == 0 {
+			im.warn("%s skipped, it has no importable questions", where)
+			continue
+		}
+
+		name := strings.TrimSpace(theme.Name)
+		if name == "" {
+			name = "Theme " + strconv.Itoa(len(round.Categories)+1)
+		}
+		category.Name = im.unique(name, categoryNames, 25, fmt.Sprintf("round \"%s\" theme", round.Name))
+		round.Categories = append(round.Categories, category)
+	}
+	if len(round.Categories) == 0 {
+		im.warn("round \"%s\" skipped, it has no importable themes", round.Name)
+		return round, false, nil
+	}
+
+	// every category of the round must have equal number of questions
+	questionsCount := len(round.Categories[0].Questions)
+	for _, category := range round.Categories {
+		if len(category.Questions) < questionsCount {
+			questionsCount = len(category.Questions)
+		}
+	}
+	for j := range round.Categories {
+		category := &round.Categories[j]
+		if len(category.Questions) > questionsCount {
+			im.warn(
+				"round \"%s\", theme \"%s\": last %d question(s) skipped, every theme of the round must have %d",
+				round.Name, category.Name, len(category.Questions)-questionsCount, questionsCount,
+			)
+			category.Questions = category.Questions[:questionsCount]
+		}
+	}
+
+	return round, true, nil
+}
+
+func (im *importer) importFinalRound(finalRound *entities.FinalRound, xmlRound xmlRound) error {
+	for _, theme := range xmlRound.Themes {
+		where := fmt.Sprintf("final round \"%s\", theme \"%s\"", xmlRound.Name, theme.Name)
+		if len(finalRound.Categories) == 10 {
+			im.warn("%s skipped, final round can have at most 10 themes", where)
+			continue
+		}
+		if len(theme.Questions) == 0 {
+			im.warn("%s skipped, it has no questions", where)
+			continue
+		}
+		if len(theme.Questions) > 1 {
+			im.warn("%s: only the first question imported, final theme has exactly one", where)
+		}
+
+		question, ok, err := im.importQuestion(theme.Questions[0], where)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		finalQuestion := entities.FinalQuestion{
+			HiddenFinalQuestion: entities.HiddenFinalQuestion{
+				Text:       question.Text,
+				Attachment: question.Attachment,
+			},
+			Answers: question.Answers,
+		}
+		if question.Comment != nil {
+			comment := im.truncate(*question.Comment, 100, where+" comment")
+			if len([]rune(comment)) < 10 {
+				im.warn("%s: comment dropped, final question comment must be at least 10 characters long", where)
+			} else {
+				finalQuestion.Comment = &comment
+			}
+		}
+
+		name := strings.TrimSpace(theme.Name)
+		if name == "" {
+			name = "Theme " + strconv.Itoa(len(finalRound.Categories)+1)
+		}
+		finalRound.Categories = append(finalRound.Categories, entities.FinalCategory{
+			HiddenFinalCategory: entities.HiddenFinalCategory{Name: im.truncate(name, 25, where+" name")},
+			Question:            finalQuestion,
+		})
+	}
+	return nil
+}
+
+func (im *importer) importQuestion(xmlQuestion xmlQuestion, where string) (entities.Question, bool, error) {
+	question := entities.Question{}
+
+	questionType := xmlQuestion.Type
+	if xmlQuestion.OldType != nil {
+		questionType = xmlQuestion.OldType.Name
+	}
+	if questionType != "" && questionType != "simple" {
+		im.warn("%s: special type \"%s\" is not supported, imported as a regular question", where, questionType)
+	}
+
+	value := xmlQuestion.Price
+	if value < 0 {
+		value = 0
+	}
+	if value > 10000 {
+		value = 10000
+	}
+	if value != xmlQuestion.Price {
+		im.warn("%s: price %d is out of range, set to %d", where, xmlQuestion.Price, value)
+	}
+	question.Value = value
+
+	content := im.readContent(xmlQuestion, where)
+	if xmlQuestion.Info != nil && strings.TrimSpace(xmlQuestion.Info.Comments) != "" {
+		content.comment = append(content.comment, strings.TrimSpace(xmlQuestion.Info.Comments))
+	}
+
+	answers := make([]string, 0, len(xmlQuestion.Right))
+	for _, answer := range xmlQuestion.Right {
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			continue
+		}
+		if len(answers) == 10 {
+			im.warn("%s: answer \"%s\" skipped, question can have at most 10 answers", where, answer)
+			continue
+		}
+		answers = append(answers, im.truncate(answer, 50, where+" answer"))
+	}
+	if len(answers) == 0 {
+		im.warn("%s skipped, it has no right answers", where)
+		return question, false, nil
+	}
+	question.Answers = answers
+
+	for i, ref := range content.media {
+		if question.Attachment != nil {
+			im.warn("%s: %s \"%s\" dropped, question can have only one attachment", where, content.media[i].mediaType, ref.value)
+			continue
+		}
+		attachment, err := im.importMedia(ref, where)
+		if err != nil {
+			return question, false, err
+		}
+		question.Attachment = attachment
+	}
+
+	text := strings.Join(content.text, " ")
+	if text == "" {
+		if question.Attachment == nil {
+			im.warn("%s skipped, it has neither text nor importable media", where)
+			return question, false, nil
+		}
+		text = "[" + string(question.Attachment.MediaType) + "]"
+	}
+	question.Text = im.truncate(text, 200, where+" text")
+
+	if len(content.comment) > 0 {
+		comment := im.truncate(strings.Join(content.comment, " "), 200, where+" comment")
+		question.Comment = &comment
+	}
+
+	return question, true, nil
+}
+
+// Version 4 keeps content in scenario atoms, everything after marker atom
+// is shown with the answer. Version 5 keeps it in "question" and "answer" params
+func (im *importer) readContent(xmlQuestion xmlQuestion, where string) questionContent {
+	var content questionContent
+
+	isAfterMarker := false
+	for _, atom := range xmlQuestion.Scenario {
+		if atom.Type == MARKER_ATOM {
+			isAfterMarker = true
+			continue
+		}
+		if mediaType, ok := mediaTypes[atom.Type]; ok {
+			value, isRef := strings.CutPrefix(strings.TrimSpace(atom.Value), "@")
+			content.media = append(content.media, mediaRef{mediaType: mediaType, value: value, isRef: isRef})
+			continue
+		}
+		im.addText(&content, atom.Type, atom.Value, isAfterMarker, where)
+	}
+
+	for _, param := range xmlQuestion.Params {
+		if param.Name != "question" && param.Name != "answer" {
+			continue
+		}
+		isAnswer := param.Name == "answer"
+		for _, item := range param.Items {
+			if mediaType, ok := mediaTypes[item.Type]; ok {
+				if isAnswer {
+					im.warn("%s: answer %s \"%s\" dropped, only question can have an attachment", where, mediaType, item.Value)
+					continue
+				}
+				content.media = append(content.media, mediaRef{
+					mediaType: mediaType,
+					value:     strings.TrimSpace(item.Value),
+					isRef:     strings.EqualFold(item.IsRef, "true"),
+				})
+				continue
+			}
+			im.addText(&content, item.Type, item.Value, isAnswer, where)
+		}
+	}
+
+	return content
+}
+
+func (im *importer) addText(content *questionContent, contentType string, value string, isComment bool, where string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	if contentType != "" && contentType != "text" && contentType != "say" {
+		im.warn("%s: content of type \"%s\" dropped, it is not supported", where, contentType)
+		return
+	}
+	if isComment {
+		content.comment = append(content.comment, value)
+	} else {
+		content.text = append(content.text, value)
+	}
+}
+
+func (im *importer) importMedia(ref mediaRef, where string) (*entities.Attachment, error) {
+	if !ref.isRef {
+		parsed, err := url.Parse(ref.value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			im.warn("%s: %s \"%s\" dropped, it is neither bundled nor a valid url", where, ref.mediaType, ref.value)
+			return nil, nil
+		}
+		return &entities.Attachment{MediaType: ref.mediaType, ContentUrl: ref.value}, nil
+	}
+
+	file, ok := im.files[mediaDirs[ref.mediaType]+ref.value]
+	if !ok {
+		im.warn("%s: %s \"%s\" dropped, it is missing in the package", where, ref.mediaType, ref.value)
+		return nil, nil
+	}
+	if contentUrl, ok := im.stored[file.Name]; ok {
+		return &entities.Attachment{MediaType: ref.mediaType, ContentUrl: contentUrl}, nil
+	}
+	if file.UncompressedSize64 > MAX_MEDIA_SIZE {
+		im.warn("%s: %s \"%s\" dropped, it is larger than %d bytes", where, ref.mediaType, ref.value, MAX_MEDIA_SIZE)
+		return nil, nil
+	}
+	if im.store == nil {
+		im.warn("%s: %s \"%s\" dropped, bundled media can not be hosted by this server", where, ref.mediaType, ref.value)
+		return nil, nil
+	}
+
+	name := path.Base(file.Name)
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	r, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPackage, err)
+	}
+	defer r.Close()
+	contentUrl, err := im.store(name, ref.mediaType, io.LimitReader(r, MAX_MEDIA_SIZE))
+	if err != nil {
+		if errors.Is(err, ErrMediaRejected) {
+			im.warn("%s: %s \"%s\" dropped, %s", where, ref.mediaType, ref.value, err)
+			return nil, nil
+		}
+		return nil, err
+	}
+	im.stored[file.Name] = contentUrl
+
+	return &entities.Attachment{MediaType: ref.mediaType, ContentUrl: contentUrl}, nil
+}
+
+func (im *importer) truncate(s string, maxLength int, what string) string {
+	runes := []rune(s)
+	if len(runes) <= maxLength {
+		return s
+	}
+	im.warn("%s truncated to %d characters", what, maxLength)
+	return strings.TrimSpace(string(runes[:maxLength]))
+}
+
+// Names must be unique within their parent, duplicates get a numeric suffix
+func (im *importer) unique(name string, used map[string]bool, maxLength int, what string) string {
+	name = im.truncate(name, maxLength, what)
+	if !used[name] {
+		used[name] = true
+		return name
+	}
+	for i := 2; ; i++ {
+		suffix := " " + strconv.Itoa(i)
+		runes := []rune(name)
+		if len(runes) > maxLength-len(suffix) {
+			runes = runes[:maxLength-len(suffix)]
+		}
+		candidate := string(runes) + suffix
+		if !used[candidate] {
+			im.warn("%s \"%s\" is duplicated, renamed to \"%s\"", what, name, candidate)
+			used[candidate] = true
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package spreadsheet
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/holdennekt/sgame/entities"
+)
+
+const (
+	ROUND_COLUMN           = "round"
+	CATEGORY_COLUMN        = "category"
+	VALUE_COLUMN           = "value"
+	QUESTION_COLUMN        = "question"
+	ANSWERS_COLUMN         = "answers"
+	COMMENT_COLUMN         = "comment"
+	ATTACHMENT_COLUMN      = "attachment"
+	ATTACHMENT_TYPE_COLUMN = "attachmenttype"
+)
+
+// Rows with this round name make up the final round
+const FINAL_ROUND_NAME = "final"
+const ANSWERS_SEPARATOR = "|"
+
+var requiredColumns = []string{ROUND_COLUMN, CATEGORY_COLUMN, VALUE_COLUMN, QUESTION_COLUMN, ANSWERS_COLUMN}
+
+var columnAliases = map[string]string{
+	"attachmenturl": ATTACHMENT_COLUMN,
+	"answer":        ANSWERS_COLUMN,
+	"theme":         CATEGORY_COLUMN,
+	"price":         VALUE_COLUMN,
+}
+
+type RowError struct {
+	// 1-based as shown by spreadsheet editors, 0 when the error is about the whole sheet
+	Row    int    `json:"row,omitempty"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
+}
+
+type ValidationError struct {
+	Errors []RowError
+}
+
+// Like "row 3, answer", empty for problems of the whole sheet
+func (re RowError) Location() string {
+	switch {
+	case re.Row == 0:
+		return ""
+	case re.Column == "":
+		return fmt.Sprintf("row %d", re.Row)
+	default:
+		return fmt.Sprintf("row %d, %s", re.Row, re.Column)
+	}
+}
+
+func (ve *ValidationError) Error() string {
+	messages := make([]string, len(ve.Errors))
+	for i, rowErr := range ve.Errors {
+		if location := rowErr.Location(); location != "" {
+			messages[i] = location + ": " + rowErr.Error
+		} else {
+			messages[i] = rowErr.Error
+		}
+	}
+	return strings.Join(messages, ", ")
+}
+
+func (ve *ValidationError) add(row int, column string, format string, args ...any) {
+	ve.Errors = append(ve.Errors, RowError{Row: row, Column: column, Error: fmt.Sprintf(format, args...)})
+}
+
+type categoryRows struct {
+	firstRow int
+	index    int
+}
+
+type roundRows struct {
+	round      *entities.Round
+	categories map[string]*categoryRows
+}
+
+type builder struct {
+	columns map[string]int
+	ve      *ValidationError
+}
+
+func (b *builder) cell(row []string, column string) string {
+	i, ok := b.columns[column]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// Builds pack from rows of a sheet, the first row must be the header.
+// Every problem is reported with the row it was found in
+func BuildPack(rows [][]string, name string, packType entities.PrivacyType) (entities.PackDTO, error) {
+	packDTO := entities.PackDTO{
+		Name:   name,
+		Type:   packType,
+		Rounds: make([]entities.Round, 0),
+		FinalRound: entities.FinalRound{
+			Categories: make([]entities.FinalCategory, 0),
+		},
+	}
+	b := &builder{columns: make(map[string]int), ve: &ValidationError{}}
+
+	if len(rows) == 0 {
+		b.ve.add(0, "", "sheet is empty")
+		return packDTO, b.ve
+	}
+	for i, header := range rows[0] {
+		column := strings.ToLower(strings.Join(strings.Fields(header), ""))
+		if alias, ok := columnAliases[column]; ok {
+			column = alias
+		}
+		if _, ok := b.columns[column]; ok {
+			b.ve.add(1, header, "column is duplicated")
+			continue
+		}
+		b.columns[column] = i
+	}
+	for _, column := range requiredColumns {
+		if _, ok := b.columns[column]; !ok {
+			b.ve.add(1, "", "missing \"%s\" column", column)
+		}
+	}
+	if len(b.ve.Errors) > 0 {
+		return packDTO, b.ve
+	}
+
+	rounds := make([]*roundRows, 0)
+	roundsByName := make(map[string]*roundRows)
+	finalCategories := make(map[string]bool)
+	hasFinalRows := false
+	for i, row := range rows[1:] {
+		rowNumber := i + 2
+		if isEmpty(row) {
+			continue
+		}
+
+		roundName := b.cell(row, ROUND_COLUMN)
+		categoryName := b.cell(row, CATEGORY_COLUMN)
+		if strings.EqualFold(roundName, FINAL_ROUND_NAME) {
+			hasFinalRows = true
+			finalCategory, ok := b.finalCategory(row, rowNumber)
+			if !ok {
+				continue
+			}
+			if finalCategories[finalCategory.Name] {
+				b.ve.add(rowNumber, CATEGORY_COLUMN, "final round already has category \"%s\", it can have only one question", categoryName)
+				continue
+			}
+			if len(packDTO.FinalRound.Categories) == 10 {
+				b.ve.add(rowNumber, CATEGORY_COLUMN, "final round can have at most 10 categories")
+				continue
+			}
+			finalCategories[finalCategory.Name] = true
+			packDTO.FinalRound.Categories = append(packDTO.FinalRound.Categories, finalCategory)
+			continue
+		}
+
+		question, ok := b.question(row, rowNumber)
+		if !b.checkLength(roundName, 1, 50, rowNumber, ROUND_COLUMN) {
+			ok = false
+		}
+		if !b.checkLength(categoryName, 1, 25, rowNumber, CATEGORY_COLUMN) {
+			ok = false
+		}
+		if !ok {
+			continue
+		}
+
+		rr, exists := roundsByName[roundName]
+		if !exists {
+			if len(rounds) == 10 {
+				b.ve.add(rowNumber, ROUND_COLUMN, "pack can have at most 10 rounds")
+				continue
+			}
+			rr = &roundRows{
+				round:      &entities.Round{Name: roundName, Categories: make([]entities.Category, 0)},
+				categories: make(map[string]*categoryRows),
+			}
+			roundsByName[roundName] = rr
+			rounds = append(rounds, rr)
+		}
+		cr, exists := rr.categories[categoryName]
+		if !exists {
+			if len(rr.categories) == 10 {
+				b.ve.add(rowNumber, CATEGORY_COLUMN, "round \"%s\" can have at most 10 categories", roundName)
+				continue
+			}
+			cr = &categoryRows{firstRow: rowNumber, index: len(rr.round.Categories)}
+			rr.categories[categoryName] = cr
+			rr.round.Categories = append(rr.round.Categories, entities.Category{
+				Name:      categoryName,
+				Questions: make([]entities.Question, 0),
+			})
+		}
+		category := &rr.round.Categories[cr.index]
+		if len(category.Questions) == 10 {
+			b.ve.add(rowNumber, "", "category \"%s\" of round \"%s\" can have at most 10 questions", categoryName, roundName)
+			continue
+		}
+		question.Index = len(category.Questions)
+		category.Questions = append(category.Questions, question)
+	}
+
+	for _, rr := range rounds {
+		first := rr.round.Categories[0]
+		for _, category := range rr.round.Categories[1:] {
+			if len(category.Questions) != len(first.Questions) {
+				b.ve.add(
+					rr.categories[category.Name].firstRow, CATEGORY_COLUMN,
+					"category \"%s\" has %d questions, but category \"%s\" of the same round has %d, within the round every category must have equal number of questions",
+					category.Name, len(category.Questions), first.Name, len(first.Questions),
+				)
+			}
+		}
+		packDTO.Rounds = append(packDTO.Rounds, *rr.round)
+	}
+	if !hasFinalRows {
+		b.ve.add(0, "", "final round is missing, add rows with \"%s\" in \"%s\" column", FINAL_ROUND_NAME, ROUND_COLUMN)
+	}
+
+	if len(b.ve.Errors) > 0 {
+		return packDTO, b.ve
+	}
+	return packDTO, nil
+}
+
+func (b *builder) question(row []string, rowNumber int) (entities.Question, bool) {
+	question := entities.Question{}
+	ok := true
+
+	value, err := strconv.Atoi(b.cell(row, VALUE_COLUMN))
+	if err != nil {
+		// numbers of XLSX cells may come as floats
+		floatValue, floatErr := strconv.ParseFloat(b.cell(row, VALUE_COLUMN), 64)
+		if floatErr != nil || floatValue != float64(int(floatValue)) {
+			b.ve.add(rowNumber, VALUE_COLUMN, "must be an integer")
+			ok = false
+		}
+		value = int(floatValue)
+	}
+	if value > 10000 {
+		b.ve.add(rowNumber, VALUE_COLUMN, "must be at most 10000")
+		ok = false
+	}
+	question.Value = value
+
+	text, attachment, answers, textOk := b.content(row, rowNumber)
+	question.Text = text
+	question.Attachment = attachment
+	question.Answers = answers
+
+	comment := b.cell(row, COMMENT_COLUMN)
+	if comment != "" {
+		if !b.checkLength(comment, 1, 200, rowNumber, COMMENT_COLUMN) {
+			ok = false
+		}
+		question.Comment = &comment
+	}
+
+	return question, ok && textOk
+}
+
+func (b *builder) finalCategory(row []string, rowNumber int) (entities.FinalCategory, bool) {
+	name := b.cell(row, CATEGORY_COLUMN)
+	ok := b.checkLength(name, 1, 25, rowNumber, CATEGORY_COLUMN)
+
+	text, attachment, answers, textOk := b.content(row, rowNumber)
+	finalQuestion := entities.FinalQuestion{
+		HiddenFinalQuestion: entities.HiddenFinalQuestion{Text: text, Attachment: attachment},
+		Answers:             answers,
+	}
+
+	comment := b.cell(row, COMMENT_COLUMN)
+	if comment != "" {
+		if !b.checkLength(comment, 10, 100, rowNumber, COMMENT_COLUMN) {
+			ok = false
+		}
+		finalQuestion.Comment = &comment
+	}
+
+	return entities.FinalCategory{
+		HiddenFinalCategory: entities.HiddenFinalCategory{Name: name},
+		Question:            finalQuestion,
+	}, ok && textOk
+}
+
+// Question text, attachment and answers, shared by regular and final questions
+func (b *builder) content(row []string, rowNumber int) (string, *entities.Attachment, []string, bool) {
+	text := b.cell(row, QUESTION_COLUMN)
+	ok := b.checkLength(text, 1, 200, rowNumber, QUESTION_COLUMN)
+
+	answers := make([]string, 0)
+	for _, answer := range strings.Split(b.cell(row, ANSWERS_COLUMN), ANSWERS_SEPARATOR) {
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			continue
+		}
+		if !b.checkLength(answer, 1, 50, rowNumber, ANSWERS_COLUMN) {
+			ok = false
+		}
+		answers = append(answers, answer)
+	}
+	if len(answers) == 0 || len(answers) > 10 {
+		b.ve.add(rowNumber, ANSWERS_COLUMN, "must have from 1 to 10 answers separated by \"%s\"", ANSWERS_SEPARATOR)
+		ok = false
+	}
+
+	attachment, attachmentOk := b.attachment(row, rowNumber)
+	return text, attachment, answers, ok && attachmentOk
+}
+
+func (b *builder) attachment(row []string, rowNumber int) (*entities.Attachment, bool) {
+	contentUrl := b.cell(row, ATTACHMENT_COLUMN)
+	if contentUrl == "" {
+		return nil, true
+	}
+
+	parsed, err := url.Parse(contentUrl)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		b.ve.add(rowNumber, ATTACHMENT_COLUMN, "must be a valid http(s) url")
+		return nil, false
+	}
+	if len(contentUrl) > 2000 {
+		b.ve.add(rowNumber, ATTACHMENT_COLUMN, "must be at most 2000 characters long")
+		return nil, false
+	}
+
+	mediaType := entities.MediaType(strings.ToLower(b.cell(row, ATTACHMENT_TYPE_COLUMN)))
+	if mediaType == "" {
+		contentType := mime.TypeByExtension(path.Ext(parsed.Path))
+		mediaType = entities.MediaType(strings.SplitN(contentType, "/", 2)[0])
+	}
+	switch mediaType {
+	case entities.Image, entities.Audio, entities.Video:
+	default:
+		b.ve.add(rowNumber, ATTACHMENT_TYPE_COLUMN, "must be one of image, audio, video, it can not be guessed from the url")
+		return nil, false
+	}
+
+	return &entities.Attachment{MediaType: mediaType, ContentUrl: contentUrl}, true
+}
+
+func (b *builder) checkLength(s string, min int, max int, rowNumber int, column string) bool {
+	length := len([]rune(s))
+	if length == 0 && min > 0 {
+		b.ve.add(rowNumber, column, "is required")
+		return false
+	}
+	if length < min || length > max {
+		b.ve.add(rowNumber, column, "must be from %d to %d characters long", min, max)
+		return false
+	}
+	return true
+}
+
+func isEmpty(row []string) bool {
+	for _, cell := range row {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,233 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const MAX_SHEET_SIZE = 20 << 20
+
+// Rows and columns are padded up to indexes given in the file, so the indexes are limited.
+// Rows may be this far apart, columns are far more than the known ones, extra ones like notes are ignored
+const MAX_ROW_GAP = 1000
+const MAX_COLUMNS = 64
+
+var ErrInvalidSpreadsheet = errors.New("invalid spreadsheet")
+
+var xlsxMagic = []byte("PK\x03\x04")
+
+// Reads rows of CSV file or of the first worksheet of XLSX file,
+// the format is detected by content. Rows and cells placed where they
+// can not be read are reported as ValidationError
+func ReadRows(content []byte) ([][]string, error) {
+	if bytes.HasPrefix(content, xlsxMagic) {
+		return readXlsx(content)
+	}
+	return readCsv(content)
+}
+
+func readCsv(content []byte) ([][]string, error) {
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	// spreadsheet editors in many locales export CSV with semicolons
+	firstLine, _, _ := bytes.Cut(content, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSpreadsheet, err)
+	}
+	return rows, nil
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Id string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxRichText `xml:"si"`
+}
+
+type xlsxRichText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (rt xlsxRichText) String() string {
+	if len(rt.Runs) == 0 {
+		return rt.Text
+	}
+	var sb strings.Builder
+	for _, run := range rt.Runs {
+		sb.WriteString(run.Text)
+	}
+	return sb.String()
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Index int `xml:"r,attr"`
+		Cells []struct {
+			Ref       string       `xml:"r,attr"`
+			Type      string       `xml:"t,attr"`
+			Value     string       `xml:"v"`
+			InlineStr xlsxRichText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXlsx(content []byte) ([][]string, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSpreadsheet, err)
+	}
+	files := make(map[string]*zip.File, len(zipReader.File))
+	for _, file := range zipReader.File {
+		files[file.Name] = file
+	}
+
+	var workbook xlsxWorkbook
+	if err := decodeXml(files, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if len(workbook.Sheets) == 0 {
+		return nil, fmt.Errorf("%w: workbook has no sheets", ErrInvalidSpreadsheet)
+	}
+	var relationships xlsxRelationships
+	if err := decodeXml(files, "xl/_rels/workbook.xml.rels", &relationships); err != nil {
+		return nil, err
+	}
+	sheetPath := ""
+	for _, relationship := range relationships.Relationships {
+		if relationship.Id == workbook.Sheets[0].Id {
+			sheetPath = relationship.Target
+			break
+		}
+	}
+	if sheetPath == "" {
+		return nil, fmt.Errorf("%w: first sheet is missing", ErrInvalidSpreadsheet)
+	}
+	if strings.HasPrefix(sheetPath, "/") {
+		sheetPath = strings.TrimPrefix(sheetPath, "/")
+	} else {
+		sheetPath = path.Join("xl", sheetPath)
+	}
+
+	var sharedStrings xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeXml(files, "xl/sharedStrings.xml", &sharedStrings); err != nil {
+			return nil, err
+		}
+	}
+
+	var worksheet xlsxWorksheet
+	if err := decodeXml(files, sheetPath, &worksheet); err != nil {
+		return nil, err
+	}
+
+	ve := &ValidationError{}
+	rows := make([][]string, 0, len(worksheet.Rows))
+	for _, xmlRow := range worksheet.Rows {
+		// the index is optional, rows without it follow the previous one
+		if xmlRow.Index != 0 && (xmlRow.Index <= len(rows) || xmlRow.Index > len(rows)+1+MAX_ROW_GAP) {
+			ve.add(xmlRow.Index, "", "row index is out of order or too far from the previous row")
+			continue
+		}
+		// empty rows are omitted from the sheet, keep row numbers as the user sees them
+		for xmlRow.Index > len(rows)+1 {
+			rows = append(rows, []string{})
+		}
+		row := make([]string, 0, len(xmlRow.Cells))
+		for _, cell := range xmlRow.Cells {
+			column := len(row)
+			if cell.Ref != "" {
+				column = columnIndex(cell.Ref)
+			}
+			if column < len(row) || column >= MAX_COLUMNS {
+				ve.add(len(rows)+1, cell.Ref, "cell is out of order or beyond the first %d columns", MAX_COLUMNS)
+				continue
+			}
+			for len(row) < column {
+				row = append(row, "")
+			}
+
+			var value string
+			switch cell.Type {
+			case "s":
+				i, err := strconv.Atoi(cell.Value)
+				if err != nil || i < 0 || i >= len(sharedStrings.Items) {
+					return nil, fmt.Errorf("%w: cell %s references missing shared string", ErrInvalidSpreadsheet, cell.Ref)
+				}
+				value = sharedStrings.Items[i].String()
+			case "inlineStr":
+				value = cell.InlineStr.String()
+			default:
+				value = cell.Value
+			}
+			row = append(row, value)
+		}
+		rows = append(rows, row)
+	}
+	if len(ve.Errors) > 0 {
+		return nil, ve
+	}
+	return rows, nil
+}
+
+func decodeXml(files map[string]*zip.File, name string, v any) error {
+	file, ok := files[name]
+	if !ok {
+		return fmt.Errorf("%w: missing %s", ErrInvalidSpreadsheet, name)
+	}
+	if file.UncompressedSize64 > MAX_SHEET_SIZE {
+		return fmt.Errorf("%w: %s is too large", ErrInvalidSpreadsheet, name)
+	}
+	r, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSpreadsheet, err)
+	}
+	defer r.Close()
+	if err := xml.NewDecoder(io.LimitReader(r, MAX_SHEET_SIZE)).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidSpreadsheet, name, err)
+	}
+	return nil
+}
+
+// Zero based column index of cell reference like "AB12", -1 if the reference has no column
+func columnIndex(ref string) int {
+	index := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		index = index*26 + int(r-'A'+1)
+		// later letters would only overflow
+		if index > MAX_COLUMNS {
+			return index - 1
+		}
+	}
+	return index - 1
+}
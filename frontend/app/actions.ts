@@ -5,19 +5,25 @@ import { PacksResp } from "./components/PacksList";
 import { cookies } from "next/headers";
 import { PackDTO } from "./components/pack/PackEditor";
 
-const PAGE_QUERY_PARAM = "page";
+const CURSOR_QUERY_PARAM = "cursor";
 const FILTER_QUERY_PARAM = "filter";
+const LIMIT_QUERY_PARAM = "limit";
 
-export const getPacks = async (packFilter: string, page?: number) => {
+export const getPacks = async (
+  packFilter: string,
+  limit: number,
+  cursor?: string
+) => {
   const url = new URL(`http://${process.env.BACKEND_HOST}/rest/packs`);
   url.searchParams.set(FILTER_QUERY_PARAM, packFilter);
-  if (page) url.searchParams.set(PAGE_QUERY_PARAM, page.toString());
+  url.searchParams.set(LIMIT_QUERY_PARAM, limit.toString());
+  if (cursor) url.searchParams.set(CURSOR_QUERY_PARAM, cursor);
   const resp = await fetch(url.toString(), {
     cache: "no-store",
     headers: { cookie: cookies().toString() },
   }).catch(console.log);
   const packs: PacksResp | ErrorDTO = await resp?.json();
-  if (isError(packs)) throw new Error(packs.error);
+  if (isError(packs)) throw new Error(packs.message);
   return packs;
 };
 
@@ -28,7 +34,7 @@ export const getPack = async (id: string) => {
     headers: { cookie: cookies().toString() },
   }).catch(console.log);
   const pack: PackDTO | ErrorDTO = await resp?.json();
-  if (isError(pack)) throw new Error(pack.error);
+  if (isError(pack)) throw new Error(pack.message);
   return pack;
 };
 
@@ -40,7 +46,7 @@ export const createPack = async (pack: PackDTO) => {
     body: JSON.stringify(pack),
   }).catch(console.log);
   const obj: { id: string } | ErrorDTO = await resp?.json();
-  if (isError(obj)) throw new Error(obj.error);
+  if (isError(obj)) throw new Error(obj.message);
   return obj;
 };
 
@@ -52,6 +58,6 @@ export const updatePack = async (id: string, pack: PackDTO) => {
     body: JSON.stringify(pack),
   }).catch(console.log);
   const obj: { id: string } | ErrorDTO = await resp?.json();
-  if (isError(obj)) throw new Error(obj.error);
+  if (isError(obj)) throw new Error(obj.message);
   return obj;
 };
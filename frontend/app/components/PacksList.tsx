@@ -14,7 +14,8 @@ import AddButton from "./AddButton";
 import { useRouter } from "next/navigation";
 import Link from "next/link";
 
-export type PacksResp = { count: number; portionRes: HiddenPack[] };
+export type Page<T> = { items: T[]; nextCursor: string | null };
+export type PacksResp = Page<HiddenPack>;
 export type HiddenPack = {
   id: string;
   author: UserDTO;
@@ -34,8 +35,7 @@ type HiddenFinalRound = {
   categories: HiddenCategory;
 };
 
-const PACKS_PER_PAGE = 10;
-const MAX_VISIBLE_PAGES = 5;
+export const PACKS_PER_PAGE = 10;
 
 export default function PacksList({
   user,
@@ -46,21 +46,22 @@ export default function PacksList({
 }) {
   const router = useRouter();
   const [packsFilter, setPacksFilter] = useState("");
-  const [pagesCount, setPagesCount] = useState(
-    Math.floor(initialPacks.count / PACKS_PER_PAGE) + 1
-  );
-  const [currentPage, setCurrentPage] = useState(1);
-  const [packs, setPacks] = useState(initialPacks.portionRes);
+  // cursors of the pages visited before the current one
+  const [prevCursors, setPrevCursors] = useState<(string | undefined)[]>([]);
+  const [currentCursor, setCurrentCursor] = useState<string>();
+  const [nextCursor, setNextCursor] = useState(initialPacks.nextCursor);
+  const [packs, setPacks] = useState(initialPacks.items);
   const [newRoomModal, setNewRoomModal] = useState<{
     isOpen: boolean;
     pack?: PackPreview;
   }>({ isOpen: false });
 
   const fetchPacks = useDebouncedCallback(async (packFilter: string) => {
-    const packs = await getPacks(packFilter);
-    setPacks(packs.portionRes);
-    setCurrentPage(1);
-    setPagesCount(Math.floor(packs.count / PACKS_PER_PAGE) + 1);
+    const packs = await getPacks(packFilter, PACKS_PER_PAGE);
+    setPacks(packs.items);
+    setPrevCursors([]);
+    setCurrentCursor(undefined);
+    setNextCursor(packs.nextCursor);
   }, 500);
 
   const onPacksFilterChange = (packsFilter: string) => {
@@ -68,24 +69,23 @@ export default function PacksList({
     fetchPacks(packsFilter.trim())?.catch(console.log);
   };
 
-  const selectPage = async (page: number) => {
-    if (page === currentPage) return;
-    const packs = await getPacks(packsFilter, page);
-    setPacks(packs.portionRes);
-  };
-
   const selectPrevPage = async () => {
-    if (currentPage === 1) return;
-    const packs = await getPacks(packsFilter, currentPage - 1);
-    setCurrentPage(currentPage - 1);
-    setPacks(packs.portionRes);
+    if (!prevCursors.length) return;
+    const cursor = prevCursors[prevCursors.length - 1];
+    const packs = await getPacks(packsFilter.trim(), PACKS_PER_PAGE, cursor);
+    setPrevCursors(prevCursors.slice(0, -1));
+    setCurrentCursor(cursor);
+    setNextCursor(packs.nextCursor);
+    setPacks(packs.items);
   };
 
   const selectNextPage = async () => {
-    if (currentPage === pagesCount) return;
-    const packs = await getPacks(packsFilter, currentPage + 1);
-    setCurrentPage(currentPage + 1);
-    setPacks(packs.portionRes);
+    if (!nextCursor) return;
+    const packs = await getPacks(packsFilter.trim(), PACKS_PER_PAGE, nextCursor);
+    setPrevCursors([...prevCursors, currentCursor]);
+    setCurrentCursor(nextCursor);
+    setNextCursor(packs.nextCursor);
+    setPacks(packs.items);
   };
 
   return (
@@ -112,22 +112,7 @@ export default function PacksList({
               >
                 prev
               </li>
-              {[...Array(Math.min(pagesCount, MAX_VISIBLE_PAGES)).keys()].map(
-                (val) => (
-                  <li
-                    className={`border cursor-pointer px-2 py-1${
-                      val + 1 === currentPage ? " bg-white text-black" : ""
-                    }`}
-                    onClick={() => selectPage(val + 1)}
-                    key={val}
-                  >
-                    {val + 1}
-                  </li>
-                )
-              )}
-              {pagesCount > MAX_VISIBLE_PAGES && (
-                <li className="border px-2 py-1">...</li>
-              )}
+              <li className="border px-2 py-1">{prevCursors.length + 1}</li>
               <li
                 className="border cursor-pointer rounded-r px-2 py-1"
                 onClick={selectNextPage}
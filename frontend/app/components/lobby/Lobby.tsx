@@ -77,7 +77,7 @@ export default function Lobby({
     });
     handlers.set("error", (payload) => {
       if (!isError(payload)) return;
-      toast.error(payload.error, { containerId: "lobby" });
+      toast.error(payload.message, { containerId: "lobby" });
     });
 
     wsConn.current.addEventListener("message", (ev: MessageEvent<string>) => {
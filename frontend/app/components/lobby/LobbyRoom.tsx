@@ -3,11 +3,12 @@ import Private from "@/public/private.png";
 import Public from "@/public/public.png";
 import Image from "next/image";
 import { UserDTO } from "../../../middleware";
+import { PackPreview } from "./NewRoomModal";
 
 export type LobbyRoomDTO = {
   id: string;
   name: string;
-  packPreview: { id: string; name: string; };
+  packPreview: PackPreview;
   host: UserDTO | null;
   players: UserDTO[];
   maxPlayers: number;
@@ -90,7 +91,14 @@ export default function LobbyRoom({
         >
           {room.packPreview.name}
         </Link>
+        {room.packPreview.difficulty && ` · ${room.packPreview.difficulty}`}
+        {room.packPreview.language && ` · ${room.packPreview.language}`}
       </p>
+      {!!room.packPreview.tags?.length && (
+        <p className="px-2 text-xs font-normal">
+          {room.packPreview.tags.map((tag) => `#${tag}`).join(" ")}
+        </p>
+      )}
       <div className="flex items-center mt-2 px-2">
         <div className="h-7 w-7 border">
           {getAvatar(room.host)}
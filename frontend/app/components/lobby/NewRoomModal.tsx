@@ -5,8 +5,16 @@ import Modal from "../Modal";
 import { useDebouncedCallback } from "use-debounce";
 import { useRouter } from "next/navigation";
 import { ErrorDTO, isError } from "@/middleware";
+import type { Page } from "../PacksList";
 
-export type PackPreview = { id: string; name: string };
+export type PackPreview = {
+  id: string;
+  name: string;
+  description?: string;
+  tags?: string[];
+  language?: string;
+  difficulty?: "easy" | "medium" | "hard" | "";
+};
 type CreateRoomParams = {
   name: string;
   packId: string;
@@ -17,6 +25,7 @@ type CreateRoomParams = {
     thinkingTime: number;
     thinkingTimeFinal: number;
     isFalseStartAllowed: boolean;
+    areGuestsDisallowed: boolean;
   };
 };
 
@@ -28,9 +37,9 @@ const getPacksPreview = async (packFilter: string) => {
   const resp = await fetch(
     `api/rest/packsPreview?${params.toString()}`,
   ).catch(console.log);
-  const packs: PackPreview[] | ErrorDTO = await resp?.json();
-  if (isError(packs)) throw new Error(packs.error);
-  return packs;
+  const packs: Page<PackPreview> | ErrorDTO = await resp?.json();
+  if (isError(packs)) throw new Error(packs.message);
+  return packs.items;
 }
 
 export default function NewRoomModal({
@@ -79,6 +88,8 @@ export default function NewRoomModal({
         thinkingTime,
         thinkingTimeFinal,
         isFalseStartAllowed: data.isFalseStartAllowed === "on",
+        // guests can not join private rooms anyway
+        areGuestsDisallowed: privacyType === "public" && data.areGuestsAllowed !== "on",
       },
     };
 
@@ -88,7 +99,7 @@ export default function NewRoomModal({
       body: JSON.stringify(params),
     });
     const obj: { id: string; } | ErrorDTO = await resp?.json();
-    if (isError(obj)) throw new Error(obj.error);
+    if (isError(obj)) throw new Error(obj.message);
 
     const pwd = params.options.password;
     const url = `/room/${obj.id}${pwd ? `?password=${pwd}` : ""}`;
@@ -162,6 +173,17 @@ export default function NewRoomModal({
                 <option value="private">Private</option>
               </select>
             </div>
+            {privacyType === "public" && (
+              <label>
+                <p className="text-sm font-medium">Guests Allowed</p>
+                <input
+                  className="w-full h-4"
+                  type="checkbox"
+                  name="areGuestsAllowed"
+                  defaultChecked
+                />
+              </label>
+            )}
             {privacyType === "private" && (
               <div>
                 <p className="text-sm font-medium">Password</p>
@@ -14,6 +14,11 @@ import Link from "next/link";
 export type PackDTO = {
   name: string;
   type: "public" | "private";
+  description?: string;
+  tags?: string[];
+  language?: string;
+  difficulty?: "easy" | "medium" | "hard" | "";
+  cover?: FinalQuestion["attachment"];
   rounds: Round[];
   finalRound: FinalRound;
 };
@@ -149,7 +149,7 @@ export default function Room({
     });
     handlers.set("error", (payload) => {
       if (!isError(payload)) return;
-      toast.error(payload.error, { containerId: "lobby" });
+      toast.error(payload.message, { containerId: "lobby" });
     });
 
     wsConn.current.addEventListener(
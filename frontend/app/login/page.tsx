@@ -21,7 +21,7 @@ export default function Page() {
     ).catch(console.log);
     const obj: { id: string; } | ErrorDTO = await resp?.json();
 
-    if (isError(obj)) return toast.error(obj.error, { containerId: "login" });
+    if (isError(obj)) return toast.error(obj.message, { containerId: "login" });
     router.push("/");
   };
 
@@ -11,7 +11,7 @@ const getRooms = async () => {
     headers: { cookie: cookies().toString() },
   });
   const rooms: LobbyRoomDTO[] | ErrorDTO = await resp?.json();
-  if (isError(rooms)) throw new Error(rooms.error);
+  if (isError(rooms)) throw new Error(rooms.message);
   return rooms;
 };
 
@@ -21,7 +21,7 @@ export default function Page() {
     ).catch(console.log);
     const obj: { id: string; } | ErrorDTO = await resp?.json();
 
-    if (isError(obj)) return toast.error(obj.error, { containerId: "register" });
+    if (isError(obj)) return toast.error(obj.message, { containerId: "register" });
     router.push("/");
   };
 
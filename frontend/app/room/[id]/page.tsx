@@ -13,7 +13,7 @@ const connectToRoom = async (id: string, password: string | undefined) => {
     headers: { cookie: cookies().toString() },
   }).catch(console.log);
   const room: RoomDTO | ErrorDTO = await resp?.json();
-  if (isError(room)) throw new Error(room.error);
+  if (isError(room)) throw new Error(room.message);
 }
 
 export default async function Page({
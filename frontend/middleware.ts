@@ -6,9 +6,20 @@ export const USER_HEADER_NAME = "user";
 
 export type UserDTO = { id: string; name: string; avatar: string | null };
 
-export type ErrorDTO = { error: string };
+// code is stable, messages are translated to the language of the user,
+// correlationId is the one logged by the server along with the error
+export type ErrorDetailDTO = { field?: string; code?: string; message: string };
+export type ErrorDTO = {
+  code: string;
+  message: string;
+  details?: ErrorDetailDTO[];
+  data?: Record<string, unknown>;
+  correlationId: string;
+  // id of the websocket message that has caused the error
+  messageId?: string;
+};
 export const isError = (obj: unknown): obj is ErrorDTO => (
-  (obj as ErrorDTO).error !== undefined
+  (obj as ErrorDTO).code !== undefined && (obj as ErrorDTO).message !== undefined
 );
 
 const unprotectedPages = ["/register", "/login", "/about"];